@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	pewpew "github.com/bengadbois/pewpew/lib"
+	"github.com/spf13/cobra"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Print the requests of a stress test to send them once without pewpew",
+	Long: `Print the requests of a stress test to send them once without pewpew.
+Takes the same URLs, flags and config file as stress, with the global settings cascaded onto the targets.`,
+}
+
+var exportCurlCmd = &cobra.Command{
+	Use:   "curl [URL...]",
+	Short: "Print a curl command for each target",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		targets, stressCfg, err := exportTargets(cmd, args)
+		if err != nil {
+			return err
+		}
+		failed := 0
+		for i, target := range targets {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("# Target %d: %s %s\n", target.index, target.Method, target.URL)
+			command, err := pewpew.CurlCommand(target.Target, stressCfg)
+			if err != nil {
+				fmt.Println("# " + err.Error())
+				failed++
+				continue
+			}
+			fmt.Println(command)
+		}
+		if failed > 0 {
+			return errors.New(strconv.Itoa(failed) + " of " + strconv.Itoa(len(targets)) + " targets could not be exported")
+		}
+		return nil
+	},
+}
+
+var exportGoCmd = &cobra.Command{
+	Use:   "go [URL...]",
+	Short: "Print a Go test file with a test sending each target's request",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		targets, stressCfg, err := exportTargets(cmd, args)
+		if err != nil {
+			return err
+		}
+		stressCfg.Targets = make([]pewpew.Target, len(targets))
+		for i, target := range targets {
+			stressCfg.Targets[i] = target.Target
+		}
+		test, err := pewpew.GoTest(stressCfg)
+		if err != nil {
+			return err
+		}
+		output, _ := cmd.Flags().GetString("output")
+		if output == "" {
+			fmt.Print(test)
+			return nil
+		}
+		if err := ioutil.WriteFile(output, []byte(test), 0644); err != nil {
+			return errors.New("failed to write Go test to " + output + ": " + err.Error())
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %d tests to %s\n", strings.Count(test, "\nfunc Test"), output)
+		return nil
+	},
+}
+
+//a target to export, with its 1-based index in the config
+type exportTarget struct {
+	pewpew.Target
+	index int
+}
+
+//the targets of the stress test to export, or only the --target one
+func exportTargets(cmd *cobra.Command, args []string) ([]exportTarget, pewpew.StressConfig, error) {
+	stressCfg, err := loadStressConfig(args)
+	if err != nil {
+		return nil, stressCfg, err
+	}
+	only, _ := cmd.Flags().GetInt("target")
+	if only < 0 || only > len(stressCfg.Targets) {
+		return nil, stressCfg, errors.New("--target must be between 1 and " + strconv.Itoa(len(stressCfg.Targets)))
+	}
+	var targets []exportTarget
+	for i, target := range stressCfg.Targets {
+		if only == 0 || only == i+1 {
+			//each request replayed from an access log is exported on its own
+			for _, replayed := range pewpew.ReplayedTargets(target) {
+				targets = append(targets, exportTarget{replayed, i + 1})
+			}
+		}
+	}
+	return targets, stressCfg, nil
+}
+
+func init() {
+	RootCmd.AddCommand(exportCmd)
+	exportCmd.PersistentFlags().Int("target", 0, "Only export this target, numbered from 1 in the order of the config. Default exports all of them.")
+	//the stress test flags are added once they are defined, in stress.go
+
+	exportCmd.AddCommand(exportCurlCmd)
+
+	exportCmd.AddCommand(exportGoCmd)
+	exportGoCmd.Flags().StringP("output", "o", "", "Write the test file here instead of stdout, eg. 'repro_test.go'.")
+}
@@ -0,0 +1,301 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	pewpew "github.com/bengadbois/pewpew/lib"
+	"github.com/spf13/cobra"
+	yaml "gopkg.in/yaml.v3"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Create a config file of targets from another format",
+}
+
+var importHARCmd = &cobra.Command{
+	Use:   "har FILE",
+	Short: "Create a config file from the requests of a HAR recording",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, err := os.Open(args[0])
+		if err != nil {
+			return errors.New("failed to open HAR file: " + err.Error())
+		}
+		defer file.Close()
+		domains, _ := cmd.Flags().GetStringArray("domain")
+		keepTiming, _ := cmd.Flags().GetBool("keep-timing")
+		targets, err := pewpew.TargetsFromHAR(file, pewpew.HAROptions{Domains: domains, KeepTiming: keepTiming})
+		if err != nil {
+			return err
+		}
+		return outputConfig(cmd, targets)
+	},
+}
+
+var importOpenAPICmd = &cobra.Command{
+	Use:   "openapi FILE",
+	Short: "Create a config file with a target for each operation of an OpenAPI 3 specification",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		spec, err := ioutil.ReadFile(args[0])
+		if err != nil {
+			return errors.New("failed to read OpenAPI specification: " + err.Error())
+		}
+		baseURL, _ := cmd.Flags().GetString("base-url")
+		targets, err := pewpew.TargetsFromOpenAPI(spec, pewpew.OpenAPIOptions{BaseURL: baseURL})
+		if err != nil {
+			return err
+		}
+		return outputConfig(cmd, targets)
+	},
+}
+
+var importCurlCmd = &cobra.Command{
+	Use:   "curl [COMMAND...]",
+	Short: "Create a config file from a curl command, like one copied with \"Copy as cURL\"",
+	Long: `Create a config file from a curl command, like one copied with "Copy as cURL".
+Pass the command as one quoted argument, as words after --, or on stdin.`,
+	Example: `  pewpew import curl "curl -H 'Accept: application/json' https://example.com"
+  pewpew import curl -- curl -H 'Accept: application/json' https://example.com
+  pbpaste | pewpew import curl`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		command, err := curlCommand(args)
+		if err != nil {
+			return err
+		}
+		target, err := pewpew.TargetFromCurl(command)
+		if err != nil {
+			return err
+		}
+		return outputConfig(cmd, []pewpew.Target{target})
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(importCmd)
+	importCmd.PersistentFlags().StringP("output", "o", "", "Write the config to this file instead of stdout.")
+	importCmd.PersistentFlags().String("format", "toml", "Format of the config: toml, json or yaml.")
+
+	importCmd.AddCommand(importHARCmd)
+	importHARCmd.Flags().StringArray("domain", nil, "Only import requests to this domain or its subdomains. Repeatable.")
+	importHARCmd.Flags().Bool("keep-timing", false, "Delay the start of each target to match when its request was recorded.")
+
+	importCmd.AddCommand(importOpenAPICmd)
+	importOpenAPICmd.Flags().String("base-url", "", "Send the requests here instead of the specification's first server, eg. 'https://staging.example.com/v1'.")
+
+	importCmd.AddCommand(importCurlCmd)
+}
+
+//the curl command given as one argument, as its words, or on stdin when there are none
+func curlCommand(args []string) (string, error) {
+	switch len(args) {
+	case 0:
+		command, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return "", errors.New("failed to read curl command: " + err.Error())
+		}
+		return string(command), nil
+	case 1:
+		return args[0], nil
+	}
+	//already split by the shell, so quoted again to be split the same way
+	words := make([]string, len(args))
+	for i, arg := range args {
+		words[i] = "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+	}
+	return strings.Join(words, " "), nil
+}
+
+//write the config of targets to the --output file, or stdout, in the --format
+func outputConfig(cmd *cobra.Command, targets []pewpew.Target) error {
+	var config bytes.Buffer
+	format, _ := cmd.Flags().GetString("format")
+	switch format {
+	case "toml":
+		if err := writeConfig(&config, targets); err != nil {
+			return err
+		}
+	case "json":
+		if err := writeJSONConfig(&config, targets); err != nil {
+			return err
+		}
+	case "yaml":
+		if err := writeYAMLConfig(&config, targets); err != nil {
+			return err
+		}
+	default:
+		return errors.New("unsupported config format: " + format)
+	}
+	output, _ := cmd.Flags().GetString("output")
+	if output == "" {
+		_, err := os.Stdout.Write(config.Bytes())
+		return err
+	}
+	if err := ioutil.WriteFile(output, config.Bytes(), 0644); err != nil {
+		return errors.New("failed to write config to " + output + ": " + err.Error())
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d targets to %s\n", len(targets), output)
+	return nil
+}
+
+//write targets as a TOML config file, leaving out the settings they don't set
+func writeConfig(w io.Writer, targets []pewpew.Target) error {
+	for i, target := range targets {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, "[[Targets]]")
+		targetVal := reflect.ValueOf(target)
+		for j := 0; j < targetVal.NumField(); j++ {
+			field := targetVal.Field(j)
+			//interfaces like Signer are only for library use
+			if field.IsZero() || field.Kind() == reflect.Interface {
+				continue
+			}
+			value, err := tomlValue(field)
+			if err != nil {
+				return errors.New("failed to write " + targetVal.Type().Field(j).Name + ": " + err.Error())
+			}
+			fmt.Fprintf(w, "%s = %s\n", targetVal.Type().Field(j).Name, value)
+		}
+	}
+	return nil
+}
+
+//write targets as a JSON config file, leaving out the settings they don't set
+func writeJSONConfig(w io.Writer, targets []pewpew.Target) error {
+	config := struct {
+		Targets []map[string]interface{}
+	}{make([]map[string]interface{}, len(targets))}
+	for i, target := range targets {
+		config.Targets[i] = make(map[string]interface{})
+		targetVal := reflect.ValueOf(target)
+		for j := 0; j < targetVal.NumField(); j++ {
+			field := targetVal.Field(j)
+			if field.IsZero() || field.Kind() == reflect.Interface {
+				continue
+			}
+			config.Targets[i][targetVal.Type().Field(j).Name] = field.Interface()
+		}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(config)
+}
+
+//write targets as a YAML config file, leaving out the settings they don't set
+func writeYAMLConfig(w io.Writer, targets []pewpew.Target) error {
+	//built as nodes, as settings would be sorted by name if they were in a map
+	list := &yaml.Node{Kind: yaml.SequenceNode}
+	for _, target := range targets {
+		settings := &yaml.Node{Kind: yaml.MappingNode}
+		targetVal := reflect.ValueOf(target)
+		for j := 0; j < targetVal.NumField(); j++ {
+			field := targetVal.Field(j)
+			if field.IsZero() || field.Kind() == reflect.Interface {
+				continue
+			}
+			var value yaml.Node
+			if err := value.Encode(field.Interface()); err != nil {
+				return errors.New("failed to write " + targetVal.Type().Field(j).Name + ": " + err.Error())
+			}
+			settings.Content = append(settings.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: targetVal.Type().Field(j).Name}, &value)
+		}
+		list.Content = append(list.Content, settings)
+	}
+	config := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{{Kind: yaml.ScalarNode, Value: "Targets"}, list}}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(config); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+//a setting's value written as TOML
+func tomlValue(v reflect.Value) (string, error) {
+	switch v.Kind() {
+	case reflect.String:
+		return tomlString(v.String()), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Float64:
+		float := strconv.FormatFloat(v.Float(), 'g', -1, 64)
+		if !strings.ContainsAny(float, ".e") {
+			float += ".0"
+		}
+		return float, nil
+	case reflect.Slice:
+		values := make([]string, v.Len())
+		for i := range values {
+			value, err := tomlValue(v.Index(i))
+			if err != nil {
+				return "", err
+			}
+			values[i] = value
+		}
+		return "[" + strings.Join(values, ", ") + "]", nil
+	case reflect.Map:
+		var keys []string
+		for _, key := range v.MapKeys() {
+			keys = append(keys, key.String())
+		}
+		sort.Strings(keys)
+		pairs := make([]string, len(keys))
+		for i, key := range keys {
+			value, err := tomlValue(v.MapIndex(reflect.ValueOf(key)))
+			if err != nil {
+				return "", err
+			}
+			pairs[i] = tomlString(key) + " = " + value
+		}
+		return "{" + strings.Join(pairs, ", ") + "}", nil
+	}
+	return "", errors.New("unsupported type " + v.Type().String())
+}
+
+//s as a TOML basic string, escaping what TOML requires
+func tomlString(s string) string {
+	var quoted strings.Builder
+	quoted.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			quoted.WriteString(`\"`)
+		case '\\':
+			quoted.WriteString(`\\`)
+		case '\b':
+			quoted.WriteString(`\b`)
+		case '\t':
+			quoted.WriteString(`\t`)
+		case '\n':
+			quoted.WriteString(`\n`)
+		case '\f':
+			quoted.WriteString(`\f`)
+		case '\r':
+			quoted.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&quoted, `\u%04X`, r)
+			} else {
+				quoted.WriteRune(r)
+			}
+		}
+	}
+	quoted.WriteByte('"')
+	return quoted.String()
+}
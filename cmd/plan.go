@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"os"
+
+	pewpew "github.com/bengadbois/pewpew/lib"
+	"github.com/spf13/cobra"
+)
+
+//samples of each target's requests printed by stress --dry-run
+const defaultPlanSamples = 3
+
+var planCmd = &cobra.Command{
+	Use:   "plan [URL...]",
+	Short: "Print what a stress test would send, without sending anything",
+	Long: `Print what a stress test would send, without sending anything.
+Takes the same URLs, flags and config file as stress, and prints the settings of each target,
+after the global settings have been applied, with samples of the requests it would send.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		samples, _ := cmd.Flags().GetInt("samples")
+		return runPlan(args, samples)
+	},
+}
+
+//print the plan of the stress test, with this many sample requests per target
+func runPlan(args []string, samples int) error {
+	stressCfg, err := loadStressConfig(args)
+	if err != nil {
+		return err
+	}
+	return pewpew.PrintPlan(stressCfg, samples, os.Stdout)
+}
+
+func init() {
+	RootCmd.AddCommand(planCmd)
+	planCmd.Flags().Int("samples", defaultPlanSamples, "Number of sample requests to print per target.")
+	//the stress test flags are added once they are defined, in stress.go
+}
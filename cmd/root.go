@@ -5,6 +5,7 @@ import (
 	"os"
 	"runtime"
 
+	pewpew "github.com/bengadbois/pewpew/lib"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -27,19 +28,69 @@ func Execute() {
 }
 
 func init() {
-	viper.SetConfigName("config") // name of config file (without extension)
-	viper.AddConfigPath(".")      // optionally look for config in the working directory
-	err := viper.ReadInConfig()   // Find and read the config file
-	if err != nil {               // Handle errors reading the config file
-		if _, ok := err.(viper.ConfigParseError); ok {
-			fmt.Println("Failed to parse config file " + viper.ConfigFileUsed())
+	cobra.OnInitialize(readConfig)
+
+	RootCmd.PersistentFlags().StringArray("config", nil, "Config file to use instead of config.json, config.toml or config.yaml in the working directory. Repeatable, with each file overriding the ones before it.")
+	RootCmd.PersistentFlags().Int("cpu", runtime.GOMAXPROCS(0), "Number of CPUs to use.")
+	RootCmd.PersistentFlags().BoolP("verbose", "v", false, "Print extra troubleshooting info.")
+	viper.BindPFlags(RootCmd.PersistentFlags())
+}
+
+//the values interpolated into the config files, which are redacted from output
+var configSecrets []string
+
+//read the --config files, each merged over the ones before it, or else the config file in the working directory
+func readConfig() {
+	files, _ := RootCmd.PersistentFlags().GetStringArray("config")
+	if len(files) == 0 {
+		search := viper.New()
+		search.SetConfigName("config") // name of config file (without extension)
+		search.AddConfigPath(".")      // optionally look for config in the working directory
+		err := search.ReadInConfig()   // Find the config file
+		if err != nil {                // Handle errors reading the config file
+			if _, ok := err.(viper.ConfigParseError); ok {
+				fmt.Println("Failed to parse config file " + search.ConfigFileUsed())
+				fmt.Println(err)
+				os.Exit(-1)
+			}
+			//on stderr, so it stays out of configs written to stdout
+			fmt.Fprintln(os.Stderr, "No config file found")
+			return
+		}
+		files = []string{search.ConfigFileUsed()}
+	}
+	for _, file := range files {
+		if err := mergeConfigFile(file); err != nil {
+			fmt.Println("Failed to read config file " + file)
 			fmt.Println(err)
 			os.Exit(-1)
 		}
-		fmt.Println("No config file found")
 	}
+}
 
-	RootCmd.PersistentFlags().Int("cpu", runtime.GOMAXPROCS(0), "Number of CPUs to use.")
-	RootCmd.PersistentFlags().BoolP("verbose", "v", false, "Print extra troubleshooting info.")
-	viper.BindPFlags(RootCmd.PersistentFlags())
+//merge the settings of a config file over those read before it, with references like ${API_KEY} replaced by their values.
+//Settings are merged key by key, but lists like Targets are replaced.
+func mergeConfigFile(file string) error {
+	fileConfig := viper.New()
+	fileConfig.SetConfigFile(file)
+	if err := fileConfig.ReadInConfig(); err != nil {
+		return err
+	}
+	settings := fileConfig.AllSettings()
+	secrets, err := pewpew.InterpolateSettings(settings)
+	if err != nil {
+		return err
+	}
+	configSecrets = append(configSecrets, secrets...)
+	viper.SetConfigFile(file)
+	return viper.MergeConfigMap(settings)
+}
+
+//the config files read, in the order they were merged
+func configFiles() []string {
+	files, _ := RootCmd.PersistentFlags().GetStringArray("config")
+	if len(files) == 0 && viper.ConfigFileUsed() != "" {
+		files = []string{viper.ConfigFileUsed()}
+	}
+	return files
 }
@@ -1,15 +1,20 @@
 package cmd
 
 import (
+	"compress/gzip"
 	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"reflect"
+	"strings"
 
 	pewpew "github.com/bengadbois/pewpew/lib"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -17,98 +22,12 @@ var stressCmd = &cobra.Command{
 	Use:   "stress URL...",
 	Short: "Run stress tests",
 	RunE: func(cmd *cobra.Command, args []string) error {
-
-		stressCfg := pewpew.StressConfig{}
-		err := viper.Unmarshal(&stressCfg)
-		if err != nil {
-			fmt.Println(err)
-			return errors.New("could not parse config file")
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			return runPlan(args, defaultPlanSamples)
 		}
-
-		//global configs
-		stressCfg.NoHTTP2 = viper.GetBool("noHTTP2")
-		stressCfg.EnforceSSL = viper.GetBool("enforceSSL")
-		stressCfg.Quiet = viper.GetBool("quiet")
-		stressCfg.Verbose = viper.GetBool("verbose")
-
-		//URLs are handled differently that other config options
-		//command line specifying URLs take higher precedence than config URLs
-
-		//check either set via config or command line
-		if len(stressCfg.Targets) == 0 && len(args) < 1 {
-			return errors.New("requires URL")
-		}
-
-		//if URLs are set on command line, use that for Targets instead of config
-		if len(args) >= 1 {
-			stressCfg.Targets = make([]pewpew.Target, len(args))
-			for i := range stressCfg.Targets {
-				stressCfg.Targets[i].URL = args[i]
-				//use global configs instead of the config file's individual target settings
-				stressCfg.Targets[i].RegexURL = viper.GetBool("regex")
-				stressCfg.Targets[i].Count = viper.GetInt("count")
-				stressCfg.Targets[i].Concurrency = viper.GetInt("concurrency")
-				stressCfg.Targets[i].Timeout = viper.GetString("timeout")
-				stressCfg.Targets[i].Method = viper.GetString("method")
-				stressCfg.Targets[i].Body = viper.GetString("body")
-				stressCfg.Targets[i].BodyFilename = viper.GetString("bodyFile")
-				stressCfg.Targets[i].Headers = viper.GetString("headers")
-				stressCfg.Targets[i].Cookies = viper.GetString("cookies")
-				stressCfg.Targets[i].UserAgent = viper.GetString("userAgent")
-				stressCfg.Targets[i].BasicAuth = viper.GetString("basicAuth")
-				stressCfg.Targets[i].Compress = viper.GetBool("compress")
-				stressCfg.Targets[i].KeepAlive = viper.GetBool("keepalive")
-				stressCfg.Targets[i].FollowRedirects = viper.GetBool("followredirects")
-			}
-		} else {
-			//set non-URL target settings
-			//walk through viper.Get() because that will show which were
-			//explictly set instead of guessing at zero-valued defaults
-			for i, target := range viper.Get("targets").([]interface{}) {
-				targetMapVals := target.(map[string]interface{})
-				if _, set := targetMapVals["RegexURL"]; !set {
-					stressCfg.Targets[i].RegexURL = viper.GetBool("regex")
-				}
-				if _, set := targetMapVals["Count"]; !set {
-					stressCfg.Targets[i].Count = viper.GetInt("count")
-				}
-				if _, set := targetMapVals["Concurrency"]; !set {
-					stressCfg.Targets[i].Concurrency = viper.GetInt("concurrency")
-				}
-				if _, set := targetMapVals["Timeout"]; !set {
-					stressCfg.Targets[i].Timeout = viper.GetString("timeout")
-				}
-				if _, set := targetMapVals["Method"]; !set {
-					stressCfg.Targets[i].Method = viper.GetString("method")
-				}
-				if _, set := targetMapVals["Body"]; !set {
-					stressCfg.Targets[i].Body = viper.GetString("body")
-				}
-				if _, set := targetMapVals["BodyFilename"]; !set {
-					stressCfg.Targets[i].BodyFilename = viper.GetString("bodyFile")
-				}
-				if _, set := targetMapVals["Headers"]; !set {
-					stressCfg.Targets[i].Headers = viper.GetString("headers")
-				}
-				if _, set := targetMapVals["Cookies"]; !set {
-					stressCfg.Targets[i].Cookies = viper.GetString("cookies")
-				}
-				if _, set := targetMapVals["UserAgent"]; !set {
-					stressCfg.Targets[i].UserAgent = viper.GetString("userAgent")
-				}
-				if _, set := targetMapVals["BasicAuth"]; !set {
-					stressCfg.Targets[i].BasicAuth = viper.GetString("basicAuth")
-				}
-				if _, set := targetMapVals["Compress"]; !set {
-					stressCfg.Targets[i].Compress = viper.GetBool("compress")
-				}
-				if _, set := targetMapVals["KeepAlive"]; !set {
-					stressCfg.Targets[i].KeepAlive = viper.GetBool("keepalive")
-				}
-				if _, set := targetMapVals["FollowRedirects"]; !set {
-					stressCfg.Targets[i].FollowRedirects = viper.GetBool("followredirects")
-				}
-			}
+		stressCfg, err := loadStressConfig(args)
+		if err != nil {
+			return err
 		}
 
 		targetRequestStats, err := pewpew.RunStress(stressCfg, os.Stdout)
@@ -116,30 +35,7 @@ var stressCmd = &cobra.Command{
 			return err
 		}
 
-		fmt.Print("\n----Summary----\n\n")
-
-		//only print individual target data if multiple targets
-		if len(stressCfg.Targets) > 1 {
-			for idx, target := range stressCfg.Targets {
-				//info about the request
-				fmt.Printf("----Target %d: %s %s\n", idx+1, target.Method, target.URL)
-				reqStats := pewpew.CreateRequestsStats(targetRequestStats[idx])
-				fmt.Println(pewpew.CreateTextSummary(reqStats))
-			}
-		}
-
-		//combine individual targets to a total one
-		globalStats := []pewpew.RequestStat{}
-		for i := range stressCfg.Targets {
-			for j := range targetRequestStats[i] {
-				globalStats = append(globalStats, targetRequestStats[i][j])
-			}
-		}
-		if len(stressCfg.Targets) > 1 {
-			fmt.Println("----Global----")
-		}
-		reqStats := pewpew.CreateRequestsStats(globalStats)
-		fmt.Println(pewpew.CreateTextSummary(reqStats))
+		globalStats := printSummary(stressCfg, targetRequestStats, os.Stdout)
 
 		//write out json
 		if viper.GetString("ResultFilenameJSON") != "" {
@@ -189,6 +85,21 @@ func init() {
 	stressCmd.Flags().BoolP("regex", "r", false, "Interpret URLs as regular expressions.")
 	viper.BindPFlag("regex", stressCmd.Flags().Lookup("regex"))
 
+	stressCmd.Flags().String("replay", "", "Replay the requests of this nginx or Apache access log, in combined or JSON format, instead of URLs. Can be gzipped.")
+	viper.BindPFlag("replay", stressCmd.Flags().Lookup("replay"))
+
+	stressCmd.Flags().String("replay-host", "", "Scheme and host to replay the access log against, eg. 'https://staging.example.com'.")
+	viper.BindPFlag("replayHost", stressCmd.Flags().Lookup("replay-host"))
+
+	stressCmd.Flags().Bool("replay-timing", false, "Send replayed requests as far apart as they were logged.")
+	viper.BindPFlag("replayTiming", stressCmd.Flags().Lookup("replay-timing"))
+
+	stressCmd.Flags().Float64("replay-speed", 1, "With --replay-timing, replay this many times faster than logged, eg. '2' or '0.5'.")
+	viper.BindPFlag("replaySpeed", stressCmd.Flags().Lookup("replay-speed"))
+
+	stressCmd.Flags().String("curl", "", "Send the request of this curl command, eg. one copied with \"Copy as cURL\", instead of URLs.")
+	viper.BindPFlag("curl", stressCmd.Flags().Lookup("curl"))
+
 	stressCmd.Flags().IntP("num", "n", 10, "Number of total requests to make.")
 	viper.BindPFlag("count", stressCmd.Flags().Lookup("num"))
 
@@ -198,6 +109,15 @@ func init() {
 	stressCmd.Flags().StringP("timeout", "t", "10s", "Maximum seconds to wait for response")
 	viper.BindPFlag("timeout", stressCmd.Flags().Lookup("timeout"))
 
+	stressCmd.Flags().String("dial-timeout", "", "Maximum time to wait for a connection to be established, eg. '500ms'.")
+	viper.BindPFlag("dialTimeout", stressCmd.Flags().Lookup("dial-timeout"))
+
+	stressCmd.Flags().String("tls-handshake-timeout", "", "Maximum time to wait for a TLS or QUIC handshake, eg. '1s'.")
+	viper.BindPFlag("tlsHandshakeTimeout", stressCmd.Flags().Lookup("tls-handshake-timeout"))
+
+	stressCmd.Flags().String("response-header-timeout", "", "Maximum time to wait for response headers once the request is sent, eg. '2s'.")
+	viper.BindPFlag("responseHeaderTimeout", stressCmd.Flags().Lookup("response-header-timeout"))
+
 	stressCmd.Flags().StringP("request-method", "X", "GET", "Request type. GET, HEAD, POST, PUT, etc.")
 	viper.BindPFlag("method", stressCmd.Flags().Lookup("request-method"))
 
@@ -207,18 +127,99 @@ func init() {
 	stressCmd.Flags().String("body-file", "", "Path to file to use as request body. Will overwrite --body if both are present.")
 	viper.BindPFlag("bodyFile", stressCmd.Flags().Lookup("body-file"))
 
-	stressCmd.Flags().StringP("headers", "H", "", "Add arbitrary header line, eg. 'Accept-Encoding:gzip, Content-Type:application/json'")
+	stressCmd.Flags().Bool("stream-body-file", false, "Read --body-file from disk as each request is sent, instead of holding it in memory.")
+	viper.BindPFlag("streamBodyFile", stressCmd.Flags().Lookup("stream-body-file"))
+
+	stressCmd.Flags().String("body-size", "", "Send a generated body of this size, eg. '512KB' or '50MB'.")
+	viper.BindPFlag("bodySize", stressCmd.Flags().Lookup("body-size"))
+
+	stressCmd.Flags().Bool("body-random", false, "Fill the generated body with random bytes instead of zeros.")
+	viper.BindPFlag("bodyRandom", stressCmd.Flags().Lookup("body-random"))
+
+	stressCmd.Flags().String("body-encoding", "", "Compress the body with this content encoding: gzip, deflate, br or zstd.")
+	viper.BindPFlag("bodyEncoding", stressCmd.Flags().Lookup("body-encoding"))
+
+	stressCmd.Flags().StringArray("form", nil, "Add a form field to a URL encoded body, eg. 'name=value'. Repeatable.")
+	viper.BindPFlag("form", stressCmd.Flags().Lookup("form"))
+
+	stressCmd.Flags().StringArrayP("multipart", "F", nil, "Add a multipart field, eg. 'name=value', or a file part, eg. 'photo=@cat.png;type=image/png'. Repeatable.")
+	viper.BindPFlag("multipart", stressCmd.Flags().Lookup("multipart"))
+
+	stressCmd.Flags().StringP("headers", "H", "", "Add arbitrary header lines, eg. 'Accept-Encoding:gzip, Content-Type:application/json'")
 	viper.BindPFlag("headers", stressCmd.Flags().Lookup("headers"))
 
+	stressCmd.Flags().StringArray("header", nil, "Add a header, eg. 'Accept: text/html, application/json'. The value can hold commas and colons. Repeatable.")
+	viper.BindPFlag("headerList", stressCmd.Flags().Lookup("header"))
+
 	stressCmd.Flags().String("cookies", "", "Add request cookies, eg. 'data=123; session=456'")
 	viper.BindPFlag("cookies", stressCmd.Flags().Lookup("cookies"))
 
+	stressCmd.Flags().StringArray("cookie", nil, "Add a request cookie, eg. 'session=456'. Repeatable.")
+	viper.BindPFlag("cookieList", stressCmd.Flags().Lookup("cookie"))
+
 	stressCmd.Flags().StringP("user-agent", "A", "pewpew", "Add User-Agent header. Can also be done with the arbitrary header flag.")
 	viper.BindPFlag("userAgent", stressCmd.Flags().Lookup("user-agent"))
 
 	stressCmd.Flags().String("basic-auth", "", "Add HTTP basic authentication, eg. 'user123:password456'.")
 	viper.BindPFlag("basicAuth", stressCmd.Flags().Lookup("basic-auth"))
 
+	stressCmd.Flags().String("basic-auth-user", "", "Add HTTP basic authentication with this user, for credentials with commas.")
+	viper.BindPFlag("basicAuthUser", stressCmd.Flags().Lookup("basic-auth-user"))
+
+	stressCmd.Flags().String("basic-auth-pass", "", "Password of the basic auth user.")
+	viper.BindPFlag("basicAuthPass", stressCmd.Flags().Lookup("basic-auth-pass"))
+
+	stressCmd.Flags().String("bearer-token", "", "Add 'Authorization: Bearer' header with this token.")
+	viper.BindPFlag("bearerToken", stressCmd.Flags().Lookup("bearer-token"))
+
+	stressCmd.Flags().String("digest-auth-user", "", "Answer HTTP Digest auth challenges as this user.")
+	viper.BindPFlag("digestAuthUser", stressCmd.Flags().Lookup("digest-auth-user"))
+
+	stressCmd.Flags().String("digest-auth-pass", "", "Password of the Digest auth user.")
+	viper.BindPFlag("digestAuthPass", stressCmd.Flags().Lookup("digest-auth-pass"))
+
+	stressCmd.Flags().String("oauth2-token-url", "", "Fetch an OAuth2 bearer token from this token endpoint before the test, and again when it expires.")
+	viper.BindPFlag("oauth2TokenURL", stressCmd.Flags().Lookup("oauth2-token-url"))
+
+	stressCmd.Flags().String("oauth2-client-id", "", "OAuth2 client ID.")
+	viper.BindPFlag("oauth2ClientID", stressCmd.Flags().Lookup("oauth2-client-id"))
+
+	stressCmd.Flags().String("oauth2-client-secret", "", "OAuth2 client secret.")
+	viper.BindPFlag("oauth2ClientSecret", stressCmd.Flags().Lookup("oauth2-client-secret"))
+
+	stressCmd.Flags().StringArray("oauth2-scope", nil, "Ask for this OAuth2 scope. Repeatable.")
+	viper.BindPFlag("oauth2Scopes", stressCmd.Flags().Lookup("oauth2-scope"))
+
+	stressCmd.Flags().String("oauth2-username", "", "Use the OAuth2 password grant with this username, instead of client credentials.")
+	viper.BindPFlag("oauth2Username", stressCmd.Flags().Lookup("oauth2-username"))
+
+	stressCmd.Flags().String("oauth2-password", "", "Password of the OAuth2 username.")
+	viper.BindPFlag("oauth2Password", stressCmd.Flags().Lookup("oauth2-password"))
+
+	stressCmd.Flags().String("hmac-key", "", "Sign each request with an HMAC-SHA256 using this key.")
+	viper.BindPFlag("hmacKey", stressCmd.Flags().Lookup("hmac-key"))
+
+	stressCmd.Flags().String("hmac-header", "", "Header of the HMAC signature. Default "+pewpew.DefaultHMACHeader+".")
+	viper.BindPFlag("hmacHeader", stressCmd.Flags().Lookup("hmac-header"))
+
+	stressCmd.Flags().String("hmac-timestamp-header", "", "Header of the HMAC signature's timestamp. Default "+pewpew.DefaultHMACTimestampHeader+".")
+	viper.BindPFlag("hmacTimestampHeader", stressCmd.Flags().Lookup("hmac-timestamp-header"))
+
+	stressCmd.Flags().String("aws-access-key-id", "", "Sign each request with AWS Signature Version 4 using this access key ID.")
+	viper.BindPFlag("awsAccessKeyID", stressCmd.Flags().Lookup("aws-access-key-id"))
+
+	stressCmd.Flags().String("aws-secret-access-key", "", "AWS secret access key.")
+	viper.BindPFlag("awsSecretAccessKey", stressCmd.Flags().Lookup("aws-secret-access-key"))
+
+	stressCmd.Flags().String("aws-session-token", "", "AWS session token, for temporary credentials.")
+	viper.BindPFlag("awsSessionToken", stressCmd.Flags().Lookup("aws-session-token"))
+
+	stressCmd.Flags().String("aws-region", "", "AWS region to sign for, eg. 'us-east-1'.")
+	viper.BindPFlag("awsRegion", stressCmd.Flags().Lookup("aws-region"))
+
+	stressCmd.Flags().String("aws-service", "", "AWS service to sign for, eg. 's3'.")
+	viper.BindPFlag("awsService", stressCmd.Flags().Lookup("aws-service"))
+
 	stressCmd.Flags().BoolP("compress", "C", true, "Add 'Accept-Encoding: gzip' header if Accept-Encoding is not already present.")
 	viper.BindPFlag("compress", stressCmd.Flags().Lookup("compress"))
 
@@ -231,6 +232,66 @@ func init() {
 	stressCmd.Flags().Bool("no-http2", false, "Disable HTTP2.")
 	viper.BindPFlag("noHTTP2", stressCmd.Flags().Lookup("no-http2"))
 
+	stressCmd.Flags().Int("max-conns-per-host", 0, "Maximum connections open to one host. 0 means no limit.")
+	viper.BindPFlag("maxConnsPerHost", stressCmd.Flags().Lookup("max-conns-per-host"))
+
+	stressCmd.Flags().Int("max-idle-conns-per-host", 0, "Maximum idle keep-alive connections kept to one host. 0 means the default of 2.")
+	viper.BindPFlag("maxIdleConnsPerHost", stressCmd.Flags().Lookup("max-idle-conns-per-host"))
+
+	stressCmd.Flags().String("idle-timeout", "", "How long an idle keep-alive connection stays open, eg. '30s'. Empty means no limit.")
+	viper.BindPFlag("idleTimeout", stressCmd.Flags().Lookup("idle-timeout"))
+
+	stressCmd.Flags().Int("connections", 0, "Spread requests over exactly this many keep-alive connections per target.")
+	viper.BindPFlag("connections", stressCmd.Flags().Lookup("connections"))
+
+	stressCmd.Flags().String("source-ips", "", "Local IP addresses to send from, taking turns per connection, eg. '10.0.0.1, 10.0.0.2'.")
+	viper.BindPFlag("sourceIPs", stressCmd.Flags().Lookup("source-ips"))
+
+	stressCmd.Flags().StringArray("resolve", nil, "Connect to this address instead of looking up the host, eg. 'example.com:443:127.0.0.1'. Repeatable.")
+	viper.BindPFlag("resolve", stressCmd.Flags().Lookup("resolve"))
+
+	stressCmd.Flags().Bool("http3", false, "Use HTTP3 (QUIC). URLs without a scheme default to https.")
+	viper.BindPFlag("http3", stressCmd.Flags().Lookup("http3"))
+
+	stressCmd.Flags().Bool("h2c", false, "Use HTTP2 over plain TCP with prior knowledge (h2c).")
+	viper.BindPFlag("h2c", stressCmd.Flags().Lookup("h2c"))
+
+	stressCmd.Flags().StringArray("ws-message", nil, "WebSocket message template to send, repeatable. Messages are sent in turn.")
+	viper.BindPFlag("wsMessages", stressCmd.Flags().Lookup("ws-message"))
+
+	stressCmd.Flags().Int("ws-message-count", 0, "Number of WebSocket messages each virtual user sends before closing. Defaults to each --ws-message once.")
+	viper.BindPFlag("wsMessageCount", stressCmd.Flags().Lookup("ws-message-count"))
+
+	stressCmd.Flags().Float64("ws-message-rate", 0, "WebSocket messages per second each virtual user sends. 0 means as fast as possible.")
+	viper.BindPFlag("wsMessageRate", stressCmd.Flags().Lookup("ws-message-rate"))
+
+	stressCmd.Flags().String("ws-id-field", pewpew.DefaultWSIDField, "JSON field WebSocket replies are matched to their messages by.")
+	viper.BindPFlag("wsIDField", stressCmd.Flags().Lookup("ws-id-field"))
+
+	stressCmd.Flags().Bool("stream", false, "Keep responses open and record their events, for Server-Sent Events and long polling.")
+	viper.BindPFlag("stream", stressCmd.Flags().Lookup("stream"))
+
+	stressCmd.Flags().String("stream-duration", "", "How long each streamed response stays open, eg. '30s'. Empty means until the server ends it.")
+	viper.BindPFlag("streamDuration", stressCmd.Flags().Lookup("stream-duration"))
+
+	stressCmd.Flags().String("graphql-query", "", "GraphQL query to POST as JSON instead of a body.")
+	viper.BindPFlag("query", stressCmd.Flags().Lookup("graphql-query"))
+
+	stressCmd.Flags().String("graphql-variables", "", "GraphQL variables as a JSON object, eg. '{\"id\": {{.Seq}}}'.")
+	viper.BindPFlag("variables", stressCmd.Flags().Lookup("graphql-variables"))
+
+	stressCmd.Flags().String("graphql-operation", "", "GraphQL operation of the query to run.")
+	viper.BindPFlag("operationName", stressCmd.Flags().Lookup("graphql-operation"))
+
+	stressCmd.Flags().String("grpc-method", "", "gRPC method to call, eg. 'package.Service/Method'.")
+	viper.BindPFlag("grpcMethod", stressCmd.Flags().Lookup("grpc-method"))
+
+	stressCmd.Flags().StringArray("grpc-message", nil, "gRPC request message as JSON, repeatable for methods that stream requests.")
+	viper.BindPFlag("grpcMessages", stressCmd.Flags().Lookup("grpc-message"))
+
+	stressCmd.Flags().String("grpc-descriptor-set", "", "Path to a protoc descriptor set describing the gRPC method. Default asks the server's reflection service.")
+	viper.BindPFlag("grpcDescriptorSet", stressCmd.Flags().Lookup("grpc-descriptor-set"))
+
 	stressCmd.Flags().Bool("ignore-ssl", false, "Enfore SSL certificate/hostname correctness.")
 	viper.BindPFlag("enforceSSL", stressCmd.Flags().Lookup("ignore-ssl"))
 
@@ -246,4 +307,445 @@ func init() {
 	stressCmd.Flags().BoolP("verbose", "v", false, "Print extra info for debugging.")
 	viper.BindPFlag("verbose", stressCmd.Flags().Lookup("verbose"))
 
+	//export and plan take the same targets, without the flags for running them
+	stressCmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		switch flag.Name {
+		case "output-json", "output-csv", "quiet", "verbose":
+			return
+		}
+		exportCmd.PersistentFlags().AddFlag(flag)
+		planCmd.Flags().AddFlag(flag)
+	})
+
+	stressCmd.Flags().Bool("dry-run", false, "Print the settings of each target and samples of their requests, like plan, without sending anything.")
+}
+
+//write the summary of each target, when there are several, and then of all of them,
+//with the config's secrets redacted. Returns the stats of all of the targets' requests.
+func printSummary(stressCfg pewpew.StressConfig, targetRequestStats [][]pewpew.RequestStat, w io.Writer) []pewpew.RequestStat {
+	fmt.Fprint(w, "\n----Summary----\n\n")
+
+	//only print individual target data if multiple targets
+	if len(stressCfg.Targets) > 1 {
+		for idx, target := range stressCfg.Targets {
+			//info about the request
+			fmt.Fprintf(w, "----Target %d: %s %s\n", idx+1, target.Method, pewpew.Redact(stressCfg, target.URL))
+			reqStats := pewpew.CreateRequestsStats(targetRequestStats[idx])
+			fmt.Fprintln(w, pewpew.CreateTextSummary(reqStats))
+		}
+	}
+
+	//combine individual targets to a total one
+	globalStats := []pewpew.RequestStat{}
+	for i := range stressCfg.Targets {
+		for j := range targetRequestStats[i] {
+			globalStats = append(globalStats, targetRequestStats[i][j])
+		}
+	}
+	if len(stressCfg.Targets) > 1 {
+		fmt.Fprintln(w, "----Global----")
+	}
+	reqStats := pewpew.CreateRequestsStats(globalStats)
+	fmt.Fprintln(w, pewpew.CreateTextSummary(reqStats))
+	return globalStats
+}
+
+//the config of the stress test, with the global settings cascaded onto the targets that don't set them
+func loadStressConfig(args []string) (stressCfg pewpew.StressConfig, err error) {
+	err = viper.Unmarshal(&stressCfg)
+	if err != nil {
+		fmt.Println(err)
+		return stressCfg, errors.New("could not parse config file")
+	}
+
+	//global configs
+	stressCfg.NoHTTP2 = viper.GetBool("noHTTP2")
+	stressCfg.H2C = viper.GetBool("h2c")
+	stressCfg.EnforceSSL = viper.GetBool("enforceSSL")
+	stressCfg.Quiet = viper.GetBool("quiet")
+	stressCfg.Verbose = viper.GetBool("verbose")
+	stressCfg.Secrets = append(stressCfg.Secrets, configSecrets...)
+
+	//URLs are handled differently that other config options
+	//command line specifying URLs take higher precedence than config URLs
+
+	//requests replayed from an access log take the place of URLs
+	var replayTarget *pewpew.Target
+	if viper.GetString("replay") != "" {
+		if len(args) >= 1 {
+			return stressCfg, errors.New("URLs cannot be combined with --replay")
+		}
+		target, err := readReplayTarget(viper.GetString("replay"))
+		if err != nil {
+			return stressCfg, err
+		}
+		replayTarget = &target
+		args = []string{target.URL}
+	}
+
+	//as does a curl command
+	var curlTarget *pewpew.Target
+	if viper.GetString("curl") != "" {
+		if len(args) >= 1 || replayTarget != nil {
+			return stressCfg, errors.New("URLs and --replay cannot be combined with --curl")
+		}
+		target, err := pewpew.TargetFromCurl(viper.GetString("curl"))
+		if err != nil {
+			return stressCfg, err
+		}
+		curlTarget = &target
+		args = []string{target.URL}
+	}
+
+	//check either set via config or command line
+	if len(stressCfg.Targets) == 0 && len(args) < 1 {
+		return stressCfg, errors.New("requires URL")
+	}
+
+	//if URLs are set on command line, use that for Targets instead of config
+	if len(args) >= 1 {
+		stressCfg.Targets = make([]pewpew.Target, len(args))
+		for i := range stressCfg.Targets {
+			stressCfg.Targets[i].URL = args[i]
+			//use global configs instead of the config file's individual target settings
+			stressCfg.Targets[i].RegexURL = viper.GetBool("regex")
+			stressCfg.Targets[i].Count = viper.GetInt("count")
+			stressCfg.Targets[i].Concurrency = viper.GetInt("concurrency")
+			stressCfg.Targets[i].Timeout = viper.GetString("timeout")
+			stressCfg.Targets[i].Method = viper.GetString("method")
+			stressCfg.Targets[i].Body = viper.GetString("body")
+			stressCfg.Targets[i].BodyFilename = viper.GetString("bodyFile")
+			stressCfg.Targets[i].Form = viper.GetStringSlice("form")
+			stressCfg.Targets[i].Multipart = viper.GetStringSlice("multipart")
+			stressCfg.Targets[i].StreamBodyFile = viper.GetBool("streamBodyFile")
+			stressCfg.Targets[i].BodySize = viper.GetString("bodySize")
+			stressCfg.Targets[i].BodyRandom = viper.GetBool("bodyRandom")
+			stressCfg.Targets[i].BodyEncoding = viper.GetString("bodyEncoding")
+			stressCfg.Targets[i].Headers = viper.GetString("headers")
+			stressCfg.Targets[i].HeaderMap = viper.GetStringMapString("headerMap")
+			stressCfg.Targets[i].HeaderList = viper.GetStringSlice("headerList")
+			stressCfg.Targets[i].Cookies = viper.GetString("cookies")
+			stressCfg.Targets[i].CookieList = viper.GetStringSlice("cookieList")
+			stressCfg.Targets[i].UserAgent = viper.GetString("userAgent")
+			stressCfg.Targets[i].BasicAuth = viper.GetString("basicAuth")
+			stressCfg.Targets[i].BasicAuthUser = viper.GetString("basicAuthUser")
+			stressCfg.Targets[i].BasicAuthPass = viper.GetString("basicAuthPass")
+			stressCfg.Targets[i].BearerToken = viper.GetString("bearerToken")
+			stressCfg.Targets[i].DigestAuthUser = viper.GetString("digestAuthUser")
+			stressCfg.Targets[i].DigestAuthPass = viper.GetString("digestAuthPass")
+			stressCfg.Targets[i].OAuth2TokenURL = viper.GetString("oauth2TokenURL")
+			stressCfg.Targets[i].OAuth2ClientID = viper.GetString("oauth2ClientID")
+			stressCfg.Targets[i].OAuth2ClientSecret = viper.GetString("oauth2ClientSecret")
+			stressCfg.Targets[i].OAuth2Scopes = viper.GetStringSlice("oauth2Scopes")
+			stressCfg.Targets[i].OAuth2Username = viper.GetString("oauth2Username")
+			stressCfg.Targets[i].OAuth2Password = viper.GetString("oauth2Password")
+			stressCfg.Targets[i].HMACKey = viper.GetString("hmacKey")
+			stressCfg.Targets[i].HMACHeader = viper.GetString("hmacHeader")
+			stressCfg.Targets[i].HMACTimestampHeader = viper.GetString("hmacTimestampHeader")
+			stressCfg.Targets[i].AWSAccessKeyID = viper.GetString("awsAccessKeyID")
+			stressCfg.Targets[i].AWSSecretAccessKey = viper.GetString("awsSecretAccessKey")
+			stressCfg.Targets[i].AWSSessionToken = viper.GetString("awsSessionToken")
+			stressCfg.Targets[i].AWSRegion = viper.GetString("awsRegion")
+			stressCfg.Targets[i].AWSService = viper.GetString("awsService")
+			stressCfg.Targets[i].Compress = viper.GetBool("compress")
+			stressCfg.Targets[i].KeepAlive = viper.GetBool("keepalive")
+			stressCfg.Targets[i].FollowRedirects = viper.GetBool("followredirects")
+			stressCfg.Targets[i].HTTP3 = viper.GetBool("http3")
+			stressCfg.Targets[i].MaxConnsPerHost = viper.GetInt("maxConnsPerHost")
+			stressCfg.Targets[i].MaxIdleConnsPerHost = viper.GetInt("maxIdleConnsPerHost")
+			stressCfg.Targets[i].IdleTimeout = viper.GetString("idleTimeout")
+			stressCfg.Targets[i].Connections = viper.GetInt("connections")
+			stressCfg.Targets[i].DialTimeout = viper.GetString("dialTimeout")
+			stressCfg.Targets[i].TLSHandshakeTimeout = viper.GetString("tlsHandshakeTimeout")
+			stressCfg.Targets[i].ResponseHeaderTimeout = viper.GetString("responseHeaderTimeout")
+			stressCfg.Targets[i].SourceIPs = viper.GetString("sourceIPs")
+			stressCfg.Targets[i].Resolve = viper.GetStringSlice("resolve")
+			stressCfg.Targets[i].WSMessages = viper.GetStringSlice("wsMessages")
+			stressCfg.Targets[i].WSMessageCount = viper.GetInt("wsMessageCount")
+			stressCfg.Targets[i].WSMessageRate = viper.GetFloat64("wsMessageRate")
+			stressCfg.Targets[i].WSIDField = viper.GetString("wsIDField")
+			stressCfg.Targets[i].GRPCMethod = viper.GetString("grpcMethod")
+			stressCfg.Targets[i].GRPCMessages = viper.GetStringSlice("grpcMessages")
+			stressCfg.Targets[i].GRPCDescriptorSet = viper.GetString("grpcDescriptorSet")
+			stressCfg.Targets[i].Stream = viper.GetBool("stream")
+			stressCfg.Targets[i].StreamDuration = viper.GetString("streamDuration")
+			stressCfg.Targets[i].Query = viper.GetString("query")
+			stressCfg.Targets[i].Variables = viper.GetString("variables")
+			stressCfg.Targets[i].OperationName = viper.GetString("operationName")
+			//the rest of the global settings still apply to replayed requests,
+			//and --concurrent bounds how many are sent at a time
+			if replayTarget != nil {
+				stressCfg.Targets[i].RegexURL = false
+				stressCfg.Targets[i].Count = replayTarget.Count
+				stressCfg.Targets[i].ReplayRequests = replayTarget.ReplayRequests
+				if stressCfg.Targets[i].Concurrency > replayTarget.Count {
+					stressCfg.Targets[i].Concurrency = replayTarget.Count
+				}
+			}
+			if curlTarget != nil {
+				stressCfg.Targets[i].RegexURL = false
+				applyCurlTarget(&stressCfg.Targets[i], *curlTarget)
+			}
+		}
+	} else {
+		//set non-URL target settings
+		//walk through viper.Get() because that will show which were
+		//explictly set instead of guessing at zero-valued defaults
+		for i, target := range viper.Get("targets").([]interface{}) {
+			//viper lowercases the keys of targets, as it does all keys
+			targetMapVals := make(map[string]interface{})
+			for key, val := range target.(map[string]interface{}) {
+				targetMapVals[strings.ToLower(key)] = val
+			}
+			set := func(setting string) bool {
+				_, ok := targetMapVals[strings.ToLower(setting)]
+				return ok
+			}
+			if !set("RegexURL") {
+				stressCfg.Targets[i].RegexURL = viper.GetBool("regex")
+			}
+			if !set("Count") {
+				stressCfg.Targets[i].Count = viper.GetInt("count")
+			}
+			if !set("Concurrency") {
+				stressCfg.Targets[i].Concurrency = viper.GetInt("concurrency")
+			}
+			if !set("Timeout") {
+				stressCfg.Targets[i].Timeout = viper.GetString("timeout")
+			}
+			if !set("Method") {
+				stressCfg.Targets[i].Method = viper.GetString("method")
+			}
+			if !set("Body") {
+				stressCfg.Targets[i].Body = viper.GetString("body")
+			}
+			if !set("BodyFilename") {
+				stressCfg.Targets[i].BodyFilename = viper.GetString("bodyFile")
+			}
+			if !set("Form") {
+				stressCfg.Targets[i].Form = viper.GetStringSlice("form")
+			}
+			if !set("Multipart") {
+				stressCfg.Targets[i].Multipart = viper.GetStringSlice("multipart")
+			}
+			if !set("StreamBodyFile") {
+				stressCfg.Targets[i].StreamBodyFile = viper.GetBool("streamBodyFile")
+			}
+			if !set("BodySize") {
+				stressCfg.Targets[i].BodySize = viper.GetString("bodySize")
+			}
+			if !set("BodyRandom") {
+				stressCfg.Targets[i].BodyRandom = viper.GetBool("bodyRandom")
+			}
+			if !set("BodyEncoding") {
+				stressCfg.Targets[i].BodyEncoding = viper.GetString("bodyEncoding")
+			}
+			if !set("Headers") {
+				stressCfg.Targets[i].Headers = viper.GetString("headers")
+			}
+			if !set("HeaderMap") {
+				stressCfg.Targets[i].HeaderMap = viper.GetStringMapString("headerMap")
+			}
+			if !set("HeaderList") {
+				stressCfg.Targets[i].HeaderList = viper.GetStringSlice("headerList")
+			}
+			if !set("Cookies") {
+				stressCfg.Targets[i].Cookies = viper.GetString("cookies")
+			}
+			if !set("CookieList") {
+				stressCfg.Targets[i].CookieList = viper.GetStringSlice("cookieList")
+			}
+			if !set("UserAgent") {
+				stressCfg.Targets[i].UserAgent = viper.GetString("userAgent")
+			}
+			if !set("BasicAuth") {
+				stressCfg.Targets[i].BasicAuth = viper.GetString("basicAuth")
+			}
+			if !set("BasicAuthUser") {
+				stressCfg.Targets[i].BasicAuthUser = viper.GetString("basicAuthUser")
+			}
+			if !set("BasicAuthPass") {
+				stressCfg.Targets[i].BasicAuthPass = viper.GetString("basicAuthPass")
+			}
+			if !set("BearerToken") {
+				stressCfg.Targets[i].BearerToken = viper.GetString("bearerToken")
+			}
+			if !set("DigestAuthUser") {
+				stressCfg.Targets[i].DigestAuthUser = viper.GetString("digestAuthUser")
+			}
+			if !set("DigestAuthPass") {
+				stressCfg.Targets[i].DigestAuthPass = viper.GetString("digestAuthPass")
+			}
+			if !set("OAuth2TokenURL") {
+				stressCfg.Targets[i].OAuth2TokenURL = viper.GetString("oauth2TokenURL")
+			}
+			if !set("OAuth2ClientID") {
+				stressCfg.Targets[i].OAuth2ClientID = viper.GetString("oauth2ClientID")
+			}
+			if !set("OAuth2ClientSecret") {
+				stressCfg.Targets[i].OAuth2ClientSecret = viper.GetString("oauth2ClientSecret")
+			}
+			if !set("OAuth2Scopes") {
+				stressCfg.Targets[i].OAuth2Scopes = viper.GetStringSlice("oauth2Scopes")
+			}
+			if !set("OAuth2Username") {
+				stressCfg.Targets[i].OAuth2Username = viper.GetString("oauth2Username")
+			}
+			if !set("OAuth2Password") {
+				stressCfg.Targets[i].OAuth2Password = viper.GetString("oauth2Password")
+			}
+			if !set("HMACKey") {
+				stressCfg.Targets[i].HMACKey = viper.GetString("hmacKey")
+			}
+			if !set("HMACHeader") {
+				stressCfg.Targets[i].HMACHeader = viper.GetString("hmacHeader")
+			}
+			if !set("HMACTimestampHeader") {
+				stressCfg.Targets[i].HMACTimestampHeader = viper.GetString("hmacTimestampHeader")
+			}
+			if !set("AWSAccessKeyID") {
+				stressCfg.Targets[i].AWSAccessKeyID = viper.GetString("awsAccessKeyID")
+			}
+			if !set("AWSSecretAccessKey") {
+				stressCfg.Targets[i].AWSSecretAccessKey = viper.GetString("awsSecretAccessKey")
+			}
+			if !set("AWSSessionToken") {
+				stressCfg.Targets[i].AWSSessionToken = viper.GetString("awsSessionToken")
+			}
+			if !set("AWSRegion") {
+				stressCfg.Targets[i].AWSRegion = viper.GetString("awsRegion")
+			}
+			if !set("AWSService") {
+				stressCfg.Targets[i].AWSService = viper.GetString("awsService")
+			}
+			if !set("Compress") {
+				stressCfg.Targets[i].Compress = viper.GetBool("compress")
+			}
+			if !set("KeepAlive") {
+				stressCfg.Targets[i].KeepAlive = viper.GetBool("keepalive")
+			}
+			if !set("FollowRedirects") {
+				stressCfg.Targets[i].FollowRedirects = viper.GetBool("followredirects")
+			}
+			if !set("HTTP3") {
+				stressCfg.Targets[i].HTTP3 = viper.GetBool("http3")
+			}
+			if !set("MaxConnsPerHost") {
+				stressCfg.Targets[i].MaxConnsPerHost = viper.GetInt("maxConnsPerHost")
+			}
+			if !set("MaxIdleConnsPerHost") {
+				stressCfg.Targets[i].MaxIdleConnsPerHost = viper.GetInt("maxIdleConnsPerHost")
+			}
+			if !set("IdleTimeout") {
+				stressCfg.Targets[i].IdleTimeout = viper.GetString("idleTimeout")
+			}
+			if !set("Connections") {
+				stressCfg.Targets[i].Connections = viper.GetInt("connections")
+			}
+			if !set("DialTimeout") {
+				stressCfg.Targets[i].DialTimeout = viper.GetString("dialTimeout")
+			}
+			if !set("TLSHandshakeTimeout") {
+				stressCfg.Targets[i].TLSHandshakeTimeout = viper.GetString("tlsHandshakeTimeout")
+			}
+			if !set("ResponseHeaderTimeout") {
+				stressCfg.Targets[i].ResponseHeaderTimeout = viper.GetString("responseHeaderTimeout")
+			}
+			if !set("SourceIPs") {
+				stressCfg.Targets[i].SourceIPs = viper.GetString("sourceIPs")
+			}
+			if !set("Resolve") {
+				stressCfg.Targets[i].Resolve = viper.GetStringSlice("resolve")
+			}
+			if !set("WSMessages") {
+				stressCfg.Targets[i].WSMessages = viper.GetStringSlice("wsMessages")
+			}
+			if !set("WSMessageCount") {
+				stressCfg.Targets[i].WSMessageCount = viper.GetInt("wsMessageCount")
+			}
+			if !set("WSMessageRate") {
+				stressCfg.Targets[i].WSMessageRate = viper.GetFloat64("wsMessageRate")
+			}
+			if !set("WSIDField") {
+				stressCfg.Targets[i].WSIDField = viper.GetString("wsIDField")
+			}
+			if !set("GRPCMethod") {
+				stressCfg.Targets[i].GRPCMethod = viper.GetString("grpcMethod")
+			}
+			if !set("GRPCMessages") {
+				stressCfg.Targets[i].GRPCMessages = viper.GetStringSlice("grpcMessages")
+			}
+			if !set("GRPCDescriptorSet") {
+				stressCfg.Targets[i].GRPCDescriptorSet = viper.GetString("grpcDescriptorSet")
+			}
+			if !set("Stream") {
+				stressCfg.Targets[i].Stream = viper.GetBool("stream")
+			}
+			if !set("StreamDuration") {
+				stressCfg.Targets[i].StreamDuration = viper.GetString("streamDuration")
+			}
+			if !set("Query") {
+				stressCfg.Targets[i].Query = viper.GetString("query")
+			}
+			if !set("Variables") {
+				stressCfg.Targets[i].Variables = viper.GetString("variables")
+			}
+			if !set("OperationName") {
+				stressCfg.Targets[i].OperationName = viper.GetString("operationName")
+			}
+		}
+	}
+	return stressCfg, nil
+}
+
+//read the requests of the access log to replay, which may be gzipped like rotated logs
+func readReplayTarget(filename string) (pewpew.Target, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return pewpew.Target{}, errors.New("failed to open access log: " + err.Error())
+	}
+	defer file.Close()
+	var log io.Reader = file
+	if strings.HasSuffix(filename, ".gz") {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return pewpew.Target{}, errors.New("failed to read gzipped access log: " + err.Error())
+		}
+		defer gzipReader.Close()
+		log = gzipReader
+	}
+	if viper.GetFloat64("replaySpeed") != 1 && !viper.GetBool("replayTiming") {
+		return pewpew.Target{}, errors.New("--replay-speed requires --replay-timing")
+	}
+	target, skipped, err := pewpew.TargetFromAccessLog(log, pewpew.AccessLogOptions{
+		Host:       viper.GetString("replayHost"),
+		KeepTiming: viper.GetBool("replayTiming"),
+		Speed:      viper.GetFloat64("replaySpeed"),
+	})
+	if err != nil {
+		return pewpew.Target{}, err
+	}
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "Skipped %d lines of %s that aren't requests\n", skipped, filename)
+	}
+	return target, nil
+}
+
+//set the settings a curl command sets on target, on top of the global ones,
+//adding its headers and cookies to the global ones
+func applyCurlTarget(target *pewpew.Target, curlTarget pewpew.Target) {
+	targetVal := reflect.ValueOf(target).Elem()
+	curlVal := reflect.ValueOf(curlTarget)
+	for i := 0; i < curlVal.NumField(); i++ {
+		field := curlVal.Field(i)
+		if field.IsZero() {
+			continue
+		}
+		switch curlVal.Type().Field(i).Name {
+		case "HeaderList", "CookieList":
+			targetVal.Field(i).Set(reflect.AppendSlice(targetVal.Field(i), field))
+		default:
+			targetVal.Field(i).Set(field)
+		}
+	}
 }
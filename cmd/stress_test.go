@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pewpew "github.com/bengadbois/pewpew/lib"
+	"github.com/spf13/viper"
+)
+
+func TestLoadStressConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pewpew")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	//the first target sets its own Count and Timeout, which win over the global ones the second takes
+	configs := map[string]string{
+		"toml": `Count = 5
+Timeout = "1s"
+
+[[Targets]]
+URL = "http://localhost/a"
+Count = 2
+Timeout = "3s"
+[[Targets]]
+URL = "http://localhost/b"
+`,
+		"json": `{
+    "Count": 5,
+    "Timeout": "1s",
+    "Targets": [
+        {"URL": "http://localhost/a", "Count": 2, "Timeout": "3s"},
+        {"URL": "http://localhost/b"}
+    ]
+}`,
+		"yaml": `Count: 5
+Timeout: 1s
+Targets:
+  - URL: http://localhost/a
+    Count: 2
+    Timeout: 3s
+  - URL: http://localhost/b
+`,
+	}
+	for format, config := range configs {
+		filename := filepath.Join(dir, "config."+format)
+		if err := ioutil.WriteFile(filename, []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		viper.SetConfigFile(filename)
+		if err := viper.ReadInConfig(); err != nil {
+			t.Fatalf("failed to read %s config: %s", format, err)
+		}
+		stressCfg, err := loadStressConfig(nil)
+		if err != nil {
+			t.Errorf("loadStressConfig of %s config err: %s", format, err)
+			continue
+		}
+		if len(stressCfg.Targets) != 2 {
+			t.Errorf("loadStressConfig of %s config made %d targets wanted 2", format, len(stressCfg.Targets))
+			continue
+		}
+		if target := stressCfg.Targets[0]; target.Count != 2 || target.Timeout != "3s" {
+			t.Errorf("loadStressConfig of %s config made target 1 Count %d Timeout %q wanted 2 and 3s", format, target.Count, target.Timeout)
+		}
+		if target := stressCfg.Targets[1]; target.Count != 5 || target.Timeout != "1s" {
+			t.Errorf("loadStressConfig of %s config made target 2 Count %d Timeout %q wanted 5 and 1s", format, target.Count, target.Timeout)
+		}
+	}
+}
+
+func TestPrintSummary(t *testing.T) {
+	stressCfg := pewpew.StressConfig{Secrets: []string{"hunter2"}, Targets: []pewpew.Target{
+		{URL: "http://localhost/a?key=hunter2", Method: "GET"},
+		{URL: "http://localhost/b", Method: "POST"},
+	}}
+	targetRequestStats := [][]pewpew.RequestStat{
+		{{URL: "http://localhost/a?key=hunter2", StatusCode: 200}},
+		{{URL: "http://localhost/b", StatusCode: 201}, {URL: "http://localhost/b", StatusCode: 201}},
+	}
+	var w bytes.Buffer
+	globalStats := printSummary(stressCfg, targetRequestStats, &w)
+	if len(globalStats) != 3 {
+		t.Errorf("printSummary returned %d stats wanted 3", len(globalStats))
+	}
+	summary := w.String()
+	for _, want := range []string{"----Target 1: GET http://localhost/a?key=[REDACTED]\n", "----Target 2: POST http://localhost/b\n", "----Global----\n"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("printSummary is missing %q:\n%s", want, summary)
+		}
+	}
+	if strings.Contains(summary, "hunter2") {
+		t.Errorf("printSummary wrote a secret:\n%s", summary)
+	}
+}
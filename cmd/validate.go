@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	pewpew "github.com/bengadbois/pewpew/lib"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+//config keys read by the command line itself, rather than being settings of the StressConfig
+var commandConfigKeys = []string{"cpu", "regex", "bodyFile", "replay", "replayHost", "replayTiming", "replaySpeed", "curl", "ResultFilenameJSON", "ResultFilenameCSV"}
+
+var validateCmd = &cobra.Command{
+	Use:   "validate [CONFIG]",
+	Short: "Check a config file for problems, without running it",
+	Long: `Check a config file for problems, without running it.
+Every problem is reported with its file, line and target.
+Defaults to the config files stress would use, including each --config.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 1 {
+			//in place of the config files read before, but interpolated like them
+			viper.SetConfigFile(args[0])
+			configSecrets = nil
+			if err := viper.ReadInConfig(); err != nil {
+				return errors.New("failed to read config file: " + err.Error())
+			}
+			if err := mergeConfigFile(args[0]); err != nil {
+				return errors.New("failed to read config file: " + err.Error())
+			}
+		}
+		filenames := configFiles()
+		if len(args) == 1 {
+			filenames = args
+		}
+		if len(filenames) == 0 {
+			return errors.New("no config file found")
+		}
+		files := make([]pewpew.ConfigFile, len(filenames))
+		for i, filename := range filenames {
+			data, err := ioutil.ReadFile(filename)
+			if err != nil {
+				return errors.New("failed to read config file: " + err.Error())
+			}
+			files[i] = pewpew.ConfigFile{Name: filename, Data: data, Format: strings.TrimPrefix(filepath.Ext(filename), ".")}
+		}
+
+		//checked as stress would run it, with the global settings applied to the targets
+		var stressCfg *pewpew.StressConfig
+		var loadErr error
+		if cfg, err := loadStressConfig(nil); err == nil {
+			stressCfg = &cfg
+		} else {
+			loadErr = err
+		}
+		problems, err := pewpew.ValidateConfig(files, stressCfg, commandConfigKeys)
+		if err != nil {
+			return err
+		}
+		if loadErr != nil {
+			problems = append(problems, pewpew.ConfigProblem{Message: loadErr.Error()})
+		}
+		for _, problem := range problems {
+			if problem.File != "" {
+				fmt.Printf("%s:%d: %s\n", problem.File, problem.Line, problem)
+			} else {
+				fmt.Println(problem)
+			}
+		}
+		if len(problems) > 0 {
+			return errors.New(strconv.Itoa(len(problems)) + " problems found")
+		}
+		fmt.Println("No problems found in " + strings.Join(filenames, ", "))
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(validateCmd)
+}
@@ -0,0 +1,289 @@
+package pewpew
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//AccessLogOptions chooses where and how the requests of an access log are replayed
+type AccessLogOptions struct {
+	//Scheme and host to send the requests to, like "https://staging.example.com"
+	Host string
+	//Give each request an Offset, so the requests are sent as far apart as they were logged
+	KeepTiming bool
+	//With KeepTiming, replay this many times faster than logged, like 2 for twice as fast.
+	//Zero means as logged.
+	Speed float64
+}
+
+//ReplayedRequest is a request of an access log, replayed by a Target
+type ReplayedRequest struct {
+	Method string
+	//Path and query, like "/search?q=pew"
+	URI       string
+	Referer   string
+	UserAgent string
+	//How long after the target starts to send the request. Zero means as soon as a worker is free.
+	Offset time.Duration
+}
+
+//context key for the Offset of a replayed request
+type replayOffsetKey struct{}
+
+//a request read from an access log
+type accessLogEntry struct {
+	time      time.Time
+	method    string
+	uri       string
+	referer   string
+	userAgent string
+}
+
+//the combined log format of nginx and Apache, whose referer and user agent are left out by the common log format:
+//127.0.0.1 - user [10/Oct/2000:13:55:36 -0700] "GET /index.html HTTP/1.0" 200 2326 "http://referer/" "Mozilla/5.0"
+var combinedLogRegexp = regexp.MustCompile(`^\S+ \S+ \S+ \[([^\]]+)\] "((?:[^"\\]|\\.)*)" \S+ \S+(?: "((?:[^"\\]|\\.)*)" "((?:[^"\\]|\\.)*)")?`)
+
+//time format of the combined log format
+const accessLogTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+//names the fields of JSON access logs commonly go by, in order of preference
+var (
+	jsonLogTimeFields      = []string{"time_iso8601", "time_local", "timestamp", "@timestamp", "time", "msec"}
+	jsonLogMethodFields    = []string{"request_method", "method"}
+	jsonLogURIFields       = []string{"request_uri", "uri", "path", "url"}
+	jsonLogQueryFields     = []string{"args", "query_string", "query"}
+	jsonLogRefererFields   = []string{"http_referer", "referer", "referrer"}
+	jsonLogUserAgentFields = []string{"http_user_agent", "user_agent", "useragent"}
+)
+
+//TargetFromAccessLog turns the requests of an nginx or Apache access log into a Target replaying each of them
+//once to opts.Host, in the order they were logged. Lines can be in the combined or common log format,
+//or JSON objects with nginx's variable names, like "request" or "request_method" and "request_uri".
+//Access logs don't hold request bodies, so requests are sent without one.
+//Lines that aren't requests are skipped, and counted in skipped.
+//The Target sends one request at a time, and its Concurrency can be raised to send more.
+func TargetFromAccessLog(r io.Reader, opts AccessLogOptions) (target Target, skipped int, err error) {
+	host, err := url.Parse(opts.Host)
+	if err != nil || (host.Scheme != "http" && host.Scheme != "https") || host.Host == "" {
+		return Target{}, 0, errors.New("replay host must be like https://example.com: " + opts.Host)
+	}
+	if opts.Speed < 0 {
+		return Target{}, 0, errors.New("replay speed cannot be negative")
+	}
+	speed := opts.Speed
+	if speed == 0 {
+		speed = 1
+	}
+
+	var entries []accessLogEntry
+	scanner := bufio.NewScanner(r)
+	//long query strings are common in access logs
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry accessLogEntry
+		var ok bool
+		if strings.HasPrefix(line, "{") {
+			entry, ok = parseJSONLogLine(line)
+		} else {
+			entry, ok = parseCombinedLogLine(line)
+		}
+		if !ok {
+			skipped++
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return Target{}, 0, errors.New("failed to read access log: " + err.Error())
+	}
+	if len(entries) == 0 {
+		return Target{}, skipped, errors.New("no requests in access log to replay")
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].time.Before(entries[j].time)
+	})
+
+	requests := make([]ReplayedRequest, len(entries))
+	for i, entry := range entries {
+		requests[i] = ReplayedRequest{
+			Method:    entry.method,
+			URI:       entry.uri,
+			Referer:   entry.referer,
+			UserAgent: entry.userAgent,
+		}
+		if opts.KeepTiming {
+			if offset := time.Duration(float64(entry.time.Sub(entries[0].time)) / speed); offset > 0 {
+				requests[i].Offset = offset
+			}
+		}
+	}
+	target = Target{
+		URL:            strings.TrimSuffix(opts.Host, "/"),
+		Method:         "GET",
+		Count:          len(requests),
+		Concurrency:    1,
+		ReplayRequests: requests,
+	}
+	return target, skipped, nil
+}
+
+//ReplayedTargets is what each of t's ReplayRequests sends, as a Target sending it once, in order.
+//A target that doesn't replay requests is returned as is.
+func ReplayedTargets(t Target) []Target {
+	if len(t.ReplayRequests) == 0 {
+		return []Target{t}
+	}
+	targets := make([]Target, len(t.ReplayRequests))
+	for i, r := range t.ReplayRequests {
+		targets[i] = replayedTarget(t, r)
+	}
+	return targets
+}
+
+//the settings of t with the method, URI and headers of a request it replays, sending it once
+func replayedTarget(t Target, r ReplayedRequest) Target {
+	replayed := t
+	replayed.ReplayRequests = nil
+	replayed.URL = strings.TrimSuffix(t.URL, "/") + r.URI
+	replayed.Method = r.Method
+	replayed.Count = 1
+	replayed.Concurrency = 1
+	if r.UserAgent != "" {
+		replayed.UserAgent = r.UserAgent
+	}
+	if r.Referer != "" {
+		replayed.HeaderList = append(append([]string(nil), t.HeaderList...), "Referer: "+r.Referer)
+	}
+	return replayed
+}
+
+//parse a line in the combined or common log format
+func parseCombinedLogLine(line string) (accessLogEntry, bool) {
+	match := combinedLogRegexp.FindStringSubmatch(line)
+	if match == nil {
+		return accessLogEntry{}, false
+	}
+	logTime, err := time.Parse(accessLogTimeFormat, match[1])
+	if err != nil {
+		return accessLogEntry{}, false
+	}
+	entry := accessLogEntry{time: logTime, referer: logField(match[3]), userAgent: logField(match[4])}
+	entry.method, entry.uri, err = parseRequestLine(match[2])
+	if err != nil {
+		return accessLogEntry{}, false
+	}
+	return entry, true
+}
+
+//parse a line of a JSON access log
+func parseJSONLogLine(line string) (accessLogEntry, bool) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return accessLogEntry{}, false
+	}
+	var entry accessLogEntry
+	var err error
+	if request := jsonLogField(fields, []string{"request"}); request != "" {
+		entry.method, entry.uri, err = parseRequestLine(request)
+		if err != nil {
+			return accessLogEntry{}, false
+		}
+	} else {
+		entry.method = jsonLogField(fields, jsonLogMethodFields)
+		entry.uri = jsonLogField(fields, jsonLogURIFields)
+		//the URI may be the path alone, like nginx's $uri
+		if query := jsonLogField(fields, jsonLogQueryFields); query != "" && !strings.Contains(entry.uri, "?") {
+			entry.uri += "?" + query
+		}
+		if entry.method == "" || !strings.HasPrefix(entry.uri, "/") {
+			return accessLogEntry{}, false
+		}
+	}
+	entry.time, err = parseLogTime(fields, jsonLogTimeFields)
+	if err != nil {
+		return accessLogEntry{}, false
+	}
+	entry.referer = jsonLogField(fields, jsonLogRefererFields)
+	entry.userAgent = jsonLogField(fields, jsonLogUserAgentFields)
+	return entry, true
+}
+
+//split a request line like "GET /index.html HTTP/1.1" into its method and URI
+func parseRequestLine(request string) (method, uri string, err error) {
+	parts := strings.Fields(request)
+	if len(parts) < 2 || len(parts) > 3 || !strings.HasPrefix(parts[1], "/") {
+		return "", "", errors.New("not a request line: " + request)
+	}
+	for _, c := range parts[0] {
+		if c < 'A' || c > 'Z' {
+			return "", "", errors.New("not a request method: " + parts[0])
+		}
+	}
+	return parts[0], parts[1], nil
+}
+
+//the first of names set in fields, as a string, with "-" meaning unset like in text logs
+func jsonLogField(fields map[string]interface{}, names []string) string {
+	for _, name := range names {
+		switch value := fields[name].(type) {
+		case string:
+			if field := logField(value); field != "" {
+				return field
+			}
+		case float64:
+			return strconv.FormatFloat(value, 'f', -1, 64)
+		}
+	}
+	return ""
+}
+
+//the time of a JSON log line, which can be in RFC 3339, the combined log format's, or Unix seconds
+func parseLogTime(fields map[string]interface{}, names []string) (time.Time, error) {
+	value := jsonLogField(fields, names)
+	if value == "" {
+		return time.Time{}, errors.New("no time")
+	}
+	if logTime, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return logTime, nil
+	}
+	if logTime, err := time.Parse(accessLogTimeFormat, value); err == nil {
+		return logTime, nil
+	}
+	//parsed apart from the fraction, which a float64 can't hold to the nanosecond
+	whole, fraction := value, ""
+	if dot := strings.Index(value, "."); dot != -1 {
+		whole, fraction = value[:dot], value[dot+1:]
+	}
+	seconds, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || len(fraction) > 9 {
+		return time.Time{}, errors.New("failed to parse time: " + value)
+	}
+	var nanos int64
+	if fraction != "" {
+		nanos, err = strconv.ParseInt(fraction+strings.Repeat("0", 9-len(fraction)), 10, 64)
+		if err != nil {
+			return time.Time{}, errors.New("failed to parse time: " + value)
+		}
+	}
+	return time.Unix(seconds, nanos), nil
+}
+
+//a field of a log line, empty when logged as "-"
+func logField(value string) string {
+	if value == "-" {
+		return ""
+	}
+	return value
+}
@@ -0,0 +1,134 @@
+package pewpew
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTargetFromAccessLog(t *testing.T) {
+	combinedLog := `203.0.113.9 - - [01/May/2024:10:00:02 +0000] "POST /api/orders HTTP/1.1" 201 12 "-" "curl/8.0"
+203.0.113.7 - alice [01/May/2024:10:00:00 +0000] "GET /search?q=pew+pew HTTP/1.1" 200 2326 "https://example.com/" "Mozilla/5.0 (X11)"
+
+203.0.113.8 - - [01/May/2024:10:00:01 +0000] "\x16\x03\x01" 400 150 "-" "-"
+203.0.113.8 - - [01/May/2024:10:00:01 +0000] "GET /health HTTP/1.0" 200 2
+not a log line
+`
+	jsonLog := `{"time_iso8601": "2024-05-01T10:00:00+00:00", "request": "GET /a?b=c HTTP/2.0", "status": 200, "http_user_agent": "Mozilla/5.0", "http_referer": "-"}
+{"msec": 1714557600.25, "request_method": "DELETE", "uri": "/items/1", "args": "force=true", "http_referer": "https://example.com/items"}
+{"timestamp": "01/May/2024:10:00:01 +0000", "method": "PUT", "path": "/items/2"}
+{"request": "-", "time_iso8601": "2024-05-01T10:00:00+00:00"}
+{"method": "GET", "uri": "/no-time"}
+`
+	search := ReplayedRequest{Method: "GET", URI: "/search?q=pew+pew", Referer: "https://example.com/", UserAgent: "Mozilla/5.0 (X11)"}
+	health := ReplayedRequest{Method: "GET", URI: "/health"}
+	orders := ReplayedRequest{Method: "POST", URI: "/api/orders", UserAgent: "curl/8.0"}
+	at := func(r ReplayedRequest, offset time.Duration) ReplayedRequest {
+		r.Offset = offset
+		return r
+	}
+	replay := func(host string, requests ...ReplayedRequest) Target {
+		return Target{URL: host, Method: "GET", Count: len(requests), Concurrency: 1, ReplayRequests: requests}
+	}
+	cases := []struct {
+		log         string
+		opts        AccessLogOptions
+		want        Target
+		wantSkipped int
+		wantErr     bool
+	}{
+		{combinedLog, AccessLogOptions{Host: "https://staging.example.com"}, replay("https://staging.example.com", search, health, orders), 2, false},
+		{combinedLog, AccessLogOptions{Host: "https://staging.example.com/", KeepTiming: true},
+			replay("https://staging.example.com", search, at(health, time.Second), at(orders, 2*time.Second)), 2, false},
+		{combinedLog, AccessLogOptions{Host: "https://staging.example.com", KeepTiming: true, Speed: 4},
+			replay("https://staging.example.com", search, at(health, 250*time.Millisecond), at(orders, 500*time.Millisecond)), 2, false},
+		{jsonLog, AccessLogOptions{Host: "http://localhost:8080", KeepTiming: true}, replay("http://localhost:8080",
+			ReplayedRequest{Method: "GET", URI: "/a?b=c", UserAgent: "Mozilla/5.0"},
+			ReplayedRequest{Method: "DELETE", URI: "/items/1?force=true", Referer: "https://example.com/items", Offset: 250 * time.Millisecond},
+			ReplayedRequest{Method: "PUT", URI: "/items/2", Offset: time.Second},
+		), 2, false},
+		{"not a log line\n", AccessLogOptions{Host: "https://staging.example.com"}, Target{}, 1, true},
+		{combinedLog, AccessLogOptions{Host: "staging.example.com"}, Target{}, 0, true},
+		{combinedLog, AccessLogOptions{Host: "https://staging.example.com", Speed: -1}, Target{}, 0, true},
+	}
+	for _, c := range cases {
+		got, skipped, err := TargetFromAccessLog(strings.NewReader(c.log), c.opts)
+		if (err != nil) != c.wantErr {
+			t.Errorf("TargetFromAccessLog(%+v) err: %v wanted error: %t", c.opts, err, c.wantErr)
+			continue
+		}
+		if skipped != c.wantSkipped {
+			t.Errorf("TargetFromAccessLog(%+v) skipped %d lines wanted %d", c.opts, skipped, c.wantSkipped)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("TargetFromAccessLog(%+v) == %+v wanted %+v", c.opts, got, c.want)
+		}
+	}
+}
+
+func TestReplayedTargets(t *testing.T) {
+	target := Target{URL: "https://staging.example.com/", Method: "GET", Count: 2, Concurrency: 2, HeaderList: []string{"X-Test: 1"},
+		UserAgent: "pewpew", ReplayRequests: []ReplayedRequest{
+			{Method: "GET", URI: "/search?q=pew", Referer: "https://example.com/"},
+			{Method: "POST", URI: "/api/orders", UserAgent: "curl/8.0", Offset: time.Second},
+		}}
+	want := []Target{
+		{URL: "https://staging.example.com/search?q=pew", Method: "GET", Count: 1, Concurrency: 1,
+			HeaderList: []string{"X-Test: 1", "Referer: https://example.com/"}, UserAgent: "pewpew"},
+		{URL: "https://staging.example.com/api/orders", Method: "POST", Count: 1, Concurrency: 1,
+			HeaderList: []string{"X-Test: 1"}, UserAgent: "curl/8.0"},
+	}
+	if got := ReplayedTargets(target); !reflect.DeepEqual(got, want) {
+		t.Errorf("ReplayedTargets == %+v wanted %+v", got, want)
+	}
+	//the target's own headers aren't changed
+	if len(target.HeaderList) != 1 {
+		t.Errorf("ReplayedTargets changed the target's headers to %q", target.HeaderList)
+	}
+	plain := Target{URL: "https://example.com", Method: "GET", Count: 3, Concurrency: 1}
+	if got := ReplayedTargets(plain); !reflect.DeepEqual(got, []Target{plain}) {
+		t.Errorf("ReplayedTargets of a target without replayed requests == %+v", got)
+	}
+}
+
+func TestParseRequestLine(t *testing.T) {
+	cases := []struct {
+		request string
+		method  string
+		uri     string
+		wantErr bool
+	}{
+		{"GET / HTTP/1.1", "GET", "/", false},
+		{"OPTIONS /a?b=c", "OPTIONS", "/a?b=c", false},
+		{"-", "", "", true},
+		{"get / HTTP/1.1", "", "", true},
+		{"GET http://example.com/ HTTP/1.1", "", "", true},
+		{"GET / HTTP/1.1 extra", "", "", true},
+	}
+	for _, c := range cases {
+		method, uri, err := parseRequestLine(c.request)
+		if (err != nil) != c.wantErr || method != c.method || uri != c.uri {
+			t.Errorf("parseRequestLine(%q) == %q, %q, %v wanted %q, %q, error: %t", c.request, method, uri, err, c.method, c.uri, c.wantErr)
+		}
+	}
+}
+
+func TestParseLogTime(t *testing.T) {
+	want := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	cases := []map[string]interface{}{
+		{"time": "2024-05-01T10:00:00Z"},
+		{"time_local": "01/May/2024:12:00:00 +0200"},
+		{"msec": 1714557600.0},
+		{"@timestamp": "1714557600"},
+	}
+	for _, fields := range cases {
+		got, err := parseLogTime(fields, jsonLogTimeFields)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("parseLogTime(%v) == %s, %v wanted %s", fields, got, err, want)
+		}
+	}
+	if _, err := parseLogTime(map[string]interface{}{"time": "yesterday"}, jsonLogTimeFields); err == nil {
+		t.Errorf("parseLogTime of a bad time succeeded")
+	}
+}
@@ -0,0 +1,300 @@
+package pewpew
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//requestBody is the body of a target's requests, loaded once and shared by all of them
+type requestBody struct {
+	//held in memory
+	data []byte
+	//streamed from disk as each request is sent
+	filename string
+	//generated zero bytes
+	zeroSize int64
+	//set when the body has a type of its own, like a form
+	contentType string
+	//set when the body has been compressed
+	contentEncoding string
+}
+
+//load the body of the target's requests, compressed with its BodyEncoding, nil if they have none
+func loadBody(t Target) (*requestBody, error) {
+	body, err := loadUnencodedBody(t)
+	if err != nil || body == nil || t.BodyEncoding == "" {
+		return body, err
+	}
+	reader, _, err := body.reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	encoded, err := encodeBody(t.BodyEncoding, reader)
+	if err != nil {
+		return nil, err
+	}
+	return &requestBody{data: encoded, contentType: body.contentType, contentEncoding: t.BodyEncoding}, nil
+}
+
+func loadUnencodedBody(t Target) (*requestBody, error) {
+	switch {
+	case t.BodyFilename != "" && t.StreamBodyFile:
+		//make sure the file can be read before the test starts
+		file, err := os.Open(t.BodyFilename)
+		if err != nil {
+			return nil, errors.New("failed to open file " + t.BodyFilename + ": " + err.Error())
+		}
+		file.Close()
+		return &requestBody{filename: t.BodyFilename}, nil
+	case t.BodyFilename != "":
+		fileContents, err := ioutil.ReadFile(t.BodyFilename)
+		if err != nil {
+			return nil, errors.New("failed to read contents of file " + t.BodyFilename + ": " + err.Error())
+		}
+		return &requestBody{data: fileContents}, nil
+	case t.Body != "":
+		return &requestBody{data: []byte(t.Body)}, nil
+	case len(t.Form) > 0:
+		fields, err := parseFormFields(t.Form)
+		if err != nil {
+			return nil, err
+		}
+		return &requestBody{data: buildFormBody(fields), contentType: "application/x-www-form-urlencoded"}, nil
+	case len(t.Multipart) > 0:
+		fields, err := parseMultipartFields(t.Multipart)
+		if err != nil {
+			return nil, err
+		}
+		body, contentType, err := buildMultipartBody(fields)
+		if err != nil {
+			return nil, err
+		}
+		return &requestBody{data: body, contentType: contentType}, nil
+	case t.BodySize != "":
+		size, err := parseByteSize(t.BodySize)
+		if err != nil {
+			return nil, err
+		}
+		if !t.BodyRandom {
+			return &requestBody{zeroSize: size}, nil
+		}
+		data := make([]byte, size)
+		rand.New(rand.NewSource(time.Now().UnixNano())).Read(data)
+		return &requestBody{data: data}, nil
+	}
+	return nil, nil
+}
+
+//a new reader of the body for one request, and its length
+func (b *requestBody) reader() (io.ReadCloser, int64, error) {
+	if b.filename != "" {
+		info, err := os.Stat(b.filename)
+		if err != nil {
+			return nil, 0, errors.New("failed to open file " + b.filename + ": " + err.Error())
+		}
+		if info.Size() == 0 {
+			return http.NoBody, 0, nil
+		}
+		return &lazyFile{filename: b.filename}, info.Size(), nil
+	}
+	if b.zeroSize > 0 {
+		return ioutil.NopCloser(io.LimitReader(zeroReader{}, b.zeroSize)), b.zeroSize, nil
+	}
+	//a zero length with a body would be sent as unknown length
+	if len(b.data) == 0 {
+		return http.NoBody, 0, nil
+	}
+	return ioutil.NopCloser(bytes.NewReader(b.data)), int64(len(b.data)), nil
+}
+
+//set the body on req, so that it can be sent again on redirects and retries
+func (b *requestBody) setOn(req *http.Request) error {
+	body, length, err := b.reader()
+	if err != nil {
+		return err
+	}
+	req.Body = body
+	req.ContentLength = length
+	req.GetBody = func() (io.ReadCloser, error) {
+		body, _, err := b.reader()
+		return body, err
+	}
+	if b.contentType != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", b.contentType)
+	}
+	if b.contentEncoding != "" {
+		req.Header.Set("Content-Encoding", b.contentEncoding)
+	}
+	return nil
+}
+
+//lazyFile opens its file when first read, so queued requests don't hold a file open each
+type lazyFile struct {
+	filename string
+	file     *os.File
+}
+
+func (f *lazyFile) Read(p []byte) (int, error) {
+	if f.file == nil {
+		file, err := os.Open(f.filename)
+		if err != nil {
+			return 0, err
+		}
+		f.file = file
+	}
+	return f.file.Read(p)
+}
+
+func (f *lazyFile) Close() error {
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Close()
+}
+
+//zeroReader reads endless zero bytes
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+//parse a size like "1024", "512KB", "50MB" or "1GB", where a KB is 1024 bytes
+func parseByteSize(sizeStr string) (int64, error) {
+	units := []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	}
+	numberStr := strings.ToUpper(strings.TrimSpace(sizeStr))
+	multiplier := int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(numberStr, unit.suffix) {
+			numberStr = strings.TrimSpace(strings.TrimSuffix(numberStr, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+	number, err := strconv.ParseInt(numberStr, 10, 64)
+	if err != nil || number <= 0 {
+		return 0, errors.New("failed to parse body size: " + sizeStr)
+	}
+	return number * multiplier, nil
+}
+
+//one field of a Form or Multipart body
+type bodyField struct {
+	name  string
+	value string
+	//for file parts of a Multipart body, the path of the file whose contents are the value
+	filename    string
+	contentType string
+}
+
+//parse "name=value" pairs, keeping their order and any repeated names
+func parseFormFields(fields []string) ([]bodyField, error) {
+	var parsed []bodyField
+	for _, field := range fields {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, errors.New("form field must be like name=value: " + field)
+		}
+		parsed = append(parsed, bodyField{name: strings.TrimSpace(parts[0]), value: parts[1]})
+	}
+	return parsed, nil
+}
+
+//parse multipart fields like curl's -F: "name=value" for a field,
+//or "name=@path" for a file part, optionally followed by ";type=content/type"
+func parseMultipartFields(fields []string) ([]bodyField, error) {
+	parsed, err := parseFormFields(fields)
+	if err != nil {
+		return nil, errors.New("multipart " + err.Error())
+	}
+	for i, field := range parsed {
+		if !strings.HasPrefix(field.value, "@") {
+			continue
+		}
+		filename := strings.TrimPrefix(field.value, "@")
+		if idx := strings.LastIndex(filename, ";type="); idx != -1 {
+			parsed[i].contentType = filename[idx+len(";type="):]
+			filename = filename[:idx]
+		}
+		if filename == "" {
+			return nil, errors.New("multipart file part has no path: " + fields[i])
+		}
+		parsed[i].filename = filename
+		parsed[i].value = ""
+		if parsed[i].contentType == "" {
+			parsed[i].contentType = mime.TypeByExtension(filepath.Ext(filename))
+		}
+		if parsed[i].contentType == "" {
+			parsed[i].contentType = "application/octet-stream"
+		}
+	}
+	return parsed, nil
+}
+
+//encode fields as application/x-www-form-urlencoded, in the order given
+func buildFormBody(fields []bodyField) []byte {
+	pairs := make([]string, len(fields))
+	for i, field := range fields {
+		pairs[i] = url.QueryEscape(field.name) + "=" + url.QueryEscape(field.value)
+	}
+	return []byte(strings.Join(pairs, "&"))
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+//encode fields as multipart/form-data, reading file parts from disk.
+//Returns the body and its Content-Type, which holds the boundary.
+func buildMultipartBody(fields []bodyField) ([]byte, string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, field := range fields {
+		if field.filename == "" {
+			if err := writer.WriteField(field.name, field.value); err != nil {
+				return nil, "", errors.New("failed to write multipart field " + field.name + ": " + err.Error())
+			}
+			continue
+		}
+		fileContents, err := ioutil.ReadFile(field.filename)
+		if err != nil {
+			return nil, "", errors.New("failed to read contents of file " + field.filename + ": " + err.Error())
+		}
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", `form-data; name="`+quoteEscaper.Replace(field.name)+
+			`"; filename="`+quoteEscaper.Replace(filepath.Base(field.filename))+`"`)
+		header.Set("Content-Type", field.contentType)
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, "", errors.New("failed to write multipart file " + field.filename + ": " + err.Error())
+		}
+		part.Write(fileContents)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", errors.New("failed to write multipart body: " + err.Error())
+	}
+	return body.Bytes(), writer.FormDataContentType(), nil
+}
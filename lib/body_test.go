@@ -0,0 +1,319 @@
+package pewpew
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestParseFormFields(t *testing.T) {
+	cases := []struct {
+		fields []string
+		want   []bodyField
+		hasErr bool
+	}{
+		{nil, nil, false},
+		{[]string{"a=1", "b = two words", "a=", "c=x=y"},
+			[]bodyField{{name: "a", value: "1"}, {name: "b", value: " two words"}, {name: "a", value: ""}, {name: "c", value: "x=y"}}, false},
+		{[]string{"a"}, nil, true},
+		{[]string{"=1"}, nil, true},
+	}
+	for _, c := range cases {
+		fields, err := parseFormFields(c.fields)
+		if (err != nil) != c.hasErr {
+			t.Errorf("parseFormFields(%q) err: %t wanted %t", c.fields, (err != nil), c.hasErr)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(fields, c.want) {
+			t.Errorf("parseFormFields(%q) == %+v wanted %+v", c.fields, fields, c.want)
+		}
+	}
+}
+
+func TestParseMultipartFields(t *testing.T) {
+	cases := []struct {
+		fields []string
+		want   []bodyField
+		hasErr bool
+	}{
+		{[]string{"name=pewpew"}, []bodyField{{name: "name", value: "pewpew"}}, false},
+		{[]string{"photo=@/tmp/cat.png"}, []bodyField{{name: "photo", filename: "/tmp/cat.png", contentType: "image/png"}}, false},
+		{[]string{"doc=@/tmp/notes;type=text/markdown"}, []bodyField{{name: "doc", filename: "/tmp/notes", contentType: "text/markdown"}}, false},
+		{[]string{"blob=@/tmp/blob"}, []bodyField{{name: "blob", filename: "/tmp/blob", contentType: "application/octet-stream"}}, false},
+		{[]string{"empty=@"}, nil, true},
+		{[]string{"noequals"}, nil, true},
+	}
+	for _, c := range cases {
+		fields, err := parseMultipartFields(c.fields)
+		if (err != nil) != c.hasErr {
+			t.Errorf("parseMultipartFields(%q) err: %t wanted %t", c.fields, (err != nil), c.hasErr)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(fields, c.want) {
+			t.Errorf("parseMultipartFields(%q) == %+v wanted %+v", c.fields, fields, c.want)
+		}
+	}
+}
+
+func TestBuildFormBody(t *testing.T) {
+	fields := []bodyField{{name: "b", value: "two words"}, {name: "a", value: "x&y=z"}, {name: "b", value: "2"}}
+	want := "b=two+words&a=x%26y%3Dz&b=2"
+	if body := string(buildFormBody(fields)); body != want {
+		t.Errorf("buildFormBody(%+v) == %q wanted %q", fields, body, want)
+	}
+}
+
+func TestBuildMultipartBody(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pewpew")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, `my "file".txt`)
+	if err := ioutil.WriteFile(filename, []byte("file contents"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fields := []bodyField{
+		{name: "name", value: "pewpew"},
+		{name: "upload", filename: filename, contentType: "text/plain"},
+	}
+	body, contentType, err := buildMultipartBody(fields)
+	if err != nil {
+		t.Fatalf("buildMultipartBody(%+v) err: %s", fields, err)
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "multipart/form-data" {
+		t.Fatalf("buildMultipartBody(%+v) Content-Type == %q", fields, contentType)
+	}
+	form, err := multipart.NewReader(bytes.NewReader(body), params["boundary"]).ReadForm(1024)
+	if err != nil {
+		t.Fatalf("buildMultipartBody(%+v) body does not match its boundary: %s", fields, err)
+	}
+	if form.Value["name"][0] != "pewpew" {
+		t.Errorf("multipart field name == %q wanted %q", form.Value["name"][0], "pewpew")
+	}
+	file := form.File["upload"][0]
+	if file.Filename != `my "file".txt` || file.Header.Get("Content-Type") != "text/plain" || file.Size != int64(len("file contents")) {
+		t.Errorf("multipart file part == %q %q %d bytes", file.Filename, file.Header.Get("Content-Type"), file.Size)
+	}
+
+	if _, _, err := buildMultipartBody([]bodyField{{name: "upload", filename: filepath.Join(dir, "missing")}}); err == nil {
+		t.Errorf("buildMultipartBody with a missing file succeeded, wanted an error")
+	}
+}
+
+func TestRunStressForm(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pewpew")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "upload.json")
+	if err := ioutil.WriteFile(filename, []byte(`{"a": 1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	//accepted when the fields arrive with their values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1024); err != nil && err != http.ErrNotMultipart {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.PostForm.Get("name") != "pewpew" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.MultipartForm != nil {
+			if files := r.MultipartForm.File["upload"]; len(files) != 1 || files[0].Header.Get("Content-Type") != "application/json" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	targets := []Target{
+		{Form: []string{"name=pewpew", "tag=a", "tag=b"}},
+		{Multipart: []string{"name=pewpew", "upload=@" + filename}},
+	}
+	for _, target := range targets {
+		target.URL = server.URL
+		target.Method = "POST"
+		target.Count = 2
+		target.Concurrency = 1
+		targetStats, err := RunStress(StressConfig{Targets: []Target{target}, Quiet: true}, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("RunStress(%+v) err: %s", target, err)
+		}
+		for _, stat := range targetStats[0] {
+			if stat.StatusCode != http.StatusOK {
+				t.Errorf("request of %+v status code == %d wanted %d", target, stat.StatusCode, http.StatusOK)
+			}
+		}
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	cases := []struct {
+		size   string
+		want   int64
+		hasErr bool
+	}{
+		{"1024", 1024, false},
+		{"10B", 10, false},
+		{"512KB", 512 * 1024, false},
+		{"50mb", 50 * 1024 * 1024, false},
+		{" 2 GB ", 2 * 1024 * 1024 * 1024, false},
+		{"", 0, true},
+		{"0", 0, true},
+		{"-5KB", 0, true},
+		{"1.5MB", 0, true},
+		{"lots", 0, true},
+	}
+	for _, c := range cases {
+		size, err := parseByteSize(c.size)
+		if (err != nil) != c.hasErr {
+			t.Errorf("parseByteSize(%q) err: %t wanted %t", c.size, (err != nil), c.hasErr)
+			continue
+		}
+		if size != c.want {
+			t.Errorf("parseByteSize(%q) == %d wanted %d", c.size, size, c.want)
+		}
+	}
+}
+
+func TestLoadBody(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pewpew")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "body")
+	if err := ioutil.WriteFile(filename, []byte("file body"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		target Target
+		want   []byte
+		hasErr bool
+	}{
+		{Target{}, nil, false},
+		{Target{Body: "body"}, []byte("body"), false},
+		{Target{BodyFilename: filename}, []byte("file body"), false},
+		{Target{BodyFilename: filename, StreamBodyFile: true}, []byte("file body"), false},
+		{Target{BodyFilename: tempFilename, StreamBodyFile: true}, []byte{}, false}, //empty file
+		{Target{BodySize: "4B"}, []byte{0, 0, 0, 0}, false},
+		{Target{Form: []string{"a=1"}}, []byte("a=1"), false},
+		{Target{BodyFilename: filepath.Join(dir, "missing")}, nil, true},
+		{Target{BodyFilename: filepath.Join(dir, "missing"), StreamBodyFile: true}, nil, true},
+		{Target{BodySize: "lots"}, nil, true},
+	}
+	for _, c := range cases {
+		body, err := loadBody(c.target)
+		if (err != nil) != c.hasErr {
+			t.Errorf("loadBody(%+v) err: %t wanted %t", c.target, (err != nil), c.hasErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if body == nil {
+			if c.want != nil {
+				t.Errorf("loadBody(%+v) has no body wanted %q", c.target, c.want)
+			}
+			continue
+		}
+		//every request reads the same body, in full
+		for i := 0; i < 2; i++ {
+			reader, length, err := body.reader()
+			if err != nil {
+				t.Fatalf("loadBody(%+v) reader err: %s", c.target, err)
+			}
+			contents, _ := ioutil.ReadAll(reader)
+			reader.Close()
+			if !bytes.Equal(contents, c.want) || length != int64(len(c.want)) {
+				t.Errorf("loadBody(%+v) body == %q (%d bytes) wanted %q", c.target, contents, length, c.want)
+			}
+		}
+	}
+
+	//random bodies are the size asked for
+	body, err := loadBody(Target{BodySize: "1KB", BodyRandom: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reader, length, _ := body.reader(); length != 1024 {
+		t.Errorf("random body length == %d wanted 1024", length)
+	} else if n, _ := io.Copy(ioutil.Discard, reader); n != 1024 {
+		t.Errorf("random body read %d bytes wanted 1024", n)
+	}
+}
+
+func TestRunStressBodies(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pewpew")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "upload")
+	if err := ioutil.WriteFile(filename, bytes.Repeat([]byte("pewpew"), 10000), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	//redirects once, which resends the body, then answers with how much body arrived
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := io.Copy(ioutil.Discard, r.Body)
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+			return
+		}
+		if n != r.ContentLength {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		w.Header().Set("Body-Length", strconv.FormatInt(n, 10))
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		target Target
+		length int
+	}{
+		{Target{BodyFilename: filename}, 60000},
+		{Target{BodyFilename: filename, StreamBodyFile: true}, 60000},
+		{Target{BodySize: "64KB"}, 65536},
+		{Target{BodySize: "64KB", BodyRandom: true}, 65536},
+	}
+	for _, c := range cases {
+		c.target.URL = server.URL + "/redirect"
+		c.target.Method = "POST"
+		c.target.Count = 6
+		c.target.Concurrency = 3
+		c.target.FollowRedirects = true
+		targetStats, err := RunStress(StressConfig{Targets: []Target{c.target}, Quiet: true}, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("RunStress(%+v) err: %s", c.target, err)
+		}
+		for _, stat := range targetStats[0] {
+			if stat.Error != nil {
+				t.Fatalf("request of %+v failed: %s", c.target, stat.Error)
+			}
+			if stat.StatusCode != http.StatusOK {
+				t.Errorf("request of %+v status code == %d wanted %d", c.target, stat.StatusCode, http.StatusOK)
+			}
+			if stat.DataTransferred < c.length {
+				t.Errorf("request of %+v transferred %d bytes, less than its %d byte body", c.target, stat.DataTransferred, c.length)
+			}
+		}
+	}
+}
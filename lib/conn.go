@@ -0,0 +1,113 @@
+package pewpew
+
+import (
+	"crypto/tls"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+//last connection ID handed out, shared by all targets so IDs are unique per run
+var lastConnID int64
+
+//connTracker identifies the connections a target's requests travel over
+//and how many requests are in flight on each of them at once.
+//Connections are forgotten once they're closed, or when it can't tell, once nothing is in flight on them.
+type connTracker struct {
+	mu     sync.Mutex
+	ids    map[interface{}]int
+	active map[int]int
+	//keys of the connections that can't tell when they're closed, by ID
+	keys map[int]interface{}
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{
+		ids:    make(map[interface{}]int),
+		keys:   make(map[int]interface{}),
+		active: make(map[int]int),
+	}
+}
+
+//closingConn is a dialed connection that tells when it has been closed
+type closingConn struct {
+	net.Conn
+	once   sync.Once
+	closed chan struct{}
+}
+
+func newClosingConn(conn net.Conn) *closingConn {
+	return &closingConn{Conn: conn, closed: make(chan struct{})}
+}
+
+func (c *closingConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() {
+		close(c.closed)
+	})
+	return err
+}
+
+//key that identifies the underlying connection of conn, and what's closed when it is, if that can be told.
+//HTTP3 hands out a new wrapper of the QUIC connection on every request, so it's found by its socket instead.
+func connKey(conn net.Conn) (interface{}, <-chan struct{}) {
+	//the transport's TLS runs over the connection that was dialed
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	if closing, ok := conn.(*closingConn); ok {
+		return closing, closing.closed
+	}
+	if conn.LocalAddr().Network() == "udp" {
+		if quicConn := openQUICConn(conn.LocalAddr().String()); quicConn != nil {
+			return quicConn, quicConn.Context().Done()
+		}
+	}
+	return conn, nil
+}
+
+//acquire registers a request as in flight on conn.
+//Returns the ID of conn and how many requests are in flight on it, including this one.
+func (c *connTracker) acquire(conn net.Conn) (id int, streams int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key, closed := connKey(conn)
+	id, ok := c.ids[key]
+	if !ok {
+		id = int(atomic.AddInt64(&lastConnID, 1))
+		c.ids[key] = id
+		if closed != nil {
+			go func() {
+				<-closed
+				c.forget(key)
+			}()
+		} else {
+			c.keys[id] = key
+		}
+	}
+	c.active[id]++
+	return id, c.active[id]
+}
+
+//release marks a request on connection id as finished
+func (c *connTracker) release(id int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.active[id] > 1 {
+		c.active[id]--
+		return
+	}
+	delete(c.active, id)
+	//a connection that can't tell when it's closed is forgotten now, as it may never be used again
+	if key, ok := c.keys[id]; ok {
+		delete(c.ids, key)
+		delete(c.keys, id)
+	}
+}
+
+//forget a connection that has been closed. Requests still in flight on it keep its ID.
+func (c *connTracker) forget(key interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.ids, key)
+}
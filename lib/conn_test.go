@@ -0,0 +1,73 @@
+package pewpew
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestConnTracker(t *testing.T) {
+	tracker := newConnTracker()
+	connA, _ := net.Pipe()
+	connB, _ := net.Pipe()
+
+	idA, streams := tracker.acquire(connA)
+	if streams != 1 {
+		t.Errorf("first acquire of connection streams == %d wanted 1", streams)
+	}
+	idA2, streams := tracker.acquire(connA)
+	if idA2 != idA {
+		t.Errorf("acquire of same connection id == %d wanted %d", idA2, idA)
+	}
+	if streams != 2 {
+		t.Errorf("second acquire of connection streams == %d wanted 2", streams)
+	}
+	idB, streams := tracker.acquire(connB)
+	if idB == idA {
+		t.Errorf("acquire of different connections both have id %d", idA)
+	}
+	if streams != 1 {
+		t.Errorf("first acquire of other connection streams == %d wanted 1", streams)
+	}
+
+	tracker.release(idA)
+	tracker.release(idA)
+	tracker.release(idA) //more releases than acquires should not go negative
+	idA, streams = tracker.acquire(connA)
+	if streams != 1 {
+		t.Errorf("acquire after release streams == %d wanted 1", streams)
+	}
+
+	//connections that can't tell when they're closed are forgotten once nothing is in flight on them
+	tracker.release(idA)
+	tracker.release(idB)
+	if len(tracker.ids) != 0 || len(tracker.active) != 0 {
+		t.Errorf("after releasing every request tracker has %d connections and %d active wanted none", len(tracker.ids), len(tracker.active))
+	}
+
+	//dialed connections are kept while idle, and forgotten once closed
+	pipe, _ := net.Pipe()
+	dialed := newClosingConn(pipe)
+	idC, _ := tracker.acquire(dialed)
+	tracker.release(idC)
+	if idC2, _ := tracker.acquire(dialed); idC2 != idC {
+		t.Errorf("acquire of idle dialed connection id == %d wanted %d", idC2, idC)
+	}
+	tracked := func() int {
+		tracker.mu.Lock()
+		defer tracker.mu.Unlock()
+		return len(tracker.ids)
+	}
+	dialed.Close()
+	for i := 0; i < 100 && tracked() > 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if tracked() != 0 {
+		t.Errorf("closed connection is still tracked")
+	}
+	//the request in flight on it when it closed is still released
+	tracker.release(idC)
+	if len(tracker.active) != 0 {
+		t.Errorf("after releasing the closed connection's request tracker has %d active wanted none", len(tracker.active))
+	}
+}
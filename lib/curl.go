@@ -0,0 +1,525 @@
+package pewpew
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//how curl options that are understood set a target
+const (
+	curlURL = iota
+	curlMethod
+	curlHeader
+	curlUserAgent
+	curlReferer
+	curlCookie
+	curlUser
+	curlDigest
+	curlBearer
+	curlData
+	curlDataRaw
+	curlDataBinary
+	curlDataURLEncode
+	curlJSON
+	curlForm
+	curlGet
+	curlHead
+	curlLocation
+	curlCompressed
+	curlResolve
+	curlMaxTime
+	curlConnectTimeout
+	curlHTTP3
+	curlInterface
+	//options that only change what curl prints, or have no counterpart in pewpew
+	curlIgnored
+	curlIgnoredArg
+)
+
+//curl's long options, and whether they take an argument
+var curlOptions = map[string]struct {
+	action int
+	hasArg bool
+}{
+	"--url":                   {curlURL, true},
+	"--request":               {curlMethod, true},
+	"--header":                {curlHeader, true},
+	"--user-agent":            {curlUserAgent, true},
+	"--referer":               {curlReferer, true},
+	"--cookie":                {curlCookie, true},
+	"--user":                  {curlUser, true},
+	"--basic":                 {curlIgnored, false},
+	"--digest":                {curlDigest, false},
+	"--oauth2-bearer":         {curlBearer, true},
+	"--data":                  {curlData, true},
+	"--data-ascii":            {curlData, true},
+	"--data-raw":              {curlDataRaw, true},
+	"--data-binary":           {curlDataBinary, true},
+	"--data-urlencode":        {curlDataURLEncode, true},
+	"--json":                  {curlJSON, true},
+	"--form":                  {curlForm, true},
+	"--form-string":           {curlForm, true},
+	"--get":                   {curlGet, false},
+	"--head":                  {curlHead, false},
+	"--location":              {curlLocation, false},
+	"--compressed":            {curlCompressed, false},
+	"--resolve":               {curlResolve, true},
+	"--max-time":              {curlMaxTime, true},
+	"--connect-timeout":       {curlConnectTimeout, true},
+	"--http3":                 {curlHTTP3, false},
+	"--http3-only":            {curlHTTP3, false},
+	"--interface":             {curlInterface, true},
+	"--insecure":              {curlIgnored, false},
+	"--silent":                {curlIgnored, false},
+	"--show-error":            {curlIgnored, false},
+	"--verbose":               {curlIgnored, false},
+	"--include":               {curlIgnored, false},
+	"--fail":                  {curlIgnored, false},
+	"--fail-with-body":        {curlIgnored, false},
+	"--no-buffer":             {curlIgnored, false},
+	"--globoff":               {curlIgnored, false},
+	"--progress-bar":          {curlIgnored, false},
+	"--no-progress-meter":     {curlIgnored, false},
+	"--remote-name":           {curlIgnored, false},
+	"--remote-header-name":    {curlIgnored, false},
+	"--http1.0":               {curlIgnored, false},
+	"--http1.1":               {curlIgnored, false},
+	"--http2":                 {curlIgnored, false},
+	"--http2-prior-knowledge": {curlIgnored, false},
+	"--no-keepalive":          {curlIgnored, false},
+	"--tcp-nodelay":           {curlIgnored, false},
+	"--output":                {curlIgnoredArg, true},
+	"--write-out":             {curlIgnoredArg, true},
+	"--dump-header":           {curlIgnoredArg, true},
+	"--cookie-jar":            {curlIgnoredArg, true},
+	"--retry":                 {curlIgnoredArg, true},
+	"--retry-delay":           {curlIgnoredArg, true},
+	"--retry-max-time":        {curlIgnoredArg, true},
+	"--stderr":                {curlIgnoredArg, true},
+	"--trace":                 {curlIgnoredArg, true},
+	"--trace-ascii":           {curlIgnoredArg, true},
+}
+
+//curl's short options, as the long option they stand for
+var curlShortOptions = map[byte]string{
+	'X': "--request",
+	'H': "--header",
+	'A': "--user-agent",
+	'e': "--referer",
+	'b': "--cookie",
+	'u': "--user",
+	'd': "--data",
+	'F': "--form",
+	'G': "--get",
+	'I': "--head",
+	'L': "--location",
+	'm': "--max-time",
+	'k': "--insecure",
+	's': "--silent",
+	'S': "--show-error",
+	'v': "--verbose",
+	'i': "--include",
+	'f': "--fail",
+	'N': "--no-buffer",
+	'g': "--globoff",
+	'#': "--progress-bar",
+	'O': "--remote-name",
+	'J': "--remote-header-name",
+	'0': "--http1.0",
+	'o': "--output",
+	'w': "--write-out",
+	'D': "--dump-header",
+	'c': "--cookie-jar",
+}
+
+//TargetFromCurl turns a curl command line, like one copied with "Copy as cURL" from browser
+//developer tools, into a Target sending the same request. The method, URL, headers, cookies,
+//body, auth, --compressed, --location, --resolve and timeouts are kept. Options that only change
+//what curl prints are skipped, as is -k, since certificates are only checked with EnforceSSL.
+//Count, Concurrency and the like are left for the global settings.
+func TargetFromCurl(command string) (Target, error) {
+	args, err := splitShellWords(command)
+	if err != nil {
+		return Target{}, err
+	}
+	if len(args) == 0 || (path.Base(args[0]) != "curl" && path.Base(args[0]) != "curl.exe") {
+		return Target{}, errors.New("not a curl command: " + command)
+	}
+
+	var target Target
+	var data []string
+	var dataFile string
+	var get, head, digest, jsonData, hasContentType bool
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			for _, rest := range args[i+1:] {
+				if err := setCurlURL(&target, rest); err != nil {
+					return Target{}, err
+				}
+			}
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			if err := setCurlURL(&target, arg); err != nil {
+				return Target{}, err
+			}
+			continue
+		}
+
+		//short options can be grouped, like -sSL, and hold their argument, like -XPOST
+		var options []string
+		var attached string
+		if strings.HasPrefix(arg, "--") {
+			options = []string{arg}
+		} else {
+			for j := 1; j < len(arg); j++ {
+				long, ok := curlShortOptions[arg[j]]
+				if !ok {
+					return Target{}, errors.New("unsupported curl option: -" + string(arg[j]))
+				}
+				options = append(options, long)
+				if curlOptions[long].hasArg {
+					attached = arg[j+1:]
+					break
+				}
+			}
+		}
+		for _, option := range options {
+			spec, ok := curlOptions[option]
+			if !ok {
+				return Target{}, errors.New("unsupported curl option: " + option)
+			}
+			var value string
+			if spec.hasArg {
+				if attached != "" {
+					value = attached
+				} else if i+1 < len(args) {
+					i++
+					value = args[i]
+				} else {
+					return Target{}, errors.New("curl option " + option + " is missing its argument")
+				}
+			}
+
+			switch spec.action {
+			case curlURL:
+				err = setCurlURL(&target, value)
+			case curlMethod:
+				target.Method = value
+			case curlHeader:
+				nameValue := strings.SplitN(value, ":", 2)
+				name := strings.TrimSpace(nameValue[0])
+				switch {
+				//"Name;" sends the header empty
+				case len(nameValue) == 1 && strings.HasSuffix(name, ";"):
+					target.HeaderList = append(target.HeaderList, strings.TrimSuffix(name, ";")+":")
+				//"Name:" only removes a header curl would have sent
+				case len(nameValue) == 1 || strings.TrimSpace(nameValue[1]) == "":
+				case strings.EqualFold(name, "User-Agent"):
+					target.UserAgent = strings.TrimSpace(nameValue[1])
+				default:
+					if strings.EqualFold(name, "Content-Type") {
+						hasContentType = true
+					}
+					target.HeaderList = append(target.HeaderList, value)
+				}
+			case curlUserAgent:
+				target.UserAgent = value
+			case curlReferer:
+				//";auto" only changes the referer of redirects
+				if referer := strings.TrimSuffix(value, ";auto"); referer != "auto" {
+					target.HeaderList = append(target.HeaderList, "Referer: "+referer)
+				}
+			case curlCookie:
+				//without an equals sign, it names a file to read cookies from
+				if !strings.Contains(value, "=") {
+					return Target{}, errors.New("curl cookie files are not supported: " + value)
+				}
+				for _, cookie := range strings.Split(value, ";") {
+					if cookie = strings.TrimSpace(cookie); cookie != "" {
+						target.CookieList = append(target.CookieList, cookie)
+					}
+				}
+			case curlUser:
+				userPass := strings.SplitN(value, ":", 2)
+				target.BasicAuthUser = userPass[0]
+				if len(userPass) == 2 {
+					target.BasicAuthPass = userPass[1]
+				}
+			case curlDigest:
+				digest = true
+			case curlBearer:
+				target.BearerToken = value
+			case curlData, curlDataBinary:
+				if strings.HasPrefix(value, "@") {
+					dataFile = value[1:]
+					if dataFile == "-" {
+						return Target{}, errors.New("curl data from stdin is not supported")
+					}
+					continue
+				}
+				data = append(data, value)
+			case curlDataRaw:
+				data = append(data, value)
+			case curlDataURLEncode:
+				encoded, err := curlURLEncode(value)
+				if err != nil {
+					return Target{}, err
+				}
+				data = append(data, encoded)
+			case curlJSON:
+				if strings.HasPrefix(value, "@") {
+					return Target{}, errors.New("curl JSON from a file is not supported: " + value)
+				}
+				data = append(data, value)
+				jsonData = true
+			case curlForm:
+				if strings.Contains(value, "=<") {
+					return Target{}, errors.New("curl form fields read from a file are not supported: " + value)
+				}
+				target.Multipart = append(target.Multipart, value)
+			case curlGet:
+				get = true
+			case curlHead:
+				head = true
+			case curlLocation:
+				target.FollowRedirects = true
+			case curlCompressed:
+				target.Compress = true
+			case curlResolve:
+				target.Resolve = append(target.Resolve, value)
+			case curlMaxTime:
+				target.Timeout, err = curlSeconds(value)
+			case curlConnectTimeout:
+				target.DialTimeout, err = curlSeconds(value)
+			case curlHTTP3:
+				target.HTTP3 = true
+			case curlInterface:
+				if net.ParseIP(value) == nil {
+					return Target{}, errors.New("curl interface must be an IP address: " + value)
+				}
+				target.SourceIPs = value
+			}
+			if err != nil {
+				return Target{}, err
+			}
+		}
+	}
+	if target.URL == "" {
+		return Target{}, errors.New("curl command has no URL: " + command)
+	}
+
+	//the same as curl picks
+	if dataFile != "" && len(data) > 0 {
+		return Target{}, errors.New("curl data from a file cannot be combined with other data")
+	}
+	body := strings.Join(data, "&")
+	method := "GET"
+	switch {
+	case head:
+		method = "HEAD"
+	case get:
+		if dataFile != "" {
+			return Target{}, errors.New("curl data from a file cannot be sent with --get")
+		}
+		if body != "" {
+			if strings.Contains(target.URL, "?") {
+				target.URL += "&" + body
+			} else {
+				target.URL += "?" + body
+			}
+		}
+	case body != "" || dataFile != "" || len(target.Multipart) > 0:
+		method = "POST"
+		target.Body = body
+		target.BodyFilename = dataFile
+		if !hasContentType && len(target.Multipart) == 0 {
+			if jsonData {
+				target.HeaderList = append(target.HeaderList, "Content-Type: application/json", "Accept: application/json")
+			} else {
+				target.HeaderList = append(target.HeaderList, "Content-Type: application/x-www-form-urlencoded")
+			}
+		}
+	}
+	if target.Method == "" {
+		target.Method = method
+	}
+	//--digest applies to the credentials of --user, wherever it is
+	if digest {
+		if target.BasicAuthUser == "" {
+			return Target{}, errors.New("curl --digest requires --user")
+		}
+		target.DigestAuthUser, target.DigestAuthPass = target.BasicAuthUser, target.BasicAuthPass
+		target.BasicAuthUser, target.BasicAuthPass = "", ""
+	}
+	return target, nil
+}
+
+//set the URL of a curl command, which defaults to http:// like curl does
+func setCurlURL(target *Target, rawURL string) error {
+	if target.URL != "" {
+		return errors.New("curl commands with more than one URL are not supported")
+	}
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	if _, err := url.Parse(rawURL); err != nil {
+		return errors.New("failed to parse curl URL: " + err.Error())
+	}
+	target.URL = rawURL
+	return nil
+}
+
+//encode the argument of --data-urlencode: "content", "=content" or "name=content"
+func curlURLEncode(value string) (string, error) {
+	if at := strings.Index(value, "@"); at != -1 && !strings.Contains(value[:at], "=") {
+		return "", errors.New("curl data read from a file to URL encode is not supported: " + value)
+	}
+	eq := strings.Index(value, "=")
+	if eq == -1 {
+		return url.QueryEscape(value), nil
+	}
+	if eq == 0 {
+		return url.QueryEscape(value[1:]), nil
+	}
+	return value[:eq] + "=" + url.QueryEscape(value[eq+1:]), nil
+}
+
+//curl's timeouts, in seconds like "2.5", as a duration
+func curlSeconds(value string) (string, error) {
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || seconds <= 0 {
+		return "", errors.New("failed to parse curl timeout: " + value)
+	}
+	return time.Duration(seconds * float64(time.Second)).String(), nil
+}
+
+//split a command line into its words the way a POSIX shell does, with single quotes,
+//double quotes, backslash escapes, line continuations, and bash's $'...' strings
+func splitShellWords(command string) ([]string, error) {
+	command = strings.Replace(command, "\r\n", "\n", -1)
+	var words []string
+	var word strings.Builder
+	inWord := false
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '\\':
+			if i+1 < len(command) {
+				i++
+				//a backslash before a newline continues the line
+				if command[i] != '\n' {
+					word.WriteByte(command[i])
+					inWord = true
+				}
+			}
+		case c == '\'':
+			end := strings.IndexByte(command[i+1:], '\'')
+			if end == -1 {
+				return nil, errors.New("unterminated single quote in command")
+			}
+			word.WriteString(command[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '$' && i+1 < len(command) && command[i+1] == '\'':
+			n, err := ansiCQuoted(command[i+2:], &word)
+			if err != nil {
+				return nil, err
+			}
+			i += n + 1
+			inWord = true
+		case c == '"':
+			i++
+			for ; i < len(command) && command[i] != '"'; i++ {
+				//in double quotes, backslash only escapes these
+				if command[i] == '\\' && i+1 < len(command) && strings.IndexByte("$`\"\\\n", command[i+1]) != -1 {
+					i++
+					if command[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteByte(command[i])
+			}
+			if i == len(command) {
+				return nil, errors.New("unterminated double quote in command")
+			}
+			inWord = true
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+//decode the body of a $'...' string up to its closing quote into word,
+//returning how many bytes were read, including the closing quote
+func ansiCQuoted(s string, word *strings.Builder) (int, error) {
+	escapes := map[byte]byte{'a': '\a', 'b': '\b', 'e': 0x1b, 'E': 0x1b, 'f': '\f', 'n': '\n',
+		'r': '\r', 't': '\t', 'v': '\v', '\\': '\\', '\'': '\'', '"': '"', '?': '?'}
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\'':
+			return i + 1, nil
+		case s[i] == '\\' && i+1 < len(s):
+			i++
+			if escaped, ok := escapes[s[i]]; ok {
+				word.WriteByte(escaped)
+				continue
+			}
+			//hex \xHH, unicode \uHHHH and \UHHHHHHHH, and octal \NNN
+			base, maxLen := 16, 0
+			switch {
+			case s[i] == 'x':
+				maxLen = 2
+			case s[i] == 'u':
+				maxLen = 4
+			case s[i] == 'U':
+				maxLen = 8
+			case s[i] >= '0' && s[i] <= '7':
+				base, maxLen = 8, 3
+				i--
+			}
+			escape := s[i]
+			digits := ""
+			for maxLen > 0 && i+1 < len(s) && len(digits) < maxLen && isDigitOfBase(s[i+1], base) {
+				i++
+				digits += string(s[i])
+			}
+			if digits == "" {
+				word.WriteByte('\\')
+				word.WriteByte(s[i])
+				continue
+			}
+			value, _ := strconv.ParseUint(digits, base, 32)
+			if escape == 'u' || escape == 'U' {
+				word.WriteRune(rune(value))
+			} else {
+				word.WriteByte(byte(value))
+			}
+		default:
+			word.WriteByte(s[i])
+		}
+	}
+	return 0, errors.New("unterminated $' quote in command")
+}
+
+func isDigitOfBase(c byte, base int) bool {
+	_, err := strconv.ParseUint(string(c), base, 8)
+	return err == nil
+}
@@ -0,0 +1,129 @@
+package pewpew
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTargetFromCurl(t *testing.T) {
+	cases := []struct {
+		command string
+		want    Target
+		wantErr bool
+	}{
+		//as copied from browser developer tools
+		{`curl 'https://api.example.com/orders?page=2' \
+  -H 'accept: application/json' \
+  -H 'content-type: application/json' \
+  -H 'user-agent: Mozilla/5.0' \
+  -b 'session=abc; theme=dark' \
+  --data-raw $'{"note":"it\'s"}' \
+  --compressed`, Target{
+			URL:        "https://api.example.com/orders?page=2",
+			Method:     "POST",
+			Body:       `{"note":"it's"}`,
+			HeaderList: []string{"accept: application/json", "content-type: application/json"},
+			CookieList: []string{"session=abc", "theme=dark"},
+			UserAgent:  "Mozilla/5.0",
+			Compress:   true,
+		}, false},
+		{`curl -sSLk -XPUT -u admin:pass:word --resolve example.com:443:127.0.0.1 -m 2.5 https://example.com/a -d x=1 -d y=2`, Target{
+			URL:             "https://example.com/a",
+			Method:          "PUT",
+			Body:            "x=1&y=2",
+			HeaderList:      []string{"Content-Type: application/x-www-form-urlencoded"},
+			BasicAuthUser:   "admin",
+			BasicAuthPass:   "pass:word",
+			FollowRedirects: true,
+			Resolve:         []string{"example.com:443:127.0.0.1"},
+			Timeout:         "2.5s",
+		}, false},
+		{`curl -G --data-urlencode 'q=pew pew' -d n=1 example.com/search?x=1`, Target{
+			URL:    "http://example.com/search?x=1&q=pew+pew&n=1",
+			Method: "GET",
+		}, false},
+		{`curl --json '{"a":1}' https://example.com`, Target{
+			URL:        "https://example.com",
+			Method:     "POST",
+			Body:       `{"a":1}`,
+			HeaderList: []string{"Content-Type: application/json", "Accept: application/json"},
+		}, false},
+		{`curl -F name=pew -F 'file=@/tmp/a.png;type=image/png' https://example.com/upload`, Target{
+			URL:       "https://example.com/upload",
+			Method:    "POST",
+			Multipart: []string{"name=pew", "file=@/tmp/a.png;type=image/png"},
+		}, false},
+		{`curl --digest -u user:pass -I https://example.com`, Target{
+			URL:            "https://example.com",
+			Method:         "HEAD",
+			DigestAuthUser: "user",
+			DigestAuthPass: "pass",
+		}, false},
+		{`/usr/bin/curl --data-binary @/tmp/body.bin https://example.com -o /dev/null -w '%{http_code}'`, Target{
+			URL:          "https://example.com",
+			Method:       "POST",
+			BodyFilename: "/tmp/body.bin",
+			HeaderList:   []string{"Content-Type: application/x-www-form-urlencoded"},
+		}, false},
+		{`curl -H 'X-Empty;' -H 'Accept:' -e 'https://ref.example.com;auto' --connect-timeout 3 --oauth2-bearer tok --http3 --interface 10.0.0.1 -- https://example.com`, Target{
+			URL:         "https://example.com",
+			Method:      "GET",
+			HeaderList:  []string{"X-Empty:", "Referer: https://ref.example.com"},
+			DialTimeout: "3s",
+			BearerToken: "tok",
+			HTTP3:       true,
+			SourceIPs:   "10.0.0.1",
+		}, false},
+		{`wget https://example.com`, Target{}, true},
+		{`curl`, Target{}, true},
+		{`curl -Z https://example.com`, Target{}, true},
+		{`curl --proxy http://proxy:3128 https://example.com`, Target{}, true},
+		{`curl -b cookies.txt https://example.com`, Target{}, true},
+		{`curl https://a.example.com https://b.example.com`, Target{}, true},
+		{`curl -d @body.json -d x=1 https://example.com`, Target{}, true},
+		{`curl 'https://example.com`, Target{}, true},
+		{`curl -m soon https://example.com`, Target{}, true},
+		{`curl --digest https://example.com`, Target{}, true},
+		{`curl --interface eth0 https://example.com`, Target{}, true},
+		{`curl https://example.com -H`, Target{}, true},
+	}
+	for _, c := range cases {
+		target, err := TargetFromCurl(c.command)
+		if (err != nil) != c.wantErr {
+			t.Errorf("TargetFromCurl(%q) err: %v wanted error: %t", c.command, err, c.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(target, c.want) {
+			t.Errorf("TargetFromCurl(%q) == %+v wanted %+v", c.command, target, c.want)
+		}
+	}
+}
+
+func TestSplitShellWords(t *testing.T) {
+	cases := []struct {
+		command string
+		want    []string
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"  a\tb  ", []string{"a", "b"}, false},
+		{`a 'b c' "d \"e\" \$f \g" h\ i`, []string{"a", "b c", `d "e" $f \g`, "h i"}, false},
+		{`a''b "" ''`, []string{"ab", "", ""}, false},
+		{`$'i\tj\x41\101é\'\q'`, []string{"i\tjAAé'\\q"}, false},
+		{"a \\\nb \\\r\nc", []string{"a", "b", "c"}, false},
+		{`$'a' b $'c'd`, []string{"a", "b", "cd"}, false},
+		{`'unterminated`, nil, true},
+		{`"unterminated`, nil, true},
+		{`$'unterminated`, nil, true},
+	}
+	for _, c := range cases {
+		words, err := splitShellWords(c.command)
+		if (err != nil) != c.wantErr {
+			t.Errorf("splitShellWords(%q) err: %v wanted error: %t", c.command, err, c.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(words, c.want) {
+			t.Errorf("splitShellWords(%q) == %q wanted %q", c.command, words, c.want)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package pewpew
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+//digestChallenge is the WWW-Authenticate challenge of a server using Digest auth, from RFC 7616
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	//the qop chosen out of those the server offered, empty for RFC 2069 servers that offer none
+	qop string
+	//the nonce expired, but the credentials were right
+	stale bool
+}
+
+//digestAuth answers Digest challenges for one worker, reusing the nonce
+//of the last challenge for later requests and counting its uses
+type digestAuth struct {
+	user      string
+	pass      string
+	challenge *digestChallenge
+	//requests sent with the current nonce
+	nonceCount int
+}
+
+//Digest auth state for a worker of the target, nil if it doesn't use Digest auth
+func newDigestAuth(t Target) *digestAuth {
+	if t.DigestAuthUser == "" {
+		return nil
+	}
+	return &digestAuth{user: t.DigestAuthUser, pass: t.DigestAuthPass}
+}
+
+//send req with send, answering a Digest challenge in the response by sending it again.
+//The stat is of the final request, with the challenge's round trip counted in its AuthRoundTrips.
+func (d *digestAuth) do(req http.Request, send func(http.Request) (*http.Response, RequestStat)) (*http.Response, RequestStat) {
+	answered := d.challenge != nil
+	if answered {
+		if err := d.authorize(&req); err != nil {
+			return nil, unsentRequestStat(req, err, ErrorAuth)
+		}
+	}
+	response, stat := send(req)
+	if stat.Error != nil || response.StatusCode != http.StatusUnauthorized {
+		return response, stat
+	}
+	challenge, err := parseDigestChallenge(response.Header.Values("WWW-Authenticate"))
+	if err != nil {
+		return response, stat
+	}
+	//the credentials were rejected, not just the nonce, so answering again won't help
+	if answered && !challenge.stale {
+		d.challenge = challenge
+		d.nonceCount = 0
+		return response, stat
+	}
+	d.challenge = challenge
+	d.nonceCount = 0
+
+	retry := req
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return response, stat
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return response, stat
+		}
+		retry.Body = body
+	}
+	if err := d.authorize(&retry); err != nil {
+		stat.Error = err
+		stat.ErrorKind = ErrorAuth
+		return response, stat
+	}
+	retryResponse, retryStat := send(retry)
+	retryStat.AuthRoundTrips = stat.AuthRoundTrips + 1
+	retryStat.AuthRoundTripTime = stat.AuthRoundTripTime + stat.Duration
+	retryStat.DataTransferred += stat.DataTransferred
+	return retryResponse, retryStat
+}
+
+//set the Authorization header of req, answering the current challenge with the next nonce count
+func (d *digestAuth) authorize(req *http.Request) error {
+	c := d.challenge
+	algorithm := strings.TrimSuffix(strings.ToUpper(c.algorithm), "-SESS")
+	session := algorithm != strings.ToUpper(c.algorithm)
+	var newHash func() hash.Hash
+	switch algorithm {
+	case "", "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		return errors.New("unsupported Digest algorithm: " + c.algorithm)
+	}
+	h := func(data string) string {
+		hasher := newHash()
+		io.WriteString(hasher, data)
+		return hex.EncodeToString(hasher.Sum(nil))
+	}
+
+	d.nonceCount++
+	nc := fmt.Sprintf("%08x", d.nonceCount)
+	cnonceBytes := make([]byte, 16)
+	rand.Read(cnonceBytes)
+	cnonce := hex.EncodeToString(cnonceBytes)
+	uri := req.URL.RequestURI()
+
+	ha1 := h(d.user + ":" + c.realm + ":" + d.pass)
+	if session {
+		ha1 = h(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+	ha2 := h(req.Method + ":" + uri)
+	if c.qop == "auth-int" {
+		bodyHasher := newHash()
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return errors.New("failed to read request body for Digest auth: " + err.Error())
+			}
+			_, err = io.Copy(bodyHasher, body)
+			body.Close()
+			if err != nil {
+				return errors.New("failed to read request body for Digest auth: " + err.Error())
+			}
+		}
+		ha2 = h(req.Method + ":" + uri + ":" + hex.EncodeToString(bodyHasher.Sum(nil)))
+	}
+	var response string
+	if c.qop == "" {
+		response = h(ha1 + ":" + c.nonce + ":" + ha2)
+	} else {
+		response = h(ha1 + ":" + c.nonce + ":" + nc + ":" + cnonce + ":" + c.qop + ":" + ha2)
+	}
+
+	params := []string{
+		`username="` + quoteEscaper.Replace(d.user) + `"`,
+		`realm="` + quoteEscaper.Replace(c.realm) + `"`,
+		`nonce="` + quoteEscaper.Replace(c.nonce) + `"`,
+		`uri="` + quoteEscaper.Replace(uri) + `"`,
+		`response="` + response + `"`,
+	}
+	if c.algorithm != "" {
+		params = append(params, "algorithm="+c.algorithm)
+	}
+	if c.opaque != "" {
+		params = append(params, `opaque="`+quoteEscaper.Replace(c.opaque)+`"`)
+	}
+	if c.qop != "" {
+		params = append(params, "qop="+c.qop, "nc="+nc, `cnonce="`+cnonce+`"`)
+	}
+	req.Header.Set("Authorization", "Digest "+strings.Join(params, ", "))
+	return nil
+}
+
+//find the Digest challenge among the WWW-Authenticate headers of a response
+func parseDigestChallenge(headers []string) (*digestChallenge, error) {
+	for _, header := range headers {
+		idx := strings.Index(strings.ToLower(header), "digest ")
+		//not part of another challenge's scheme, like "X-Digest"
+		if idx == -1 || (idx > 0 && header[idx-1] != ' ' && header[idx-1] != ',') {
+			continue
+		}
+		params := parseAuthParams(header[idx+len("digest "):])
+		if params["nonce"] == "" {
+			return nil, errors.New("Digest challenge has no nonce")
+		}
+		challenge := &digestChallenge{
+			realm:     params["realm"],
+			nonce:     params["nonce"],
+			opaque:    params["opaque"],
+			algorithm: params["algorithm"],
+			stale:     strings.EqualFold(params["stale"], "true"),
+		}
+		if qops, set := params["qop"]; set {
+			for _, qop := range strings.Split(qops, ",") {
+				qop = strings.TrimSpace(qop)
+				if qop == "auth" || (qop == "auth-int" && challenge.qop == "") {
+					challenge.qop = qop
+				}
+			}
+			if challenge.qop == "" {
+				return nil, errors.New("unsupported Digest qop: " + qops)
+			}
+		}
+		return challenge, nil
+	}
+	return nil, errors.New("no Digest challenge")
+}
+
+//parse auth params like `realm="a, b", nonce="abc", algorithm=MD5` into a map of lowercase names.
+//Stops at the next challenge in the same header.
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " ,")
+		eq := strings.Index(s, "=")
+		if eq == -1 {
+			return params
+		}
+		name := strings.ToLower(strings.TrimSpace(s[:eq]))
+		//a word with a space before any equals sign starts the next challenge
+		if strings.ContainsAny(name, " \t") {
+			return params
+		}
+		s = strings.TrimLeft(s[eq+1:], " ")
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			var unquoted strings.Builder
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				unquoted.WriteByte(s[i])
+			}
+			value = unquoted.String()
+			if i < len(s) {
+				i++
+			}
+			s = s[i:]
+		} else {
+			end := strings.Index(s, ",")
+			if end == -1 {
+				end = len(s)
+			}
+			value = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+		params[name] = value
+	}
+}
@@ -0,0 +1,201 @@
+package pewpew
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseAuthParams(t *testing.T) {
+	cases := []struct {
+		s    string
+		want map[string]string
+	}{
+		{"", map[string]string{}},
+		{`realm="test"`, map[string]string{"realm": "test"}},
+		{`Realm="a, b", nonce="abc", algorithm=MD5`, map[string]string{"realm": "a, b", "nonce": "abc", "algorithm": "MD5"}},
+		{`realm = "with \"quotes\"" ,qop="auth,auth-int"`, map[string]string{"realm": `with "quotes"`, "qop": "auth,auth-int"}},
+		{`realm="unterminated`, map[string]string{"realm": "unterminated"}},
+		//stops at the next challenge
+		{`realm="a", Basic realm="b"`, map[string]string{"realm": "a"}},
+	}
+	for _, c := range cases {
+		if got := parseAuthParams(c.s); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("parseAuthParams(%q) == %v wanted %v", c.s, got, c.want)
+		}
+	}
+}
+
+func TestParseDigestChallenge(t *testing.T) {
+	cases := []struct {
+		headers   []string
+		want      *digestChallenge
+		wantError bool
+	}{
+		{nil, nil, true},
+		{[]string{`Basic realm="test"`}, nil, true},
+		{[]string{`Digest realm="test"`}, nil, true},
+		{[]string{`Digest realm="test", qop="auth-conf", nonce="abc"`}, nil, true},
+		{[]string{`X-Digest realm="test", nonce="abc"`}, nil, true},
+		{[]string{`Digest realm="test", nonce="abc"`}, &digestChallenge{realm: "test", nonce: "abc"}, false},
+		{[]string{`Basic realm="basic"`, `Digest realm="test", nonce="abc", opaque="xyz", algorithm=SHA-256, qop="auth-int,auth", stale=TRUE`},
+			&digestChallenge{realm: "test", nonce: "abc", opaque: "xyz", algorithm: "SHA-256", qop: "auth", stale: true}, false},
+		{[]string{`Basic realm="basic", Digest realm="test", nonce="abc", qop="auth-int"`},
+			&digestChallenge{realm: "test", nonce: "abc", qop: "auth-int"}, false},
+	}
+	for _, c := range cases {
+		got, err := parseDigestChallenge(c.headers)
+		if (err != nil) != c.wantError {
+			t.Errorf("parseDigestChallenge(%q) err: %v wanted error: %t", c.headers, err, c.wantError)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("parseDigestChallenge(%q) == %+v wanted %+v", c.headers, got, c.want)
+		}
+	}
+}
+
+//server that accepts Digest auth of "user" with the password "hunter2".
+//Its nonce changes after maxUses requests, when maxUses is set.
+type testDigestServer struct {
+	algorithm string
+	qop       string
+	maxUses   int
+
+	lock  sync.Mutex
+	nonce int
+	uses  int
+	//nonce counts of the accepted requests, in order
+	nonceCounts []string
+}
+
+func (s *testDigestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	nonce := "nonce" + strconv.Itoa(s.nonce)
+	challenge := func(stale bool) {
+		header := `Digest realm="test", nonce="` + nonce + `", opaque="xyz"`
+		if s.algorithm != "" {
+			header += ", algorithm=" + s.algorithm
+		}
+		if s.qop != "" {
+			header += `, qop="` + s.qop + `"`
+		}
+		if stale {
+			header += ", stale=true"
+		}
+		w.Header().Add("WWW-Authenticate", `Basic realm="test"`)
+		w.Header().Add("WWW-Authenticate", header)
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Digest ") {
+		challenge(false)
+		return
+	}
+	params := parseAuthParams(auth[len("Digest "):])
+	if params["nonce"] != nonce {
+		challenge(true)
+		return
+	}
+
+	newHash := md5.New
+	if strings.HasPrefix(s.algorithm, "SHA-256") {
+		newHash = sha256.New
+	}
+	h := func(data string) string {
+		var hasher hash.Hash = newHash()
+		hasher.Write([]byte(data))
+		return hex.EncodeToString(hasher.Sum(nil))
+	}
+	ha1 := h("user:test:hunter2")
+	if strings.HasSuffix(s.algorithm, "-sess") {
+		ha1 = h(ha1 + ":" + nonce + ":" + params["cnonce"])
+	}
+	ha2 := h(r.Method + ":" + r.URL.RequestURI())
+	if s.qop == "auth-int" {
+		body, _ := ioutil.ReadAll(r.Body)
+		ha2 = h(r.Method + ":" + r.URL.RequestURI() + ":" + h(string(body)))
+	}
+	want := h(ha1 + ":" + nonce + ":" + ha2)
+	if s.qop != "" {
+		want = h(ha1 + ":" + nonce + ":" + params["nc"] + ":" + params["cnonce"] + ":" + s.qop + ":" + ha2)
+	}
+	if params["username"] != "user" || params["uri"] != r.URL.RequestURI() || params["opaque"] != "xyz" || params["response"] != want {
+		challenge(false)
+		return
+	}
+	s.nonceCounts = append(s.nonceCounts, params["nc"])
+	s.uses++
+	if s.maxUses > 0 && s.uses == s.maxUses {
+		s.nonce++
+		s.uses = 0
+	}
+}
+
+func TestRunStressDigest(t *testing.T) {
+	cases := []struct {
+		server      *testDigestServer
+		target      Target
+		statusCode  int
+		roundTrips  int
+		nonceCounts []string
+	}{
+		//each worker answers one challenge, then reuses its nonce
+		{&testDigestServer{qop: "auth"}, Target{DigestAuthPass: "hunter2", Count: 6, Concurrency: 2}, http.StatusOK, 2, nil},
+		{&testDigestServer{qop: "auth"}, Target{DigestAuthPass: "hunter2", Count: 4, Concurrency: 1}, http.StatusOK, 1,
+			[]string{"00000001", "00000002", "00000003", "00000004"}},
+		{&testDigestServer{}, Target{DigestAuthPass: "hunter2", Count: 3, Concurrency: 1}, http.StatusOK, 1, nil},
+		{&testDigestServer{algorithm: "SHA-256-sess", qop: "auth-int"}, Target{DigestAuthPass: "hunter2", Method: "POST", Body: "pewpew", Count: 3, Concurrency: 1}, http.StatusOK, 1, nil},
+		{&testDigestServer{algorithm: "MD5", qop: "auth"}, Target{DigestAuthPass: "hunter2", Method: "POST", BodySize: "1KB", BodyEncoding: EncodingGzip, Count: 3, Concurrency: 1}, http.StatusOK, 1, nil},
+		//a stale nonce is answered again with the new one, starting its count over
+		{&testDigestServer{qop: "auth", maxUses: 2}, Target{DigestAuthPass: "hunter2", Count: 5, Concurrency: 1}, http.StatusOK, 3,
+			[]string{"00000001", "00000002", "00000001", "00000002", "00000001"}},
+		//rejected credentials aren't retried after the first challenge
+		{&testDigestServer{qop: "auth"}, Target{DigestAuthPass: "wrong", Count: 3, Concurrency: 1}, http.StatusUnauthorized, 1, nil},
+	}
+	for _, c := range cases {
+		server := httptest.NewServer(c.server)
+		c.target.URL = server.URL + "/path?a=b"
+		if c.target.Method == "" {
+			c.target.Method = DefaultMethod
+		}
+		c.target.DigestAuthUser = "user"
+		targetStats, err := RunStress(StressConfig{Targets: []Target{c.target}, Quiet: true}, ioutil.Discard)
+		server.Close()
+		if err != nil {
+			t.Fatalf("RunStress(%+v) err: %s", c.target, err)
+		}
+		roundTrips := 0
+		for _, stat := range targetStats[0] {
+			if stat.Error != nil {
+				t.Fatalf("request of %+v failed: %s", c.target, stat.Error)
+			}
+			if stat.StatusCode != c.statusCode {
+				t.Errorf("request of %+v status code == %d wanted %d", c.target, stat.StatusCode, c.statusCode)
+			}
+			if stat.AuthRoundTrips > 1 {
+				t.Errorf("request of %+v made %d auth round trips wanted at most 1", c.target, stat.AuthRoundTrips)
+			}
+			if stat.AuthRoundTrips > 0 && stat.AuthRoundTripTime == 0 {
+				t.Errorf("request of %+v has no auth round trip time", c.target)
+			}
+			roundTrips += stat.AuthRoundTrips
+		}
+		if roundTrips != c.roundTrips {
+			t.Errorf("RunStress(%+v) made %d auth round trips wanted %d", c.target, roundTrips, c.roundTrips)
+		}
+		if c.nonceCounts != nil && !reflect.DeepEqual(c.server.nonceCounts, c.nonceCounts) {
+			t.Errorf("RunStress(%+v) sent nonce counts %v wanted %v", c.target, c.server.nonceCounts, c.nonceCounts)
+		}
+	}
+}
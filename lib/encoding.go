@@ -0,0 +1,158 @@
+package pewpew
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	brotli "github.com/andybalholm/brotli"
+	zstd "github.com/klauspost/compress/zstd"
+)
+
+//Content encodings request bodies can be compressed with, and responses are decoded from
+const (
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+	EncodingBrotli  = "br"
+	EncodingZstd    = "zstd"
+)
+
+//sent as Accept-Encoding by targets with Compress set, as it always has been so results stay comparable.
+//The other encodings are decoded too, when asked for with an Accept-Encoding header.
+const acceptEncoding = EncodingGzip
+
+func isSupportedEncoding(encoding string) bool {
+	switch encoding {
+	case EncodingGzip, EncodingDeflate, EncodingBrotli, EncodingZstd:
+		return true
+	}
+	return false
+}
+
+//compress everything read from r with encoding
+func encodeBody(encoding string, r io.Reader) ([]byte, error) {
+	var encoded bytes.Buffer
+	var writer io.WriteCloser
+	switch encoding {
+	case EncodingGzip:
+		writer = gzip.NewWriter(&encoded)
+	case EncodingDeflate:
+		//HTTP's deflate is the zlib format
+		writer = zlib.NewWriter(&encoded)
+	case EncodingBrotli:
+		writer = brotli.NewWriter(&encoded)
+	case EncodingZstd:
+		zstdWriter, err := zstd.NewWriter(&encoded)
+		if err != nil {
+			return nil, errors.New("failed to create zstd encoder: " + err.Error())
+		}
+		writer = zstdWriter
+	default:
+		return nil, errors.New("unsupported body encoding: " + encoding)
+	}
+	if _, err := io.Copy(writer, r); err != nil {
+		return nil, errors.New("failed to " + encoding + " encode body: " + err.Error())
+	}
+	if err := writer.Close(); err != nil {
+		return nil, errors.New("failed to " + encoding + " encode body: " + err.Error())
+	}
+	return encoded.Bytes(), nil
+}
+
+//decode a body read from r that was compressed with encoding
+func newDecoder(encoding string, r io.Reader) (io.ReadCloser, error) {
+	switch encoding {
+	case EncodingGzip:
+		return gzip.NewReader(r)
+	case EncodingDeflate:
+		return zlib.NewReader(r)
+	case EncodingBrotli:
+		return ioutil.NopCloser(brotli.NewReader(r)), nil
+	case EncodingZstd:
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	}
+	return nil, errors.New("unsupported content encoding: " + encoding)
+}
+
+//the encoding of a response that can be decoded, empty if it isn't encoded or can't be
+func responseEncoding(contentEncoding string) string {
+	encoding := strings.ToLower(strings.TrimSpace(contentEncoding))
+	if isSupportedEncoding(encoding) {
+		return encoding
+	}
+	return ""
+}
+
+//replace the body of a response compressed with encoding by its decoded contents.
+//Returns the sizes of the body before and after decoding.
+func decodeResponseBody(response *http.Response, encoding string) (int, int, error) {
+	encoded, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	//leave the body as it was if it can't be decoded
+	response.Body = ioutil.NopCloser(bytes.NewReader(encoded))
+	if err != nil {
+		return len(encoded), 0, errors.New("failed to read response body: " + err.Error())
+	}
+	decoder, err := newDecoder(encoding, bytes.NewReader(encoded))
+	if err != nil {
+		return len(encoded), 0, errors.New("failed to decode " + encoding + " response body: " + err.Error())
+	}
+	decoded, err := ioutil.ReadAll(decoder)
+	decoder.Close()
+	if err != nil {
+		return len(encoded), len(decoded), errors.New("failed to decode " + encoding + " response body: " + err.Error())
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(decoded))
+	response.Header.Del("Content-Encoding")
+	response.Header.Del("Content-Length")
+	response.ContentLength = -1
+	response.Uncompressed = true
+	return len(encoded), len(decoded), nil
+}
+
+//streamDecoder decodes a streamed response body as it's read, counting the encoded bytes.
+//The decoder is created on the first read, as some read their header when created.
+type streamDecoder struct {
+	encoding     string
+	body         io.ReadCloser
+	decoder      io.ReadCloser
+	encodedBytes int
+}
+
+func (d *streamDecoder) Read(p []byte) (int, error) {
+	if d.decoder == nil {
+		decoder, err := newDecoder(d.encoding, countingReader{d.body, &d.encodedBytes})
+		if err != nil {
+			return 0, errors.New("failed to decode " + d.encoding + " response body: " + err.Error())
+		}
+		d.decoder = decoder
+	}
+	return d.decoder.Read(p)
+}
+
+func (d *streamDecoder) Close() error {
+	if d.decoder != nil {
+		d.decoder.Close()
+	}
+	return d.body.Close()
+}
+
+type countingReader struct {
+	r     io.Reader
+	count *int
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	*c.count += n
+	return n, err
+}
@@ -0,0 +1,150 @@
+package pewpew
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEncodeBody(t *testing.T) {
+	body := strings.Repeat("pewpew ", 1000)
+	for _, encoding := range []string{EncodingGzip, EncodingDeflate, EncodingBrotli, EncodingZstd} {
+		encoded, err := encodeBody(encoding, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("encodeBody(%s) err: %s", encoding, err)
+		}
+		if len(encoded) >= len(body) {
+			t.Errorf("encodeBody(%s) is %d bytes, no smaller than the %d byte body", encoding, len(encoded), len(body))
+		}
+		decoder, err := newDecoder(encoding, bytes.NewReader(encoded))
+		if err != nil {
+			t.Fatalf("newDecoder(%s) err: %s", encoding, err)
+		}
+		decoded, err := ioutil.ReadAll(decoder)
+		if err != nil {
+			t.Fatalf("decoding %s err: %s", encoding, err)
+		}
+		if string(decoded) != body {
+			t.Errorf("decoding %s == %q wanted %q", encoding, decoded, body)
+		}
+	}
+	if _, err := encodeBody("lzma", strings.NewReader(body)); err == nil {
+		t.Errorf("encodeBody(lzma) succeeded, wanted an error")
+	}
+}
+
+func TestResponseEncoding(t *testing.T) {
+	cases := []struct {
+		contentEncoding string
+		want            string
+	}{
+		{"", ""},
+		{"gzip", EncodingGzip},
+		{" GZIP ", EncodingGzip},
+		{"deflate", EncodingDeflate},
+		{"br", EncodingBrotli},
+		{"zstd", EncodingZstd},
+		{"identity", ""},
+		{"compress", ""},
+		//responses encoded more than once are left as is
+		{"gzip, br", ""},
+	}
+	for _, c := range cases {
+		if got := responseEncoding(c.contentEncoding); got != c.want {
+			t.Errorf("responseEncoding(%q) == %q wanted %q", c.contentEncoding, got, c.want)
+		}
+	}
+}
+
+func TestRunStressEncodings(t *testing.T) {
+	body := strings.Repeat("pewpew ", 1000)
+	//checks the request body decodes, then answers with it encoded as asked in the query
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqBody, _ := ioutil.ReadAll(r.Body)
+		if encoding := r.Header.Get("Content-Encoding"); encoding != "" {
+			decoder, err := newDecoder(encoding, bytes.NewReader(reqBody))
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			reqBody, err = ioutil.ReadAll(decoder)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+		if len(reqBody) > 0 && string(reqBody) != body && !bytes.Equal(reqBody, make([]byte, 64<<10)) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		encoding := r.URL.Query().Get("encoding")
+		if encoding == "" {
+			w.Write([]byte(body))
+			return
+		}
+		if r.URL.Query().Get("broken") != "" {
+			w.Header().Set("Content-Encoding", encoding)
+			w.Write([]byte(body))
+			return
+		}
+		encoded, _ := encodeBody(encoding, strings.NewReader(body))
+		w.Header().Set("Content-Encoding", encoding)
+		w.Write(encoded)
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		target    Target
+		query     string
+		encoding  string
+		errorKind string
+	}{
+		{Target{Body: body, BodyEncoding: EncodingGzip}, "", "", ""},
+		{Target{Body: body, BodyEncoding: EncodingDeflate}, "", "", ""},
+		{Target{Body: body, BodyEncoding: EncodingBrotli}, "", "", ""},
+		{Target{Body: body, BodyEncoding: EncodingZstd}, "", "", ""},
+		{Target{BodySize: "64KB", BodyEncoding: EncodingGzip}, "", "", ""},
+		{Target{Compress: true}, "?encoding=gzip", EncodingGzip, ""},
+		{Target{Compress: true}, "?encoding=deflate", EncodingDeflate, ""},
+		{Target{Compress: true}, "?encoding=br", EncodingBrotli, ""},
+		{Target{Compress: true}, "?encoding=zstd", EncodingZstd, ""},
+		{Target{Compress: true, Stream: true}, "?encoding=gzip", EncodingGzip, ""},
+		{Target{Compress: true}, "?encoding=gzip&broken=1", EncodingGzip, ErrorContentEncoding},
+	}
+	for _, c := range cases {
+		c.target.URL = server.URL + c.query
+		c.target.Method = "POST"
+		c.target.Count = 4
+		c.target.Concurrency = 2
+		targetStats, err := RunStress(StressConfig{Targets: []Target{c.target}, Quiet: true}, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("RunStress(%+v) err: %s", c.target, err)
+		}
+		for _, stat := range targetStats[0] {
+			if stat.ErrorKind != c.errorKind {
+				t.Errorf("request of %+v error kind == %q wanted %q (%v)", c.target, stat.ErrorKind, c.errorKind, stat.Error)
+			}
+			if c.errorKind != "" {
+				continue
+			}
+			if stat.StatusCode != http.StatusOK {
+				t.Errorf("request of %+v status code == %d wanted %d", c.target, stat.StatusCode, http.StatusOK)
+			}
+			if stat.ContentEncoding != c.encoding {
+				t.Errorf("request of %+v content encoding == %q wanted %q", c.target, stat.ContentEncoding, c.encoding)
+			}
+			if c.encoding == "" {
+				continue
+			}
+			if stat.DecodedBodySize != len(body) {
+				t.Errorf("request of %+v decoded body size == %d wanted %d", c.target, stat.DecodedBodySize, len(body))
+			}
+			if stat.EncodedBodySize == 0 || stat.EncodedBodySize >= stat.DecodedBodySize {
+				t.Errorf("request of %+v encoded body size == %d, decoded %d", c.target, stat.EncodedBodySize, stat.DecodedBodySize)
+			}
+		}
+	}
+}
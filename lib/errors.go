@@ -0,0 +1,71 @@
+package pewpew
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	quic "github.com/quic-go/quic-go"
+)
+
+//Kinds of failed requests, as recorded in RequestStat.ErrorKind
+const (
+	ErrorDialTimeout           = "dial timeout"
+	ErrorTLSHandshakeTimeout   = "TLS handshake timeout"
+	ErrorResponseHeaderTimeout = "response header timeout"
+	ErrorTimeout               = "timeout" //the total Timeout of the request
+	ErrorDNS                   = "DNS lookup failed"
+	ErrorConnRefused           = "connection refused"
+	ErrorConnReset             = "connection reset"
+	ErrorTLS                   = "TLS failed"
+	ErrorGraphQL               = "GraphQL errors" //a 200 response with errors in its body
+	ErrorContentEncoding       = "content decoding failed"
+	ErrorAuth                  = "authentication failed" //a new OAuth2 token could not be fetched
+	ErrorSigning               = "signing failed"
+	ErrorOther                 = "other"
+)
+
+//the stat of a request that failed before it could be sent
+func unsentRequestStat(req http.Request, err error, errorKind string) RequestStat {
+	now := time.Now()
+	return RequestStat{
+		URL:       req.URL.String(),
+		Method:    req.Method,
+		StartTime: now,
+		EndTime:   now,
+		Error:     err,
+		ErrorKind: errorKind,
+	}
+}
+
+//sort a request error into one of the Error* kinds.
+//net/http hides most of its error types, so some can only be told apart by message.
+func classifyError(err error) string {
+	if err == nil {
+		return ""
+	}
+	msg := err.Error()
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	var quicHandshakeErr *quic.HandshakeTimeoutError
+	switch {
+	case strings.Contains(msg, "Client.Timeout"):
+		return ErrorTimeout
+	case strings.Contains(msg, "TLS handshake timeout") || errors.As(err, &quicHandshakeErr):
+		return ErrorTLSHandshakeTimeout
+	case errors.As(err, &dnsErr):
+		return ErrorDNS
+	case errors.As(err, &opErr) && opErr.Op == "dial" && opErr.Timeout():
+		return ErrorDialTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorConnRefused
+	case errors.Is(err, syscall.ECONNRESET):
+		return ErrorConnReset
+	case strings.Contains(msg, "tls: ") || strings.Contains(msg, "x509: "):
+		return ErrorTLS
+	}
+	return ErrorOther
+}
@@ -0,0 +1,41 @@
+package pewpew
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+)
+
+//net.Error that always timed out
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassifyError(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{errors.New("something else"), ErrorOther},
+		{&url.Error{Op: "Get", URL: "http://localhost", Err: errors.New("context deadline exceeded (Client.Timeout exceeded while awaiting headers)")}, ErrorTimeout},
+		{&url.Error{Op: "Get", URL: "https://localhost", Err: errors.New("net/http: TLS handshake timeout")}, ErrorTLSHandshakeTimeout},
+		{&url.Error{Op: "Get", URL: "http://localhost", Err: &net.OpError{Op: "dial", Net: "tcp", Err: timeoutError{}}}, ErrorDialTimeout},
+		{&url.Error{Op: "Get", URL: "http://localhost", Err: &net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}}, ErrorOther},
+		{&url.Error{Op: "Get", URL: "http://nonexistent", Err: &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "nonexistent"}}}, ErrorDNS},
+		{&url.Error{Op: "Get", URL: "http://localhost", Err: &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}}, ErrorConnRefused},
+		{&url.Error{Op: "Get", URL: "http://localhost", Err: &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}}, ErrorConnReset},
+		{&url.Error{Op: "Get", URL: "https://localhost", Err: errors.New("tls: failed to verify certificate: x509: certificate signed by unknown authority")}, ErrorTLS},
+	}
+	for _, c := range cases {
+		kind := classifyError(c.err)
+		if kind != c.want {
+			t.Errorf("classifyError(%v) == %q wanted %q", c.err, kind, c.want)
+		}
+	}
+}
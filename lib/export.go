@@ -0,0 +1,475 @@
+package pewpew
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+//largest body written out in full in an exported request, rather than read from its file or generated
+const maxExportedBodySize = 1 << 20
+
+//command line programs encoding bodies the same way as each BodyEncoding
+var curlBodyEncoders = map[string]string{
+	EncodingGzip:    "gzip -c",
+	EncodingDeflate: "pigz -zc",
+	EncodingBrotli:  "brotli -c",
+	EncodingZstd:    "zstd -c",
+}
+
+//CurlCommand renders the first request of t as a curl command sending it once, for reproducing
+//it without pewpew. Global settings like EnforceSSL and NoHTTP2 are taken from s.
+//Settings that only matter to a load test, like Count and KeepAlive, are left out, and
+//those curl can't do, like HMAC signing, are noted in comments above the command.
+//WebSocket and gRPC targets can't be rendered.
+func CurlCommand(t Target, s StressConfig) (string, error) {
+	req, err := exportRequest(t)
+	if err != nil {
+		return "", err
+	}
+	notes := exportNotes(t, "curl")
+	if t.RegexURL {
+		notes = append(notes, "one of the URLs generated from "+t.URL)
+	}
+	if t.OAuth2TokenURL != "" {
+		notes = append(notes, "OAUTH2_TOKEN must hold an access token from "+t.OAuth2TokenURL)
+	}
+
+	//each option with its argument, so they can go on lines of their own
+	options := []string{"curl"}
+	if req.URL.Scheme == "https" && !s.EnforceSSL {
+		//certificates are only checked with EnforceSSL
+		options = append(options, "-k")
+	}
+	switch {
+	case t.HTTP3:
+		options = append(options, "--http3")
+	case s.H2C:
+		options = append(options, "--http2-prior-knowledge")
+	case s.NoHTTP2:
+		options = append(options, "--http1.1")
+	}
+	bodyOptions, pipe, multipart, err := curlBody(t, req)
+	if err != nil {
+		return "", err
+	}
+	hasBody := len(bodyOptions) > 0
+	switch {
+	case req.Method == http.MethodHead && !hasBody:
+		options = append(options, "--head")
+	case req.Method == http.MethodGet && !hasBody, req.Method == http.MethodPost && hasBody:
+	default:
+		options = append(options, "-X "+shellQuote(req.Method))
+	}
+	for _, name := range sortedHeaderNames(req.Header) {
+		for _, value := range req.Header[name] {
+			switch {
+			case name == "User-Agent":
+				options = append(options, "-A "+shellQuote(value))
+			case name == "Cookie":
+				options = append(options, "-b "+shellQuote(value))
+			case name == "Accept-Encoding" && t.Compress && value == acceptEncoding:
+				options = append(options, "--compressed")
+			case name == "Content-Type" && multipart:
+				//curl sets its own, with its own boundary
+			case value == "":
+				//"Name:" would leave the header out
+				options = append(options, "-H "+shellQuote(name+";"))
+			default:
+				options = append(options, "-H "+shellQuote(name+": "+value))
+			}
+		}
+	}
+	if hasBody && !multipart && req.Header.Get("Content-Type") == "" {
+		//curl would send data as a form otherwise
+		options = append(options, "-H 'Content-Type:'")
+	}
+	if t.DigestAuthUser != "" {
+		options = append(options, "--digest", "-u "+shellQuote(t.DigestAuthUser+":"+t.DigestAuthPass))
+	}
+	if t.OAuth2TokenURL != "" {
+		options = append(options, `--oauth2-bearer "$OAUTH2_TOKEN"`)
+	}
+	if t.AWSAccessKeyID != "" {
+		options = append(options, "--aws-sigv4 "+shellQuote("aws:amz:"+t.AWSRegion+":"+t.AWSService),
+			"-u "+shellQuote(t.AWSAccessKeyID+":"+t.AWSSecretAccessKey))
+		if t.AWSSessionToken != "" {
+			options = append(options, "-H "+shellQuote("X-Amz-Security-Token: "+t.AWSSessionToken))
+		}
+	}
+	if t.FollowRedirects {
+		options = append(options, "-L")
+	}
+	if t.Stream {
+		options = append(options, "-N")
+		if t.StreamDuration != "" {
+			options = append(options, "--max-time "+curlSecondsArg(t.StreamDuration))
+		}
+	} else if t.Timeout != "" {
+		options = append(options, "--max-time "+curlSecondsArg(t.Timeout))
+	}
+	if t.DialTimeout != "" {
+		options = append(options, "--connect-timeout "+curlSecondsArg(t.DialTimeout))
+	}
+	for _, resolve := range t.Resolve {
+		options = append(options, "--resolve "+shellQuote(resolve))
+	}
+	if ips, _ := parseSourceIPs(t.SourceIPs); len(ips) > 0 {
+		options = append(options, "--interface "+ips[0].String())
+	}
+	options = append(options, bodyOptions...)
+	options = append(options, shellQuote(req.URL.String()))
+
+	var command strings.Builder
+	for _, note := range notes {
+		command.WriteString("# " + note + "\n")
+	}
+	if pipe != "" {
+		command.WriteString(pipe + " | ")
+	}
+	command.WriteString(strings.Join(options, " \\\n  "))
+	return command.String(), nil
+}
+
+//GoTest renders the first request of each of the targets as a test of a standalone Go test file,
+//sending it once with net/http and failing on an error or an error status.
+//Global settings like EnforceSSL are taken from s. WebSocket and gRPC targets are left out.
+func GoTest(s StressConfig) (string, error) {
+	imports := map[string]bool{"io": true, "io/ioutil": true, "net/http": true, "testing": true}
+	var tests bytes.Buffer
+	for i, t := range s.Targets {
+		fmt.Fprintln(&tests)
+		if isWebSocketURL(t.URL) || isGRPCURL(t.URL) {
+			fmt.Fprintf(&tests, "// Target %d, %s, can't be sent with net/http\n", i+1, t.URL)
+			continue
+		}
+		req, err := exportRequest(t)
+		if err != nil {
+			return "", errors.New("target " + strconv.Itoa(i+1) + ": " + err.Error())
+		}
+		fmt.Fprintf(&tests, "// TestTarget%d sends %s %s\n", i+1, req.Method, req.URL)
+		for _, note := range exportNotes(t, "this test") {
+			fmt.Fprintf(&tests, "// %s\n", note)
+		}
+		fmt.Fprintf(&tests, "func TestTarget%d(t *testing.T) {\n", i+1)
+
+		body := "nil"
+		switch {
+		case t.BodyFilename != "" && t.BodyEncoding == "":
+			fmt.Fprintf(&tests, "body, err := ioutil.ReadFile(%q)\nif err != nil {\nt.Fatal(err)\n}\n", t.BodyFilename)
+			body = "bytes.NewReader(body)"
+		case t.BodySize != "" && t.BodyEncoding == "":
+			size, _ := parseByteSize(t.BodySize)
+			fmt.Fprintf(&tests, "body := make([]byte, %d)\n", size)
+			if t.BodyRandom {
+				fmt.Fprintf(&tests, "if _, err := rand.Read(body); err != nil {\nt.Fatal(err)\n}\n")
+				imports["crypto/rand"] = true
+			}
+			body = "bytes.NewReader(body)"
+		case req.Body == nil || req.Body == http.NoBody:
+		default:
+			data, err := exportBody(req)
+			if err != nil {
+				return "", errors.New("target " + strconv.Itoa(i+1) + ": " + err.Error())
+			}
+			fmt.Fprintf(&tests, "body := []byte(%s)\n", strconv.Quote(string(data)))
+			body = "bytes.NewReader(body)"
+		}
+		if body != "nil" {
+			imports["bytes"] = true
+		}
+		fmt.Fprintf(&tests, "req, err := http.NewRequest(%q, %q, %s)\nif err != nil {\nt.Fatal(err)\n}\n", req.Method, req.URL.String(), body)
+		fmt.Fprintf(&tests, "req.Header = http.Header{\n")
+		for _, name := range sortedHeaderNames(req.Header) {
+			values := make([]string, len(req.Header[name]))
+			for j, value := range req.Header[name] {
+				values[j] = strconv.Quote(value)
+			}
+			fmt.Fprintf(&tests, "%q: {%s},\n", name, strings.Join(values, ", "))
+		}
+		fmt.Fprintf(&tests, "}\n")
+		if t.OAuth2TokenURL != "" {
+			fmt.Fprintf(&tests, "// an access token from %s\nreq.Header.Set(\"Authorization\", \"Bearer \"+os.Getenv(\"OAUTH2_TOKEN\"))\n", t.OAuth2TokenURL)
+			imports["os"] = true
+		}
+
+		fmt.Fprintf(&tests, "transport := &http.Transport{\n")
+		if !s.EnforceSSL {
+			fmt.Fprintf(&tests, "TLSClientConfig: &tls.Config{InsecureSkipVerify: true},\n")
+			imports["crypto/tls"] = true
+		}
+		if s.NoHTTP2 {
+			fmt.Fprintf(&tests, "TLSNextProto: map[string]func(string, *tls.Conn) http.RoundTripper{},\n")
+			imports["crypto/tls"] = true
+		} else {
+			fmt.Fprintf(&tests, "ForceAttemptHTTP2: true,\n")
+		}
+		fmt.Fprintf(&tests, "}\n")
+		if t.DialTimeout != "" || len(t.Resolve) > 0 {
+			fmt.Fprintf(&tests, "dialer := &net.Dialer{Timeout: %s}\n", goDuration(parseOptionalDuration(t.DialTimeout)))
+			resolve, _ := parseResolve(t.Resolve)
+			var addrs []string
+			for addr := range resolve {
+				addrs = append(addrs, addr)
+			}
+			sort.Strings(addrs)
+			fmt.Fprintf(&tests, "// connect to these addresses instead of looking up the hosts\nresolve := map[string]string{\n")
+			for _, addr := range addrs {
+				fmt.Fprintf(&tests, "%q: %q,\n", addr, resolve[addr])
+			}
+			fmt.Fprintf(&tests, "}\ntransport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {\n"+
+				"if resolved, ok := resolve[addr]; ok {\naddr = resolved\n}\nreturn dialer.DialContext(ctx, network, addr)\n}\n")
+			imports["context"] = true
+			imports["net"] = true
+		}
+		fmt.Fprintf(&tests, "client := &http.Client{Transport: transport")
+		if t.Timeout != "" && !t.Stream {
+			fmt.Fprintf(&tests, ", Timeout: %s", goDuration(parseOptionalDuration(t.Timeout)))
+			imports["time"] = true
+		}
+		fmt.Fprintf(&tests, "}\n")
+		if !t.FollowRedirects {
+			fmt.Fprintf(&tests, "client.CheckRedirect = func(req *http.Request, via []*http.Request) error {\nreturn http.ErrUseLastResponse\n}\n")
+		}
+
+		fmt.Fprintf(&tests, "resp, err := client.Do(req)\nif err != nil {\nt.Fatal(err)\n}\ndefer resp.Body.Close()\n")
+		fmt.Fprintf(&tests, "if _, err := io.Copy(ioutil.Discard, resp.Body); err != nil {\nt.Fatal(err)\n}\n")
+		fmt.Fprintf(&tests, "if resp.StatusCode >= 400 {\nt.Errorf(\"%%s %%s: %%s\", req.Method, req.URL, resp.Status)\n}\n}\n")
+		if t.DialTimeout != "" {
+			imports["time"] = true
+		}
+	}
+
+	var file bytes.Buffer
+	fmt.Fprintf(&file, "// Reproduces the requests of a pewpew config, each sent once.\n// Run with go test -v.\npackage repro\n\nimport (\n")
+	var importPaths []string
+	for path := range imports {
+		importPaths = append(importPaths, path)
+	}
+	sort.Strings(importPaths)
+	for _, path := range importPaths {
+		fmt.Fprintf(&file, "%q\n", path)
+	}
+	fmt.Fprintf(&file, ")\n")
+	file.Write(tests.Bytes())
+	formatted, err := format.Source(file.Bytes())
+	if err != nil {
+		return "", errors.New("failed to format Go test: " + err.Error())
+	}
+	return string(formatted), nil
+}
+
+//the first request of a target, as RunStress would send it
+func exportRequest(t Target) (*http.Request, error) {
+	if isWebSocketURL(t.URL) {
+		return nil, errors.New("WebSocket targets can't be exported")
+	}
+	if isGRPCURL(t.URL) {
+		return nil, errors.New("gRPC targets can't be exported")
+	}
+	body, err := loadBody(t)
+	if err != nil {
+		return nil, err
+	}
+	//a replay target's first request is the first of the access log's, see ReplayedTargets for the rest
+	reqTarget := t
+	if len(t.ReplayRequests) > 0 {
+		reqTarget = replayedTarget(t, t.ReplayRequests[0])
+	}
+	req, err := buildRequest(reqTarget, body)
+	if err == nil && t.Query != "" {
+		var variables *template.Template
+		variables, err = parseGraphQLVariables(t.Variables)
+		if err == nil {
+			err = setGraphQLBody(&req, t, variables, 0)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+//what the exported request can't do that pewpew does, for the program exporting it
+func exportNotes(t Target, program string) []string {
+	var notes []string
+	if t.HMACKey != "" {
+		notes = append(notes, "requests are signed with HMAC-SHA256 as they are sent, which "+program+" doesn't do")
+	}
+	if t.Signer != nil {
+		notes = append(notes, "requests are signed by a RequestSigner as they are sent, which "+program+" doesn't do")
+	}
+	if program != "curl" {
+		if t.DigestAuthUser != "" {
+			notes = append(notes, "Digest auth challenges aren't answered by "+program)
+		}
+		if t.AWSAccessKeyID != "" {
+			notes = append(notes, "requests are signed with AWS Signature Version 4 as they are sent, which "+program+" doesn't do")
+		}
+		if t.HTTP3 {
+			notes = append(notes, "pewpew sends this over HTTP/3, which net/http doesn't")
+		}
+	}
+	return notes
+}
+
+//the whole body of req
+func exportBody(req *http.Request) ([]byte, error) {
+	if req.ContentLength > maxExportedBodySize {
+		return nil, errors.New("body is too large to export: " + strconv.FormatInt(req.ContentLength, 10) + " bytes")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return ioutil.ReadAll(body)
+}
+
+//the curl options sending the body of req, and the command piping it to curl's stdin if it needs one.
+//multipart is set when curl builds the body itself.
+func curlBody(t Target, req *http.Request) (options []string, pipe string, multipart bool, err error) {
+	encoder := curlBodyEncoders[t.BodyEncoding]
+	switch {
+	case req.Body == nil || (req.Body == http.NoBody && t.BodyFilename == ""):
+		return nil, "", false, nil
+	case t.BodyFilename != "" && encoder == "":
+		return []string{"--data-binary " + shellQuote("@"+t.BodyFilename)}, "", false, nil
+	case t.BodyFilename != "":
+		return []string{"--data-binary @-"}, encoder + " < " + shellQuote(t.BodyFilename), false, nil
+	case t.BodySize != "":
+		size, _ := parseByteSize(t.BodySize)
+		source := "/dev/zero"
+		if t.BodyRandom {
+			source = "/dev/urandom"
+		}
+		pipe = "head -c " + strconv.FormatInt(size, 10) + " " + source
+		if encoder != "" {
+			pipe += " | " + encoder
+		}
+		return []string{"--data-binary @-"}, pipe, false, nil
+	case len(t.Multipart) > 0 && encoder == "":
+		fields, _ := parseMultipartFields(t.Multipart)
+		for i, field := range fields {
+			if field.filename != "" {
+				options = append(options, "-F "+shellQuote(t.Multipart[i]))
+			} else {
+				options = append(options, "--form-string "+shellQuote(field.name+"="+field.value))
+			}
+		}
+		return options, "", true, nil
+	}
+	//the rest are held in memory, and sent exactly as they are, already encoded
+	data, err := exportBody(req)
+	if err != nil {
+		return nil, "", false, err
+	}
+	if t.BodyEncoding == "" && isPrintableText(data) {
+		return []string{"--data-raw " + shellQuote(string(data))}, "", false, nil
+	}
+	return []string{"--data-binary @-"}, "printf " + printfQuote(data), false, nil
+}
+
+//a duration like "2.5s" as curl's seconds, like "2.5"
+func curlSecondsArg(duration string) string {
+	return strconv.FormatFloat(parseOptionalDuration(duration).Seconds(), 'f', -1, 64)
+}
+
+//a duration as a Go expression, like 10 * time.Second
+func goDuration(d time.Duration) string {
+	switch {
+	case d == 0:
+		return "0"
+	case d%time.Second == 0:
+		return strconv.FormatInt(int64(d/time.Second), 10) + " * time.Second"
+	case d%time.Millisecond == 0:
+		return strconv.FormatInt(int64(d/time.Millisecond), 10) + " * time.Millisecond"
+	}
+	return strconv.FormatInt(int64(d), 10)
+}
+
+func sortedHeaderNames(header http.Header) []string {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//whether data is text that can be passed as a command line argument
+func isPrintableText(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	for _, c := range data {
+		if (c < 0x20 && c != '\t' && c != '\n' && c != '\r') || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+//quote s as a single word for a POSIX shell, using bash's $'...' for control characters
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	safe, printable := true, true
+	for _, c := range []byte(s) {
+		if c < 0x20 || c == 0x7f {
+			printable = false
+		}
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("@%+=:,./_-", c) != -1) {
+			safe = false
+		}
+	}
+	switch {
+	case safe:
+		return s
+	case printable:
+		return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+	}
+	var quoted strings.Builder
+	quoted.WriteString("$'")
+	for _, c := range []byte(s) {
+		switch {
+		case c == '\'' || c == '\\':
+			quoted.WriteByte('\\')
+			quoted.WriteByte(c)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&quoted, `\x%02x`, c)
+		default:
+			quoted.WriteByte(c)
+		}
+	}
+	quoted.WriteByte('\'')
+	return quoted.String()
+}
+
+//quote data as a printf format printing it exactly, as command line arguments can't hold NUL bytes
+func printfQuote(data []byte) string {
+	var quoted strings.Builder
+	quoted.WriteByte('\'')
+	for _, c := range data {
+		switch {
+		case c == '%':
+			quoted.WriteString("%%")
+		case c >= 0x20 && c < 0x7f && c != '\'' && c != '\\':
+			quoted.WriteByte(c)
+		default:
+			fmt.Fprintf(&quoted, `\%03o`, c)
+		}
+	}
+	quoted.WriteByte('\'')
+	return quoted.String()
+}
@@ -0,0 +1,147 @@
+package pewpew
+
+import (
+	"go/parser"
+	"go/token"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCurlCommand(t *testing.T) {
+	cases := []struct {
+		t       Target
+		s       StressConfig
+		want    string
+		wantErr bool
+	}{
+		{Target{URL: "https://example.com/a?b=c", Method: "GET", UserAgent: "pewpew"}, StressConfig{},
+			"curl \\\n  -k \\\n  -A pewpew \\\n  'https://example.com/a?b=c'", false},
+		{Target{URL: "http://example.com/orders", Method: "POST", Body: `{"note":"it's"}`, UserAgent: "pewpew",
+			HeaderList: []string{"Content-Type: application/json"}, CookieList: []string{"a=1", "b=2"},
+			BasicAuthUser: "admin", BasicAuthPass: "secret", Compress: true, FollowRedirects: true,
+			Timeout: "2500ms", Resolve: []string{"example.com:80:127.0.0.1"}}, StressConfig{NoHTTP2: true},
+			`curl \
+  --http1.1 \
+  --compressed \
+  -H 'Authorization: Basic YWRtaW46c2VjcmV0' \
+  -H 'Content-Type: application/json' \
+  -b 'a=1; b=2' \
+  -A pewpew \
+  -L \
+  --max-time 2.5 \
+  --resolve example.com:80:127.0.0.1 \
+  --data-raw '{"note":"it'\''s"}' \
+  http://example.com/orders`, false},
+		{Target{URL: "https://example.com/upload", Method: "PUT", Multipart: []string{"name=pew", "file=@" + tempFilename + ";type=text/plain"}},
+			StressConfig{EnforceSSL: true},
+			"curl \\\n  -X PUT \\\n  -A '' \\\n  --form-string name=pew \\\n  -F 'file=@" + tempFilename + ";type=text/plain' \\\n  https://example.com/upload", false},
+		{Target{URL: "https://example.com/", Method: "POST", BodyFilename: tempFilename, BodyEncoding: "gzip", DigestAuthUser: "u", DigestAuthPass: "p"},
+			StressConfig{EnforceSSL: true},
+			"gzip -c < " + tempFilename + " | curl \\\n  -H 'Content-Encoding: gzip' \\\n  -A '' \\\n  -H 'Content-Type:' \\\n  --digest \\\n  -u u:p \\\n  --data-binary @- \\\n  https://example.com/", false},
+		{Target{URL: "https://example.com/", Method: "GET", BodySize: "1KB", HMACKey: "key", HTTP3: true},
+			StressConfig{EnforceSSL: true},
+			"# requests are signed with HMAC-SHA256 as they are sent, which curl doesn't do\nhead -c 1024 /dev/zero | curl \\\n  --http3 \\\n  -X GET \\\n  -A '' \\\n  -H 'Content-Type:' \\\n  --data-binary @- \\\n  https://example.com/", false},
+		{Target{URL: "http://example.com/", Method: "HEAD", Body: "a\x00%b", OAuth2TokenURL: "http://auth.example.com/token"}, StressConfig{},
+			"# OAUTH2_TOKEN must hold an access token from http://auth.example.com/token\nprintf 'a\\000%%b' | curl \\\n  -X HEAD \\\n  -A '' \\\n  -H 'Content-Type:' \\\n  --oauth2-bearer \"$OAUTH2_TOKEN\" \\\n  --data-binary @- \\\n  http://example.com/", false},
+		{Target{URL: "ws://example.com/", Method: "GET"}, StressConfig{}, "", true},
+		{Target{URL: "grpc://example.com/", Method: "GET"}, StressConfig{}, "", true},
+		{Target{URL: "http://example.com/", Method: "GET", BodyFilename: "/does/not/exist"}, StressConfig{}, "", true},
+	}
+	for _, c := range cases {
+		command, err := CurlCommand(c.t, c.s)
+		if (err != nil) != c.wantErr {
+			t.Errorf("CurlCommand(%+v) err: %v wanted error: %t", c.t, err, c.wantErr)
+			continue
+		}
+		if c.want != "" && command != c.want {
+			t.Errorf("CurlCommand(%+v) ==\n%s\nwanted\n%s", c.t, command, c.want)
+		}
+	}
+
+	//a generated URL, and a GraphQL query that's sent as a POST
+	command, err := CurlCommand(Target{URL: `http://example\.com/[a-c]{3}`, RegexURL: true, Query: "{ a }", Method: "GET"}, StressConfig{})
+	if err != nil {
+		t.Fatalf("CurlCommand of a GraphQL target err: %s", err)
+	}
+	lines := strings.Split(command, "\n")
+	if lines[0] != `# one of the URLs generated from http://example\.com/[a-c]{3}` ||
+		!strings.Contains(command, `--data-raw '{"query":"{ a }"}'`) || strings.Contains(command, "-X") ||
+		!regexp.MustCompile(`^  http://example\.com/[a-c]{3}$`).MatchString(lines[len(lines)-1]) {
+		t.Errorf("CurlCommand of a GraphQL target ==\n%s", command)
+	}
+
+	//what curl would be run with is the same request
+	target := Target{URL: "https://example.com/a", Method: "PATCH", Body: "x=1&y=2", UserAgent: "Mozilla/5.0",
+		HeaderList: []string{"Accept: application/json", "Content-Type: application/x-www-form-urlencoded"},
+		CookieList: []string{"session=abc"}, Compress: true, Timeout: "3s"}
+	command, err = CurlCommand(target, StressConfig{})
+	if err != nil {
+		t.Fatalf("CurlCommand(%+v) err: %s", target, err)
+	}
+	parsed, err := TargetFromCurl(command)
+	if err != nil {
+		t.Fatalf("TargetFromCurl(%s) err: %s", command, err)
+	}
+	if !reflect.DeepEqual(parsed, target) {
+		t.Errorf("TargetFromCurl(CurlCommand()) == %+v wanted %+v", parsed, target)
+	}
+}
+
+func TestGoTest(t *testing.T) {
+	s := StressConfig{
+		NoHTTP2: true,
+		Targets: []Target{
+			{URL: "http://example.com/a", Method: "POST", Body: "a\nb", UserAgent: "pewpew", Timeout: "1500ms", Resolve: []string{"example.com:80:127.0.0.1"}},
+			{URL: "ws://example.com/", Method: "GET"},
+			{URL: "https://example.com/b", Method: "PUT", BodyFilename: tempFilename, FollowRedirects: true, HMACKey: "key"},
+			{URL: "https://example.com/c", Method: "POST", BodySize: "1KB", BodyRandom: true, OAuth2TokenURL: "https://auth.example.com/token"},
+		},
+	}
+	test, err := GoTest(s)
+	if err != nil {
+		t.Fatalf("GoTest err: %s", err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "repro_test.go", test, 0); err != nil {
+		t.Fatalf("GoTest made an invalid file: %s\n%s", err, test)
+	}
+	for _, want := range []string{
+		"package repro",
+		`"context"`, `"crypto/rand"`, `"crypto/tls"`, `"time"`, `"os"`,
+		"func TestTarget1(t *testing.T) {",
+		"body := []byte(\"a\\nb\")",
+		`req, err := http.NewRequest("POST", "http://example.com/a", bytes.NewReader(body))`,
+		`"User-Agent": {"pewpew"},`,
+		`"example.com:80": "127.0.0.1:80",`,
+		"client := &http.Client{Transport: transport, Timeout: 1500 * time.Millisecond}",
+		"TLSNextProto:    map[string]func(string, *tls.Conn) http.RoundTripper{},",
+		"// Target 2, ws://example.com/, can't be sent with net/http",
+		"// requests are signed with HMAC-SHA256 as they are sent, which this test doesn't do",
+		`body, err := ioutil.ReadFile("` + tempFilename + `")`,
+		"body := make([]byte, 1024)",
+		`req.Header.Set("Authorization", "Bearer "+os.Getenv("OAUTH2_TOKEN"))`,
+	} {
+		if !strings.Contains(test, want) {
+			t.Errorf("GoTest is missing %s:\n%s", want, test)
+		}
+	}
+	//redirects are only followed by the target that follows them
+	if strings.Count(test, "http.ErrUseLastResponse") != 2 {
+		t.Errorf("GoTest should stop redirects of 2 targets:\n%s", test)
+	}
+
+	if _, err := GoTest(StressConfig{Targets: []Target{{URL: "http://example.com/", Method: "GET", BodyFilename: "/does/not/exist"}}}); err == nil {
+		t.Errorf("GoTest with a missing body file succeeded")
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	cases := []string{"", "simple", "a b", "it's", `a"b\c`, "tab\there", "new\nline", "$HOME", "\x01'\\"}
+	for _, c := range cases {
+		words, err := splitShellWords(shellQuote(c))
+		if err != nil || len(words) != 1 || words[0] != c {
+			t.Errorf("shellQuote(%q) == %s, split back into %q, %v", c, shellQuote(c), words, err)
+		}
+	}
+}
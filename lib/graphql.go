@@ -0,0 +1,133 @@
+package pewpew
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+//DefaultOperationName groups GraphQL requests whose operation has no name
+const DefaultOperationName = "anonymous"
+
+//GraphQLStat is the saved information about a GraphQL request
+type GraphQLStat struct {
+	OperationName string `json:"operationName"`
+	//messages of the errors array in the response, which makes the request count as failed
+	Errors []string `json:"errors"`
+}
+
+//values available to the Variables template of a GraphQL target
+type graphQLData struct {
+	//index of the request within the target, starting at 0
+	Seq int
+}
+
+//matches the name of the first operation in a query, like "query GetUser($id: ID!) {"
+var operationNameRegexp = regexp.MustCompile(`^\s*(?:query|mutation|subscription)\s+([_A-Za-z][_0-9A-Za-z]*)`)
+
+//name the target's requests are grouped by: its OperationName, or else the name of the operation in its Query
+func graphQLOperationName(t Target) string {
+	if t.OperationName != "" {
+		return t.OperationName
+	}
+	if match := operationNameRegexp.FindStringSubmatch(t.Query); match != nil {
+		return match[1]
+	}
+	return DefaultOperationName
+}
+
+//parse the Variables template of a GraphQL target
+func parseGraphQLVariables(variables string) (*template.Template, error) {
+	if variables == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New("variables").Parse(variables)
+	if err != nil {
+		return nil, errors.New("failed to parse GraphQL variables template: " + err.Error())
+	}
+	//catch bad JSON and references to fields that don't exist before the test starts
+	if _, err := executeGraphQLVariables(tmpl, 0); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+//fill in the variables of request seq, which must be a JSON object
+func executeGraphQLVariables(tmpl *template.Template, seq int) (json.RawMessage, error) {
+	var variables bytes.Buffer
+	if err := tmpl.Execute(&variables, graphQLData{Seq: seq}); err != nil {
+		return nil, errors.New("failed to execute GraphQL variables template: " + err.Error())
+	}
+	var object map[string]interface{}
+	if err := json.Unmarshal(variables.Bytes(), &object); err != nil {
+		return nil, errors.New("GraphQL variables must be a JSON object: " + err.Error())
+	}
+	return json.RawMessage(variables.Bytes()), nil
+}
+
+//make req a POST of the target's GraphQL query, with the variables of request seq
+//from its Variables template, parsed once for all of its requests by parseGraphQLVariables
+func setGraphQLBody(req *http.Request, t Target, variables *template.Template, seq int) error {
+	body := struct {
+		Query         string          `json:"query"`
+		Variables     json.RawMessage `json:"variables,omitempty"`
+		OperationName string          `json:"operationName,omitempty"`
+	}{
+		Query:         t.Query,
+		OperationName: t.OperationName,
+	}
+	if variables != nil {
+		var err error
+		body.Variables, err = executeGraphQLVariables(variables, seq)
+		if err != nil {
+			return err
+		}
+	}
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return errors.New("failed to encode GraphQL request: " + err.Error())
+	}
+	req.Method = http.MethodPost
+	req.Body = ioutil.NopCloser(bytes.NewReader(bodyBytes))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(bodyBytes)), nil
+	}
+	req.ContentLength = int64(len(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+	return nil
+}
+
+//record the operation of a GraphQL request, and fail it when a 200 response has errors
+func checkGraphQLResponse(response *http.Response, t Target, stat RequestStat) RequestStat {
+	stat.GraphQL = &GraphQLStat{OperationName: graphQLOperationName(t)}
+	if stat.Error != nil || response == nil || response.StatusCode != http.StatusOK {
+		return stat
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	//leave the body to be printed in verbose mode
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return stat
+	}
+	var result struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if json.Unmarshal(body, &result) != nil || len(result.Errors) == 0 {
+		return stat
+	}
+	for _, graphQLErr := range result.Errors {
+		stat.GraphQL.Errors = append(stat.GraphQL.Errors, graphQLErr.Message)
+	}
+	stat.Error = errors.New("GraphQL errors: " + strings.Join(stat.GraphQL.Errors, "; "))
+	stat.ErrorKind = ErrorGraphQL
+	return stat
+}
@@ -0,0 +1,103 @@
+package pewpew
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGraphQLOperationName(t *testing.T) {
+	cases := []struct {
+		target Target
+		want   string
+	}{
+		{Target{Query: "{ user { name } }"}, DefaultOperationName},
+		{Target{Query: "query { user { name } }"}, DefaultOperationName},
+		{Target{Query: "query GetUser($id: ID!) { user(id: $id) { name } }"}, "GetUser"},
+		{Target{Query: "\n  mutation AddUser { addUser { id } }"}, "AddUser"},
+		{Target{Query: "query A { a } query B { b }", OperationName: "B"}, "B"},
+	}
+	for _, c := range cases {
+		if got := graphQLOperationName(c.target); got != c.want {
+			t.Errorf("graphQLOperationName(%q) == %q wanted %q", c.target.Query, got, c.want)
+		}
+	}
+}
+
+func TestParseGraphQLVariables(t *testing.T) {
+	cases := []struct {
+		variables string
+		hasErr    bool
+	}{
+		{"", false},
+		{`{"id": 1}`, false},
+		{`{"id": {{.Seq}}, "name": "user{{.Seq}}"}`, false},
+		{`{"id": {{.Seq}`, true},    //unterminated action
+		{`{"id": {{.Nope}}}`, true}, //unknown field
+		{`[1, 2]`, true},            //not an object
+		{`{"id": }`, true},          //invalid JSON
+	}
+	for _, c := range cases {
+		_, err := parseGraphQLVariables(c.variables)
+		if (err != nil) != c.hasErr {
+			t.Errorf("parseGraphQLVariables(%q) err: %t wanted %t", c.variables, (err != nil), c.hasErr)
+		}
+	}
+}
+
+func TestRunStressGraphQL(t *testing.T) {
+	//users with an even ID exist
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Query     string `json:"query"`
+			Variables struct {
+				ID int `json:"id"`
+			} `json:"variables"`
+		}
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" ||
+			json.NewDecoder(r.Body).Decode(&body) != nil || body.Query == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if body.Variables.ID%2 == 1 {
+			fmt.Fprintf(w, `{"data": {"user": null}, "errors": [{"message": "user %d not found"}]}`, body.Variables.ID)
+			return
+		}
+		fmt.Fprintf(w, `{"data": {"user": {"id": %d}}}`, body.Variables.ID)
+	}))
+	defer server.Close()
+
+	target := Target{
+		URL:         server.URL,
+		Method:      DefaultMethod,
+		Count:       10,
+		Concurrency: 2,
+		Query:       "query GetUser($id: ID!) { user(id: $id) { id } }",
+		Variables:   `{"id": {{.Seq}}}`,
+	}
+	targetStats, err := RunStress(StressConfig{Targets: []Target{target}, Quiet: true}, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("RunStress(%+v) err: %s", target, err)
+	}
+	failures := 0
+	for _, stat := range targetStats[0] {
+		if stat.GraphQL == nil || stat.GraphQL.OperationName != "GetUser" {
+			t.Fatalf("GraphQL request has stats %+v wanted operation GetUser", stat.GraphQL)
+		}
+		if stat.StatusCode != http.StatusOK {
+			t.Errorf("GraphQL request status code == %d wanted %d", stat.StatusCode, http.StatusOK)
+		}
+		if stat.Error != nil {
+			failures++
+			if stat.ErrorKind != ErrorGraphQL || len(stat.GraphQL.Errors) != 1 {
+				t.Errorf("GraphQL request failed with %s (%s), errors %q", stat.Error, stat.ErrorKind, stat.GraphQL.Errors)
+			}
+		}
+	}
+	if failures != 5 {
+		t.Errorf("%d GraphQL requests failed wanted 5", failures)
+	}
+}
@@ -0,0 +1,364 @@
+package pewpew
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	credentials "google.golang.org/grpc/credentials"
+	insecure "google.golang.org/grpc/credentials/insecure"
+	gzip "google.golang.org/grpc/encoding/gzip"
+	metadata "google.golang.org/grpc/metadata"
+	peer "google.golang.org/grpc/peer"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	status "google.golang.org/grpc/status"
+	protojson "google.golang.org/protobuf/encoding/protojson"
+	proto "google.golang.org/protobuf/proto"
+	protodesc "google.golang.org/protobuf/reflect/protodesc"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoregistry "google.golang.org/protobuf/reflect/protoregistry"
+	descriptorpb "google.golang.org/protobuf/types/descriptorpb"
+	dynamicpb "google.golang.org/protobuf/types/dynamicpb"
+)
+
+//GRPCStat is the saved information about one gRPC call.
+//The gRPC status takes the place of the HTTP status code of the RequestStat it belongs to.
+type GRPCStat struct {
+	//gRPC status code, e.g. "OK", "NotFound", "Unavailable"
+	Code string `json:"code"`
+	//status message sent along with a code other than OK
+	Message          string `json:"message"`
+	MessagesSent     int    `json:"messagesSent"`
+	MessagesReceived int    `json:"messagesReceived"`
+	//for streaming calls, time until each message received since the previous message
+	//sent or received on the stream, whichever was later
+	MessageLatencies []time.Duration `json:"messageLatencies"`
+}
+
+//grpcCall is a target's gRPC method, resolved and ready to be called
+type grpcCall struct {
+	//like "/package.Service/Method"
+	fullMethod string
+	method     protoreflect.MethodDescriptor
+	//request messages, parsed from the target's JSON
+	messages []proto.Message
+}
+
+func isGRPCURL(url string) bool {
+	return strings.HasPrefix(url, "grpc://") || strings.HasPrefix(url, "grpcs://")
+}
+
+//split a method like "package.Service/Method" into its service and method names
+func parseGRPCMethod(methodStr string) (service string, method string, err error) {
+	parts := strings.Split(strings.TrimPrefix(methodStr, "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", errors.New("gRPC method must be like package.Service/Method: " + methodStr)
+	}
+	return parts[0], parts[1], nil
+}
+
+//create a connection to a gRPC target, connecting from the target's source IPs in turn
+func createGRPCConn(target Target, s StressConfig, sources *sourceIPs) (*grpc.ClientConn, error) {
+	URL, err := url.Parse(target.URL)
+	if err != nil {
+		return nil, errors.New("failed to parse URL " + target.URL + " : " + err.Error())
+	}
+	dialer := &net.Dialer{Timeout: parseOptionalDuration(target.DialTimeout)}
+	dialContext := sources.dialContext(dialer)
+	opts := []grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return dialContext(ctx, "tcp", addr)
+		}),
+		grpc.WithUserAgent(target.UserAgent),
+	}
+	if strings.HasPrefix(target.URL, "grpcs://") {
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{InsecureSkipVerify: !s.EnforceSSL})))
+	} else {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+	if target.Compress {
+		opts = append(opts, grpc.WithDefaultCallOptions(grpc.UseCompressor(gzip.Name)))
+	}
+	return grpc.NewClient(URL.Host, opts...)
+}
+
+//resolve the target's method, from its descriptor set file or else the server's reflection service,
+//and parse its request messages
+func newGRPCCall(target Target, s StressConfig) (*grpcCall, error) {
+	serviceName, methodName, err := parseGRPCMethod(target.GRPCMethod)
+	if err != nil {
+		return nil, err
+	}
+	var files *protoregistry.Files
+	if target.GRPCDescriptorSet != "" {
+		files, err = loadDescriptorSet(target.GRPCDescriptorSet)
+	} else {
+		files, err = reflectServiceFiles(target, s, serviceName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(serviceName))
+	if err != nil {
+		return nil, errors.New("failed to find gRPC service " + serviceName + ": " + err.Error())
+	}
+	service, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, errors.New(serviceName + " is not a gRPC service")
+	}
+	method := service.Methods().ByName(protoreflect.Name(methodName))
+	if method == nil {
+		return nil, errors.New("gRPC service " + serviceName + " has no method " + methodName)
+	}
+
+	messagesJSON := target.GRPCMessages
+	if len(messagesJSON) == 0 {
+		messagesJSON = []string{"{}"}
+	}
+	if len(messagesJSON) > 1 && !method.IsStreamingClient() {
+		return nil, errors.New("gRPC method " + methodName + " takes a single request message")
+	}
+	call := &grpcCall{
+		fullMethod: "/" + serviceName + "/" + methodName,
+		method:     method,
+	}
+	for _, messageJSON := range messagesJSON {
+		message := dynamicpb.NewMessage(method.Input())
+		if err := protojson.Unmarshal([]byte(messageJSON), message); err != nil {
+			return nil, errors.New("failed to parse gRPC message " + messageJSON + ": " + err.Error())
+		}
+		call.messages = append(call.messages, message)
+	}
+	return call, nil
+}
+
+//load a FileDescriptorSet, as written by protoc --include_imports --descriptor_set_out
+func loadDescriptorSet(filename string) (*protoregistry.Files, error) {
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.New("failed to read descriptor set " + filename + ": " + err.Error())
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(contents, set); err != nil {
+		return nil, errors.New("failed to parse descriptor set " + filename + ": " + err.Error())
+	}
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, errors.New("invalid descriptor set " + filename + ": " + err.Error())
+	}
+	return files, nil
+}
+
+//ask the server's reflection service for the file defining serviceName and everything it imports
+func reflectServiceFiles(target Target, s StressConfig, serviceName string) (*protoregistry.Files, error) {
+	conn, err := createGRPCConn(target, s, newSourceIPs(target.SourceIPs, target.Resolve))
+	if err != nil {
+		return nil, errors.New("failed to connect for gRPC reflection: " + err.Error())
+	}
+	defer conn.Close()
+	ctx := context.Background()
+	if timeout := parseOptionalDuration(target.Timeout); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, errors.New("gRPC reflection failed: " + err.Error())
+	}
+	defer stream.CloseSend()
+
+	fileProtos := make(map[string]*descriptorpb.FileDescriptorProto)
+	request := &reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: serviceName},
+	}
+	for request != nil {
+		if err := stream.Send(request); err != nil {
+			return nil, errors.New("gRPC reflection failed: " + err.Error())
+		}
+		response, err := stream.Recv()
+		if err != nil {
+			return nil, errors.New("gRPC reflection failed: " + err.Error())
+		}
+		if errResponse := response.GetErrorResponse(); errResponse != nil {
+			return nil, errors.New("gRPC reflection failed: " + errResponse.GetErrorMessage())
+		}
+		for _, raw := range response.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			fileProto := &descriptorpb.FileDescriptorProto{}
+			if err := proto.Unmarshal(raw, fileProto); err != nil {
+				return nil, errors.New("gRPC reflection sent an invalid file descriptor: " + err.Error())
+			}
+			fileProtos[fileProto.GetName()] = fileProto
+		}
+		//servers usually send the imports along, ask for any that are missing
+		request = nil
+		for _, fileProto := range fileProtos {
+			for _, dependency := range fileProto.GetDependency() {
+				if _, ok := fileProtos[dependency]; !ok {
+					request = &reflectionpb.ServerReflectionRequest{
+						MessageRequest: &reflectionpb.ServerReflectionRequest_FileByFilename{FileByFilename: dependency},
+					}
+					break
+				}
+			}
+			if request != nil {
+				break
+			}
+		}
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, fileProto := range fileProtos {
+		set.File = append(set.File, fileProto)
+	}
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, errors.New("gRPC reflection sent invalid file descriptors: " + err.Error())
+	}
+	return files, nil
+}
+
+//make one gRPC call, sending the request's headers as metadata
+func runGRPC(req http.Request, conn *grpc.ClientConn, call *grpcCall, target Target) (stat RequestStat) {
+	ctx := context.Background()
+	if timeout := parseOptionalDuration(target.Timeout); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	md := metadata.MD{}
+	for key, vals := range req.Header {
+		//sent by the connection itself
+		if key == "User-Agent" {
+			continue
+		}
+		md.Append(strings.ToLower(key), vals...)
+	}
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	grpcStat := &GRPCStat{}
+	var callPeer peer.Peer
+	var dataTransferred int
+	reqStartTime := time.Now()
+	var err error
+	if !call.method.IsStreamingClient() && !call.method.IsStreamingServer() {
+		response := dynamicpb.NewMessage(call.method.Output())
+		err = conn.Invoke(ctx, call.fullMethod, call.messages[0], response, grpc.Peer(&callPeer))
+		grpcStat.MessagesSent = 1
+		dataTransferred += proto.Size(call.messages[0])
+		if err == nil {
+			grpcStat.MessagesReceived = 1
+			dataTransferred += proto.Size(response)
+		}
+	} else {
+		err = runGRPCStream(ctx, conn, call, grpcStat, &dataTransferred, &callPeer)
+	}
+	reqEndTime := time.Now()
+
+	callStatus := status.Convert(err)
+	grpcStat.Code = callStatus.Code().String()
+	if callStatus.Code() != codes.OK {
+		grpcStat.Message = callStatus.Message()
+	}
+	stat = RequestStat{
+		Proto:           "gRPC",
+		URL:             req.URL.String(),
+		Method:          call.fullMethod,
+		StartTime:       reqStartTime,
+		EndTime:         reqEndTime,
+		Duration:        reqEndTime.Sub(reqStartTime),
+		DataTransferred: dataTransferred,
+		GRPC:            grpcStat,
+	}
+	//a call that never got an answer fails like a request would, other codes are the server's answer
+	switch callStatus.Code() {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Canceled:
+		stat.Error = err
+		stat.ErrorKind = grpcErrorKind(callStatus, err)
+	}
+	if callPeer.LocalAddr != nil {
+		if host, _, err := net.SplitHostPort(callPeer.LocalAddr.String()); err == nil {
+			stat.SourceIP = host
+		}
+	}
+	return
+}
+
+//what kind of failure a call's status is, as the dial errors behind it only survive in its message
+func grpcErrorKind(callStatus *status.Status, err error) string {
+	switch {
+	case callStatus.Code() == codes.DeadlineExceeded:
+		return ErrorTimeout
+	case strings.Contains(callStatus.Message(), "connection refused"):
+		return ErrorConnRefused
+	case strings.Contains(callStatus.Message(), "connection reset"):
+		return ErrorConnReset
+	}
+	return classifyError(err)
+}
+
+//send the call's messages on a stream while receiving the replies, timing each reply
+func runGRPCStream(ctx context.Context, conn *grpc.ClientConn, call *grpcCall, grpcStat *GRPCStat, dataTransferred *int, callPeer *peer.Peer) error {
+	desc := &grpc.StreamDesc{
+		StreamName:    string(call.method.Name()),
+		ServerStreams: call.method.IsStreamingServer(),
+		ClientStreams: call.method.IsStreamingClient(),
+	}
+	stream, err := conn.NewStream(ctx, desc, call.fullMethod, grpc.Peer(callPeer))
+	if err != nil {
+		return err
+	}
+	var statLock sync.Mutex
+	//when the last message was sent or received
+	lastMessageTime := time.Now()
+
+	sendDone := make(chan struct{})
+	go func() {
+		defer close(sendDone)
+		for _, message := range call.messages {
+			//the reason the stream broke is returned by RecvMsg
+			if stream.SendMsg(message) != nil {
+				return
+			}
+			statLock.Lock()
+			lastMessageTime = time.Now()
+			grpcStat.MessagesSent++
+			*dataTransferred += proto.Size(message)
+			statLock.Unlock()
+		}
+		stream.CloseSend()
+	}()
+
+	for {
+		response := dynamicpb.NewMessage(call.method.Output())
+		err = stream.RecvMsg(response)
+		receivedTime := time.Now()
+		if err != nil {
+			break
+		}
+		statLock.Lock()
+		grpcStat.MessagesReceived++
+		grpcStat.MessageLatencies = append(grpcStat.MessageLatencies, receivedTime.Sub(lastMessageTime))
+		lastMessageTime = receivedTime
+		*dataTransferred += proto.Size(response)
+		statLock.Unlock()
+		if !desc.ServerStreams {
+			//the one reply ends the call
+			break
+		}
+	}
+	<-sendDone
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,203 @@
+package pewpew
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	metadata "google.golang.org/grpc/metadata"
+	reflection "google.golang.org/grpc/reflection"
+	status "google.golang.org/grpc/status"
+	proto "google.golang.org/protobuf/proto"
+	protodesc "google.golang.org/protobuf/reflect/protodesc"
+	descriptorpb "google.golang.org/protobuf/types/descriptorpb"
+)
+
+//health service that knows one service, never answers about "slow", and streams three statuses to watchers
+type testHealthServer struct {
+	healthpb.UnimplementedHealthServer
+}
+
+func (testHealthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if req.Service == "slow" {
+		<-ctx.Done()
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	if req.Service != "pewpew" {
+		return nil, status.Error(codes.NotFound, "unknown service")
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if len(md.Get("x-required")) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "missing header")
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+func (testHealthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	for _, s := range []healthpb.HealthCheckResponse_ServingStatus{
+		healthpb.HealthCheckResponse_NOT_SERVING,
+		healthpb.HealthCheckResponse_SERVING,
+		healthpb.HealthCheckResponse_NOT_SERVING,
+	} {
+		if err := stream.Send(&healthpb.HealthCheckResponse{Status: s}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestParseGRPCMethod(t *testing.T) {
+	cases := []struct {
+		method  string
+		service string
+		name    string
+		hasErr  bool
+	}{
+		{"grpc.health.v1.Health/Check", "grpc.health.v1.Health", "Check", false},
+		{"/grpc.health.v1.Health/Check", "grpc.health.v1.Health", "Check", false},
+		{"", "", "", true},
+		{"grpc.health.v1.Health", "", "", true},
+		{"grpc.health.v1.Health/", "", "", true},
+		{"a/b/c", "", "", true},
+	}
+	for _, c := range cases {
+		service, name, err := parseGRPCMethod(c.method)
+		if (err != nil) != c.hasErr {
+			t.Errorf("parseGRPCMethod(%q) err: %t wanted %t", c.method, (err != nil), c.hasErr)
+			continue
+		}
+		if service != c.service || name != c.name {
+			t.Errorf("parseGRPCMethod(%q) == %q, %q wanted %q, %q", c.method, service, name, c.service, c.name)
+		}
+	}
+}
+
+func TestRunStressGRPC(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, testHealthServer{})
+	reflection.Register(server)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	//descriptor set for the health service, for servers without reflection
+	dir, err := ioutil.TempDir("", "pewpew")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	descriptorSet, _ := proto.Marshal(&descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(healthpb.File_grpc_health_v1_health_proto)},
+	})
+	descriptorSetFile := filepath.Join(dir, "health.protoset")
+	if err := ioutil.WriteFile(descriptorSetFile, descriptorSet, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		target           Target
+		code             string
+		messagesReceived int
+	}{
+		{Target{GRPCMethod: "grpc.health.v1.Health/Check", GRPCMessages: []string{`{"service": "pewpew"}`}, Headers: "X-Required: yes"}, "OK", 1},
+		{Target{GRPCMethod: "grpc.health.v1.Health/Check", GRPCMessages: []string{`{"service": "pewpew"}`}, Headers: "X-Required: yes", GRPCDescriptorSet: descriptorSetFile}, "OK", 1},
+		{Target{GRPCMethod: "grpc.health.v1.Health/Check", GRPCMessages: []string{`{"service": "pewpew"}`}}, "InvalidArgument", 0},
+		{Target{GRPCMethod: "grpc.health.v1.Health/Check", GRPCMessages: []string{`{"service": "other"}`}}, "NotFound", 0},
+		//server streaming
+		{Target{GRPCMethod: "grpc.health.v1.Health/Watch"}, "OK", 3},
+		//bidirectional streaming
+		{Target{GRPCMethod: "grpc.reflection.v1.ServerReflection/ServerReflectionInfo",
+			GRPCMessages: []string{`{"listServices": ""}`, `{"fileContainingSymbol": "grpc.health.v1.Health"}`}}, "OK", 2},
+	}
+	for _, c := range cases {
+		c.target.URL = "grpc://" + listener.Addr().String()
+		c.target.Method = DefaultMethod
+		c.target.Count = 4
+		c.target.Concurrency = 2
+		c.target.Timeout = "5s"
+		targetStats, err := RunStress(StressConfig{Targets: []Target{c.target}, Quiet: true}, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("RunStress(%+v) err: %s", c.target, err)
+		}
+		for _, stat := range targetStats[0] {
+			if stat.Error != nil {
+				t.Fatalf("gRPC call failed: %s", stat.Error)
+			}
+			if stat.GRPC == nil {
+				t.Fatalf("gRPC call of %+v has no stats", c.target)
+			}
+			if stat.GRPC.Code != c.code {
+				t.Errorf("gRPC call of %+v code == %s wanted %s (%s)", c.target, stat.GRPC.Code, c.code, stat.GRPC.Message)
+			}
+			if stat.GRPC.MessagesReceived != c.messagesReceived {
+				t.Errorf("gRPC call of %+v received %d messages wanted %d", c.target, stat.GRPC.MessagesReceived, c.messagesReceived)
+			}
+			if stat.Method != "/"+c.target.GRPCMethod {
+				t.Errorf("gRPC call of %+v method == %s", c.target, stat.Method)
+			}
+		}
+		//only streams time their messages
+		if streaming := targetStats[0][0].GRPC.MessagesReceived > 1; streaming != (len(targetStats[0][0].GRPC.MessageLatencies) > 0) {
+			t.Errorf("gRPC call of %+v message latencies == %v", c.target, targetStats[0][0].GRPC.MessageLatencies)
+		}
+	}
+
+	//calls that never get an answer fail, with the kind of failure
+	closedListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedListener.Close()
+	failures := []struct {
+		target    Target
+		code      string
+		errorKind string
+	}{
+		{Target{URL: "grpc://" + listener.Addr().String(), GRPCMethod: "grpc.health.v1.Health/Check", GRPCMessages: []string{`{"service": "slow"}`}, Timeout: "100ms"}, "DeadlineExceeded", ErrorTimeout},
+		{Target{URL: "grpc://" + closedListener.Addr().String(), GRPCMethod: "grpc.health.v1.Health/Check", GRPCDescriptorSet: descriptorSetFile, Timeout: "5s"}, "Unavailable", ErrorConnRefused},
+	}
+	for _, c := range failures {
+		c.target.Method = DefaultMethod
+		c.target.Count = 2
+		c.target.Concurrency = 2
+		targetStats, err := RunStress(StressConfig{Targets: []Target{c.target}, Quiet: true}, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("RunStress(%+v) err: %s", c.target, err)
+		}
+		for _, stat := range targetStats[0] {
+			if stat.Error == nil || stat.ErrorKind != c.errorKind {
+				t.Errorf("gRPC call of %+v err: %v kind %q wanted kind %q", c.target, stat.Error, stat.ErrorKind, c.errorKind)
+			}
+			if stat.GRPC == nil || stat.GRPC.Code != c.code {
+				t.Errorf("gRPC call of %+v stats == %+v wanted code %s", c.target, stat.GRPC, c.code)
+			}
+		}
+	}
+
+	//methods that can't be called
+	badTargets := []Target{
+		{GRPCMethod: "grpc.health.v1.Health/Nope"},
+		{GRPCMethod: "grpc.health.v1.Nope/Check"},
+		{GRPCMethod: "grpc.health.v1.Health/Check", GRPCMessages: []string{`{"nope": 1}`}},
+		{GRPCMethod: "grpc.health.v1.Health/Check", GRPCMessages: []string{`{}`, `{}`}},
+		{GRPCMethod: "grpc.health.v1.Health/Check", GRPCDescriptorSet: filepath.Join(dir, "missing.protoset")},
+	}
+	for _, target := range badTargets {
+		target.URL = "grpc://" + listener.Addr().String()
+		target.Method = DefaultMethod
+		target.Count = 1
+		target.Concurrency = 1
+		if _, err := RunStress(StressConfig{Targets: []Target{target}, Quiet: true}, ioutil.Discard); err == nil {
+			t.Errorf("RunStress(%+v) succeeded, wanted an error", target)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package pewpew
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+//HAROptions chooses which entries of a HAR recording become targets, and how
+type HAROptions struct {
+	//Only keep requests to these hosts or their subdomains. Empty means keep all of them.
+	Domains []string
+	//Give each target a StartDelay, so the requests are sent as far apart as they were recorded
+	KeepTiming bool
+}
+
+//the parts of a HAR 1.2 recording that describe the requests
+type harFile struct {
+	Log struct {
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time `json:"startedDateTime"`
+	Request         struct {
+		Method   string         `json:"method"`
+		URL      string         `json:"url"`
+		Headers  []harNameValue `json:"headers"`
+		Cookies  []harNameValue `json:"cookies"`
+		PostData *struct {
+			MimeType string         `json:"mimeType"`
+			Text     string         `json:"text"`
+			Params   []harNameValue `json:"params"`
+		} `json:"postData"`
+	} `json:"request"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+//headers the recording holds that are set by the transport, or by other target settings
+var harSkippedHeaders = map[string]bool{
+	"host":              true,
+	"content-length":    true,
+	"connection":        true,
+	"keep-alive":        true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+	"user-agent":        true,
+}
+
+//TargetsFromHAR turns every HTTP request of a HAR recording, like those saved by browser
+//developer tools, into a Target sending it once, in the order they were recorded.
+//Method, URL, headers, cookies and body are kept as they were sent.
+func TargetsFromHAR(r io.Reader, opts HAROptions) ([]Target, error) {
+	var har harFile
+	if err := json.NewDecoder(r).Decode(&har); err != nil {
+		return nil, errors.New("failed to parse HAR: " + err.Error())
+	}
+	entries := make([]harEntry, 0, len(har.Log.Entries))
+	for _, entry := range har.Log.Entries {
+		entryURL, err := url.Parse(entry.Request.URL)
+		if err != nil {
+			return nil, errors.New("failed to parse URL of HAR entry " + entry.Request.URL + ": " + err.Error())
+		}
+		//data: URLs and the like never went over the network
+		if entryURL.Scheme != "http" && entryURL.Scheme != "https" {
+			continue
+		}
+		if !matchesDomains(entryURL.Hostname(), opts.Domains) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("no requests in HAR to import")
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].StartedDateTime.Before(entries[j].StartedDateTime)
+	})
+
+	targets := make([]Target, len(entries))
+	for i, entry := range entries {
+		target := Target{
+			URL:         entry.Request.URL,
+			Method:      entry.Request.Method,
+			Count:       1,
+			Concurrency: 1,
+		}
+		for _, cookie := range entry.Request.Cookies {
+			target.CookieList = append(target.CookieList, cookie.Name+"="+cookie.Value)
+		}
+		for _, header := range entry.Request.Headers {
+			name := strings.ToLower(header.Name)
+			switch {
+			case strings.HasPrefix(name, ":"):
+				//HTTP2 pseudo-headers, which the URL and method already hold
+			case name == "user-agent":
+				target.UserAgent = header.Value
+			case harSkippedHeaders[name]:
+			case name == "cookie" && len(target.CookieList) > 0:
+			default:
+				target.HeaderList = append(target.HeaderList, header.Name+": "+header.Value)
+			}
+		}
+		if postData := entry.Request.PostData; postData != nil {
+			target.Body = postData.Text
+			//some recorders only keep the fields of a form
+			if target.Body == "" && len(postData.Params) > 0 {
+				form := make([]string, len(postData.Params))
+				for i, param := range postData.Params {
+					form[i] = url.QueryEscape(param.Name) + "=" + url.QueryEscape(param.Value)
+				}
+				target.Body = strings.Join(form, "&")
+			}
+		}
+		if opts.KeepTiming && i > 0 {
+			if delay := entry.StartedDateTime.Sub(entries[0].StartedDateTime); delay > 0 {
+				target.StartDelay = delay.String()
+			}
+		}
+		targets[i] = target
+	}
+	return targets, nil
+}
+
+//whether host is one of domains or a subdomain of one, or domains is empty
+func matchesDomains(host string, domains []string) bool {
+	if len(domains) == 0 {
+		return true
+	}
+	host = strings.ToLower(host)
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package pewpew
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testHAR = `{"log": {"version": "1.2", "entries": [
+	{"startedDateTime": "2024-05-01T10:00:01.500Z", "request": {
+		"method": "POST", "url": "https://api.example.com/login", "httpVersion": "HTTP/2",
+		"headers": [
+			{"name": ":authority", "value": "api.example.com"},
+			{"name": "content-type", "value": "application/x-www-form-urlencoded"},
+			{"name": "cookie", "value": "session=abc"},
+			{"name": "content-length", "value": "26"},
+			{"name": "user-agent", "value": "Mozilla/5.0"}
+		],
+		"cookies": [{"name": "session", "value": "abc"}],
+		"postData": {"mimeType": "application/x-www-form-urlencoded", "params": [{"name": "user", "value": "pew pew"}, {"name": "password", "value": "a&b"}]}
+	}},
+	{"startedDateTime": "2024-05-01T10:00:00.000Z", "request": {
+		"method": "GET", "url": "https://www.example.com/?page=1", "httpVersion": "HTTP/1.1",
+		"headers": [
+			{"name": "Host", "value": "www.example.com"},
+			{"name": "Accept", "value": "text/html, application/json"},
+			{"name": "Cookie", "value": "theme=dark"}
+		],
+		"cookies": []
+	}},
+	{"startedDateTime": "2024-05-01T10:00:02.000Z", "request": {
+		"method": "PUT", "url": "https://cdn.other.com/upload", "httpVersion": "HTTP/1.1",
+		"headers": [],
+		"cookies": [],
+		"postData": {"mimeType": "application/json", "text": "{\"a\": 1}"}
+	}},
+	{"startedDateTime": "2024-05-01T10:00:03.000Z", "request": {
+		"method": "GET", "url": "data:image/png;base64,AAAA", "headers": [], "cookies": []
+	}}
+]}}`
+
+func TestTargetsFromHAR(t *testing.T) {
+	www := Target{
+		URL:         "https://www.example.com/?page=1",
+		Method:      "GET",
+		Count:       1,
+		Concurrency: 1,
+		HeaderList:  []string{"Accept: text/html, application/json", "Cookie: theme=dark"},
+	}
+	login := Target{
+		URL:         "https://api.example.com/login",
+		Method:      "POST",
+		Count:       1,
+		Concurrency: 1,
+		HeaderList:  []string{"content-type: application/x-www-form-urlencoded"},
+		CookieList:  []string{"session=abc"},
+		UserAgent:   "Mozilla/5.0",
+		Body:        "user=pew+pew&password=a%26b",
+	}
+	upload := Target{
+		URL:         "https://cdn.other.com/upload",
+		Method:      "PUT",
+		Count:       1,
+		Concurrency: 1,
+		Body:        `{"a": 1}`,
+	}
+	withDelay := func(target Target, delay string) Target {
+		target.StartDelay = delay
+		return target
+	}
+	cases := []struct {
+		har     string
+		opts    HAROptions
+		want    []Target
+		wantErr bool
+	}{
+		{testHAR, HAROptions{}, []Target{www, login, upload}, false},
+		{testHAR, HAROptions{KeepTiming: true}, []Target{www, withDelay(login, "1.5s"), withDelay(upload, "2s")}, false},
+		{testHAR, HAROptions{Domains: []string{"example.com"}}, []Target{www, login}, false},
+		{testHAR, HAROptions{Domains: []string{"API.example.com", ".other.com"}, KeepTiming: true}, []Target{login, withDelay(upload, "500ms")}, false},
+		{testHAR, HAROptions{Domains: []string{"ample.com"}}, nil, true},
+		{`{"log": {"entries": []}}`, HAROptions{}, nil, true},
+		{`not json`, HAROptions{}, nil, true},
+	}
+	for _, c := range cases {
+		got, err := TargetsFromHAR(strings.NewReader(c.har), c.opts)
+		if (err != nil) != c.wantErr {
+			t.Errorf("TargetsFromHAR(%+v) err: %v wanted error: %t", c.opts, err, c.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("TargetsFromHAR(%+v) == %+v wanted %+v", c.opts, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package pewpew
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+
+	quic "github.com/quic-go/quic-go"
+	http3 "github.com/quic-go/quic-go/http3"
+)
+
+//context key for the quicHandshake of a request
+type quicHandshakeKey struct{}
+
+//quicHandshake is filled in when a request opens a new QUIC connection
+type quicHandshake struct {
+	//released once the handshake has finished or failed
+	wg       sync.WaitGroup
+	duration time.Duration
+	used0RTT bool
+}
+
+//the open QUIC connections that were dialed, by their local address, for telling requests' connections apart.
+//Each has a UDP socket of its own, so no two open connections share an address.
+var quicConns = struct {
+	sync.Mutex
+	byAddr map[string]*quic.Conn
+}{byAddr: make(map[string]*quic.Conn)}
+
+//record conn as open until it's closed
+func addQUICConn(conn *quic.Conn) {
+	addr := conn.LocalAddr().String()
+	quicConns.Lock()
+	quicConns.byAddr[addr] = conn
+	quicConns.Unlock()
+	go func() {
+		<-conn.Context().Done()
+		quicConns.Lock()
+		if quicConns.byAddr[addr] == conn {
+			delete(quicConns.byAddr, addr)
+		}
+		quicConns.Unlock()
+	}()
+}
+
+//the open QUIC connection with the local address addr, or nil if there isn't one
+func openQUICConn(addr string) *quic.Conn {
+	quicConns.Lock()
+	defer quicConns.Unlock()
+	return quicConns.byAddr[addr]
+}
+
+//create the HTTP/3 transport used by a target
+func newHTTP3Transport(target Target, s StressConfig, sources *sourceIPs) *http3.Transport {
+	tr := &http3.Transport{
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: !s.EnforceSSL,
+			//session tickets are required to resume with 0-RTT
+			ClientSessionCache: tls.NewLRUClientSessionCache(0),
+		},
+		DisableCompression: true, //responses are decoded by runRequest
+		Dial:               dialQUIC(sources),
+	}
+	//the QUIC handshake includes the TLS handshake
+	tr.QUICConfig = &quic.Config{
+		MaxIdleTimeout:       parseOptionalDuration(target.IdleTimeout),
+		HandshakeIdleTimeout: parseOptionalDuration(target.TLSHandshakeTimeout),
+	}
+	return tr
+}
+
+//dial QUIC connections that can send the request as 0-RTT data from the next source IP to the resolved address,
+//timing the handshake for the request that triggered the dial
+func dialQUIC(sources *sourceIPs) func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (*quic.Conn, error) {
+	return func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (*quic.Conn, error) {
+		startTime := time.Now()
+		addr = sources.remoteAddr(addr)
+		var conn *quic.Conn
+		var err error
+		if ip := sources.next(); ip != nil {
+			conn, err = dialQUICFrom(ctx, ip, addr, tlsCfg, cfg)
+		} else {
+			conn, err = quic.DialAddrEarly(ctx, addr, tlsCfg, cfg)
+		}
+		if err != nil {
+			return nil, err
+		}
+		addQUICConn(conn)
+		timeHandshake(ctx, conn, startTime)
+		return conn, nil
+	}
+}
+
+//dial a QUIC connection over a UDP socket of its own bound to ip
+func dialQUICFrom(ctx context.Context, ip net.IP, addr string, tlsCfg *tls.Config, cfg *quic.Config) (*quic.Conn, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	packetConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: ip})
+	if err != nil {
+		return nil, err
+	}
+	conn, err := quic.DialEarly(ctx, packetConn, udpAddr, tlsCfg, cfg)
+	if err != nil {
+		packetConn.Close()
+		return nil, err
+	}
+	//the QUIC connection does not close the socket it was given
+	go func() {
+		<-conn.Context().Done()
+		packetConn.Close()
+	}()
+	return conn, nil
+}
+
+//record the handshake of conn for the request that dialed it, once it finishes
+func timeHandshake(ctx context.Context, conn *quic.Conn, startTime time.Time) {
+	handshake, ok := ctx.Value(quicHandshakeKey{}).(*quicHandshake)
+	if !ok {
+		return
+	}
+	handshake.wg.Add(1)
+	go func() {
+		defer handshake.wg.Done()
+		select {
+		case <-conn.HandshakeComplete():
+			handshake.duration = time.Since(startTime)
+			handshake.used0RTT = conn.ConnectionState().Used0RTT
+		case <-conn.Context().Done():
+		}
+	}()
+}
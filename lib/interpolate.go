@@ -0,0 +1,214 @@
+package pewpew
+
+import (
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//what secrets are replaced with in output
+const redacted = "[REDACTED]"
+
+//secrets shorter than this aren't redacted, as they'd be replaced all over the output
+const minSecretLength = 4
+
+//Interpolate replaces references in s to environment variables, like ${PORT}, and to files,
+//like ${file:/run/secrets/api_key}, with their values. $${ is left as a literal ${.
+//A file's value is its contents without a trailing newline.
+//${secret:API_KEY} is the environment variable API_KEY, as a secret. The values of secrets and files
+//are returned as well, so they can be redacted as Secrets.
+func Interpolate(s string) (string, []string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil, nil
+	}
+	var interpolated strings.Builder
+	var values []string
+	for {
+		start := strings.Index(s, "${")
+		if start == -1 {
+			interpolated.WriteString(s)
+			break
+		}
+		if start > 0 && s[start-1] == '$' {
+			interpolated.WriteString(s[:start-1] + "${")
+			s = s[start+2:]
+			continue
+		}
+		interpolated.WriteString(s[:start])
+		end := strings.Index(s[start:], "}")
+		if end == -1 {
+			return "", nil, errors.New("unclosed reference: " + s[start:])
+		}
+		name := s[start+2 : start+end]
+		value, secret, err := referenceValue(name)
+		if err != nil {
+			return "", nil, err
+		}
+		interpolated.WriteString(value)
+		if secret {
+			values = append(values, value)
+		}
+		s = s[start+end+1:]
+	}
+	return interpolated.String(), values, nil
+}
+
+//the value of a reference between ${ and }, and whether it's a secret
+func referenceValue(name string) (string, bool, error) {
+	if strings.HasPrefix(name, "file:") {
+		filename := strings.TrimPrefix(name, "file:")
+		if filename == "" {
+			return "", false, errors.New("empty file name in ${" + name + "}")
+		}
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return "", false, errors.New("failed to read secret file: " + err.Error())
+		}
+		return strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r"), true, nil
+	}
+	secret := strings.HasPrefix(name, "secret:")
+	variable := strings.TrimPrefix(name, "secret:")
+	if variable == "" {
+		return "", false, errors.New("empty reference ${" + name + "}")
+	}
+	value, ok := os.LookupEnv(variable)
+	if !ok {
+		return "", false, errors.New("environment variable " + variable + " is not set")
+	}
+	return value, secret, nil
+}
+
+//InterpolateSettings interpolates every string in settings, like those read from a config file,
+//including those in its lists and nested tables, and returns the values of the secrets and files interpolated.
+//Errors name the setting at fault.
+func InterpolateSettings(settings map[string]interface{}) ([]string, error) {
+	return interpolateValue(settings, "")
+}
+
+//interpolate the strings in v in place, when it's a list or table, with name being where v is in the config
+func interpolateValue(v interface{}, name string) ([]string, error) {
+	var values []string
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			keyName := key
+			if name != "" {
+				keyName = name + "." + key
+			}
+			if s, ok := value.(string); ok {
+				interpolated, sValues, err := Interpolate(s)
+				if err != nil {
+					return nil, errors.New(keyName + ": " + err.Error())
+				}
+				v[key] = interpolated
+				values = append(values, sValues...)
+				continue
+			}
+			vValues, err := interpolateValue(value, keyName)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, vValues...)
+		}
+	case []interface{}:
+		for i, value := range v {
+			itemName := name + "[" + strconv.Itoa(i+1) + "]"
+			if s, ok := value.(string); ok {
+				interpolated, sValues, err := Interpolate(s)
+				if err != nil {
+					return nil, errors.New(itemName + ": " + err.Error())
+				}
+				v[i] = interpolated
+				values = append(values, sValues...)
+				continue
+			}
+			vValues, err := interpolateValue(value, itemName)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, vValues...)
+		}
+	case []map[string]interface{}:
+		for i, value := range v {
+			vValues, err := interpolateValue(value, name+"["+strconv.Itoa(i+1)+"]")
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, vValues...)
+		}
+	case []string:
+		for i, value := range v {
+			interpolated, sValues, err := Interpolate(value)
+			if err != nil {
+				return nil, errors.New(name + "[" + strconv.Itoa(i+1) + "]: " + err.Error())
+			}
+			v[i] = interpolated
+			values = append(values, sValues...)
+		}
+	}
+	return values, nil
+}
+
+//Redact replaces the Secrets of s in text with [REDACTED], as RunStress and PrintPlan do in what they write
+func Redact(s StressConfig, text string) string {
+	return newRedactor(s).Replace(text)
+}
+
+//a replacer of the secrets of s in output, as they are written and as they are sent:
+//escaped in URLs, and encoded in the Authorization headers of basic auth
+func newRedactor(s StressConfig) *strings.Replacer {
+	var secrets []string
+	isSecret := make(map[string]bool)
+	add := func(secret string) {
+		if secret != "" && !isSecret[secret] {
+			isSecret[secret] = true
+			secrets = append(secrets, secret)
+		}
+	}
+	for _, secret := range s.Secrets {
+		if len(secret) < minSecretLength {
+			continue
+		}
+		add(secret)
+		add(url.QueryEscape(secret))
+		add(url.PathEscape(secret))
+	}
+	if len(secrets) == 0 {
+		return strings.NewReplacer()
+	}
+	containsSecret := func(value string) bool {
+		for _, secret := range s.Secrets {
+			if len(secret) >= minSecretLength && strings.Contains(value, secret) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, target := range s.Targets {
+		credentials := []string{target.BasicAuthUser + ":" + target.BasicAuthPass}
+		if authMap, err := parseKeyValString(target.BasicAuth, ",", ":"); target.BasicAuth != "" && err == nil {
+			for user, pass := range authMap {
+				credentials = append(credentials, user+":"+pass)
+			}
+		}
+		for _, userPass := range credentials {
+			if containsSecret(userPass) {
+				add(base64.StdEncoding.EncodeToString([]byte(userPass)))
+			}
+		}
+	}
+	//the longest first, so a secret containing another is replaced whole
+	sort.SliceStable(secrets, func(i, j int) bool {
+		return len(secrets[i]) > len(secrets[j])
+	})
+	pairs := make([]string, 0, len(secrets)*2)
+	for _, secret := range secrets {
+		pairs = append(pairs, secret, redacted)
+	}
+	return strings.NewReplacer(pairs...)
+}
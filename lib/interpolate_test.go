@@ -0,0 +1,122 @@
+package pewpew
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pewpew")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	secretFile := filepath.Join(dir, "api_key")
+	if err := ioutil.WriteFile(secretFile, []byte("from a file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("PEWPEW_TEST_TOKEN", "hunter2")
+	os.Setenv("PEWPEW_TEST_EMPTY", "")
+	defer os.Unsetenv("PEWPEW_TEST_TOKEN")
+	defer os.Unsetenv("PEWPEW_TEST_EMPTY")
+
+	cases := []struct {
+		s          string
+		want       string
+		wantValues []string
+		wantErr    bool
+	}{
+		{"", "", nil, false},
+		{"http://localhost/$1{2}", "http://localhost/$1{2}", nil, false},
+		//only secrets and files are returned to redact
+		{"Bearer ${PEWPEW_TEST_TOKEN}", "Bearer hunter2", nil, false},
+		{"Bearer ${secret:PEWPEW_TEST_TOKEN}", "Bearer hunter2", []string{"hunter2"}, false},
+		{"${PEWPEW_TEST_TOKEN}:${file:" + secretFile + "}", "hunter2:from a file", []string{"from a file"}, false},
+		{"[${PEWPEW_TEST_EMPTY}]", "[]", nil, false},
+		{"$${secret:PEWPEW_TEST_TOKEN} and ${secret:PEWPEW_TEST_TOKEN}", "${secret:PEWPEW_TEST_TOKEN} and hunter2", []string{"hunter2"}, false},
+		{"${PEWPEW_TEST_UNSET}", "", nil, true},
+		{"${secret:PEWPEW_TEST_UNSET}", "", nil, true},
+		{"${secret:}", "", nil, true},
+		{"${PEWPEW_TEST_TOKEN", "", nil, true},
+		{"${}", "", nil, true},
+		{"${file:}", "", nil, true},
+		{"${file:" + filepath.Join(dir, "missing") + "}", "", nil, true},
+	}
+	for _, c := range cases {
+		got, values, err := Interpolate(c.s)
+		if (err != nil) != c.wantErr {
+			t.Errorf("Interpolate(%q) err: %v wanted error: %t", c.s, err, c.wantErr)
+			continue
+		}
+		if got != c.want || !reflect.DeepEqual(values, c.wantValues) {
+			t.Errorf("Interpolate(%q) == %q, %q wanted %q, %q", c.s, got, values, c.want, c.wantValues)
+		}
+	}
+}
+
+func TestInterpolateSettings(t *testing.T) {
+	os.Setenv("PEWPEW_TEST_TOKEN", "hunter2")
+	defer os.Unsetenv("PEWPEW_TEST_TOKEN")
+
+	settings := map[string]interface{}{
+		"count":      10,
+		"headers":    "Authorization: Bearer ${secret:PEWPEW_TEST_TOKEN}",
+		"headerlist": []interface{}{"X-Token: ${secret:PEWPEW_TEST_TOKEN}"},
+		"targets": []interface{}{
+			map[string]interface{}{
+				"url":       "http://localhost/?key=${PEWPEW_TEST_TOKEN}",
+				"headermap": map[string]interface{}{"X-Api-Key": "${secret:PEWPEW_TEST_TOKEN}"},
+			},
+		},
+		"form": []string{"password=${secret:PEWPEW_TEST_TOKEN}"},
+	}
+	want := map[string]interface{}{
+		"count":      10,
+		"headers":    "Authorization: Bearer hunter2",
+		"headerlist": []interface{}{"X-Token: hunter2"},
+		"targets": []interface{}{
+			map[string]interface{}{
+				"url":       "http://localhost/?key=hunter2",
+				"headermap": map[string]interface{}{"X-Api-Key": "hunter2"},
+			},
+		},
+		"form": []string{"password=hunter2"},
+	}
+	values, err := InterpolateSettings(settings)
+	if err != nil {
+		t.Fatalf("InterpolateSettings err: %s", err)
+	}
+	if !reflect.DeepEqual(settings, want) {
+		t.Errorf("InterpolateSettings made\n%v\nwanted\n%v", settings, want)
+	}
+	if len(values) != 4 {
+		t.Errorf("InterpolateSettings returned %q wanted hunter2 4 times", values)
+	}
+
+	//the setting at fault is named
+	_, err = InterpolateSettings(map[string]interface{}{"targets": []interface{}{map[string]interface{}{"url": "${PEWPEW_TEST_UNSET}"}}})
+	if err == nil || err.Error() != "targets[1].url: environment variable PEWPEW_TEST_UNSET is not set" {
+		t.Errorf("InterpolateSettings of an unset variable err: %v", err)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	s := StressConfig{Secrets: []string{"hunter2", "s3cr3t token", "abc", ""}}
+	cases := []struct {
+		text string
+		want string
+	}{
+		{"http://localhost/?key=hunter2", "http://localhost/?key=[REDACTED]"},
+		{"/s3cr3t%20token/?q=s3cr3t+token", "/[REDACTED]/?q=[REDACTED]"},
+		//too short to redact without mangling the rest
+		{"abcdef", "abcdef"},
+	}
+	for _, c := range cases {
+		if got := Redact(s, c.text); got != c.want {
+			t.Errorf("Redact(%q) == %q wanted %q", c.text, got, c.want)
+		}
+	}
+}
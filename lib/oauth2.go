@@ -0,0 +1,188 @@
+package pewpew
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//how long before it expires a token is replaced, so requests in flight don't carry an expired one
+const oauth2ExpiryDelta = 10 * time.Second
+
+//default timeout of token requests, for targets without a Timeout
+const oauth2Timeout = 10 * time.Second
+
+//oauth2Tokens fetches an OAuth2 access token for a target, and fetches a new one when it expires.
+//Shared by all of the target's workers.
+type oauth2Tokens struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scopes       []string
+	username     string
+	password     string
+	client       *http.Client
+
+	lock         sync.Mutex
+	accessToken  string
+	refreshToken string
+	//zero when the token doesn't expire
+	expiry time.Time
+	//number of tokens fetched, including the first
+	fetches int
+}
+
+//the response of a token endpoint, from RFC 6749 section 5
+type oauth2TokenResponse struct {
+	AccessToken  string      `json:"access_token"`
+	TokenType    string      `json:"token_type"`
+	ExpiresIn    json.Number `json:"expires_in"`
+	RefreshToken string      `json:"refresh_token"`
+	Error        string      `json:"error"`
+	ErrorDesc    string      `json:"error_description"`
+}
+
+//fetch the first token of a target with an OAuth2TokenURL, nil if it has none
+func newOAuth2Tokens(t Target, s StressConfig) (*oauth2Tokens, error) {
+	if t.OAuth2TokenURL == "" {
+		return nil, nil
+	}
+	timeout := parseOptionalDuration(t.Timeout)
+	if timeout == 0 {
+		timeout = oauth2Timeout
+	}
+	tokens := &oauth2Tokens{
+		tokenURL:     t.OAuth2TokenURL,
+		clientID:     t.OAuth2ClientID,
+		clientSecret: t.OAuth2ClientSecret,
+		scopes:       t.OAuth2Scopes,
+		username:     t.OAuth2Username,
+		password:     t.OAuth2Password,
+		client: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: !s.EnforceSSL},
+			},
+		},
+	}
+	if err := tokens.fetch(tokens.grant()); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+//the form of the grant the target is configured with:
+//password when it has a username, client credentials otherwise
+func (o *oauth2Tokens) grant() url.Values {
+	form := url.Values{}
+	if o.username != "" {
+		form.Set("grant_type", "password")
+		form.Set("username", o.username)
+		form.Set("password", o.password)
+	} else {
+		form.Set("grant_type", "client_credentials")
+	}
+	if len(o.scopes) > 0 {
+		form.Set("scope", strings.Join(o.scopes, " "))
+	}
+	return form
+}
+
+//request a token from the token endpoint, and keep it. Must hold the lock, or be the only user.
+func (o *oauth2Tokens) fetch(form url.Values) error {
+	req, err := http.NewRequest(http.MethodPost, o.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return errors.New("failed to create OAuth2 token request: " + err.Error())
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	//client credentials are form encoded before they go in the basic auth header, per RFC 6749 section 2.3.1
+	req.SetBasicAuth(url.QueryEscape(o.clientID), url.QueryEscape(o.clientSecret))
+	response, err := o.client.Do(req)
+	if err != nil {
+		return errors.New("failed to fetch OAuth2 token: " + err.Error())
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return errors.New("failed to read OAuth2 token response: " + err.Error())
+	}
+	var token oauth2TokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return errors.New("failed to parse OAuth2 token response (" + response.Status + "): " + err.Error())
+	}
+	if token.Error != "" {
+		msg := token.Error
+		if token.ErrorDesc != "" {
+			msg += ": " + token.ErrorDesc
+		}
+		return errors.New("OAuth2 token request was refused: " + msg)
+	}
+	if response.StatusCode != http.StatusOK {
+		return errors.New("OAuth2 token request failed: " + response.Status)
+	}
+	if token.AccessToken == "" {
+		return errors.New("OAuth2 token response has no access token")
+	}
+	if token.TokenType != "" && !strings.EqualFold(token.TokenType, "bearer") {
+		return errors.New("unsupported OAuth2 token type: " + token.TokenType)
+	}
+	o.accessToken = token.AccessToken
+	//keep the previous refresh token when the server doesn't send a new one
+	if token.RefreshToken != "" {
+		o.refreshToken = token.RefreshToken
+	}
+	o.expiry = time.Time{}
+	if token.ExpiresIn != "" {
+		expiresIn, err := strconv.ParseInt(string(token.ExpiresIn), 10, 64)
+		if err != nil {
+			return errors.New("failed to parse OAuth2 token expiry: " + string(token.ExpiresIn))
+		}
+		if expiresIn > 0 {
+			o.expiry = time.Now().Add(time.Duration(expiresIn) * time.Second)
+		}
+	}
+	o.fetches++
+	return nil
+}
+
+//the current access token, replaced first if it is about to expire
+func (o *oauth2Tokens) token() (string, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.expiry.IsZero() || time.Now().Add(oauth2ExpiryDelta).Before(o.expiry) {
+		return o.accessToken, nil
+	}
+	if o.refreshToken != "" {
+		form := url.Values{}
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", o.refreshToken)
+		if err := o.fetch(form); err == nil {
+			return o.accessToken, nil
+		}
+		//the refresh token may have expired too, so start over
+		o.refreshToken = ""
+	}
+	if err := o.fetch(o.grant()); err != nil {
+		return "", err
+	}
+	return o.accessToken, nil
+}
+
+//set the Authorization header of req to the current access token
+func (o *oauth2Tokens) authorize(req *http.Request) error {
+	token, err := o.token()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
@@ -0,0 +1,147 @@
+package pewpew
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+//token endpoint that hands out numbered tokens, valid for expiresIn seconds, to the client "id" with secret "secret"
+type testTokenServer struct {
+	expiresIn int
+	//refuse refresh tokens, so the grant has to start over
+	refuseRefresh bool
+
+	lock   sync.Mutex
+	grants map[string]int
+	issued int
+}
+
+func (s *testTokenServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	id, secret, _ := r.BasicAuth()
+	r.ParseForm()
+	grant := r.PostForm.Get("grant_type")
+	refused := id != "id" || secret != "secret" ||
+		(grant == "password" && r.PostForm.Get("password") != "hunter2") ||
+		(grant == "refresh_token" && s.refuseRefresh)
+	if refused {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "invalid_grant"}`))
+		return
+	}
+	if s.grants == nil {
+		s.grants = make(map[string]int)
+	}
+	s.grants[grant]++
+	s.issued++
+	response := map[string]interface{}{
+		"access_token":  "token" + strconv.Itoa(s.issued),
+		"token_type":    "bearer",
+		"refresh_token": "refresh" + strconv.Itoa(s.issued),
+	}
+	if s.expiresIn > 0 {
+		response["expires_in"] = s.expiresIn
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func TestRunStressOAuth2(t *testing.T) {
+	//answers with the Authorization header it got, which must be a bearer token
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if len(auth) <= len("Bearer token") || auth[:len("Bearer token")] != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		tokenServer *testTokenServer
+		target      Target
+		wantGrants  map[string]int
+	}{
+		//a token that doesn't expire is fetched once
+		{&testTokenServer{}, Target{OAuth2ClientID: "id", OAuth2ClientSecret: "secret", OAuth2Scopes: []string{"read", "write"}},
+			map[string]int{"client_credentials": 1}},
+		{&testTokenServer{}, Target{OAuth2ClientID: "id", OAuth2ClientSecret: "secret", OAuth2Username: "user", OAuth2Password: "hunter2"},
+			map[string]int{"password": 1}},
+		//tokens that are about to expire are refreshed before every request
+		{&testTokenServer{expiresIn: 1}, Target{OAuth2ClientID: "id", OAuth2ClientSecret: "secret"},
+			map[string]int{"client_credentials": 1, "refresh_token": 4}},
+		{&testTokenServer{expiresIn: 1, refuseRefresh: true}, Target{OAuth2ClientID: "id", OAuth2ClientSecret: "secret", OAuth2Username: "user", OAuth2Password: "hunter2"},
+			map[string]int{"password": 5}},
+	}
+	for _, c := range cases {
+		tokenServer := httptest.NewServer(c.tokenServer)
+		c.target.URL = server.URL
+		c.target.Method = DefaultMethod
+		c.target.Count = 4
+		c.target.Concurrency = 2
+		c.target.OAuth2TokenURL = tokenServer.URL
+		targetStats, err := RunStress(StressConfig{Targets: []Target{c.target}, Quiet: true}, ioutil.Discard)
+		tokenServer.Close()
+		if err != nil {
+			t.Fatalf("RunStress(%+v) err: %s", c.target, err)
+		}
+		for _, stat := range targetStats[0] {
+			if stat.Error != nil {
+				t.Fatalf("request of %+v failed: %s", c.target, stat.Error)
+			}
+			if stat.StatusCode != http.StatusOK {
+				t.Errorf("request of %+v status code == %d wanted %d", c.target, stat.StatusCode, http.StatusOK)
+			}
+		}
+		for grant, want := range c.wantGrants {
+			if got := c.tokenServer.grants[grant]; got != want {
+				t.Errorf("RunStress(%+v) made %d %s grants wanted %d", c.target, got, grant, want)
+			}
+		}
+	}
+
+	//credentials that are refused fail the whole test
+	tokenServer := httptest.NewServer(&testTokenServer{})
+	defer tokenServer.Close()
+	badTargets := []Target{
+		{OAuth2ClientID: "id", OAuth2ClientSecret: "wrong"},
+		{OAuth2ClientID: "id", OAuth2ClientSecret: "secret", OAuth2Username: "user", OAuth2Password: "wrong"},
+	}
+	for _, target := range badTargets {
+		target.URL = server.URL
+		target.Method = DefaultMethod
+		target.Count = 1
+		target.Concurrency = 1
+		target.OAuth2TokenURL = tokenServer.URL
+		if _, err := RunStress(StressConfig{Targets: []Target{target}, Quiet: true}, ioutil.Discard); err == nil {
+			t.Errorf("RunStress(%+v) succeeded, wanted an error", target)
+		}
+	}
+}
+
+func TestOAuth2TokensAuthorize(t *testing.T) {
+	tokenServer := &testTokenServer{expiresIn: 1}
+	server := httptest.NewServer(tokenServer)
+	defer server.Close()
+	tokens, err := newOAuth2Tokens(Target{OAuth2TokenURL: server.URL, OAuth2ClientID: "id", OAuth2ClientSecret: "secret"}, StressConfig{})
+	if err != nil {
+		t.Fatalf("newOAuth2Tokens err: %s", err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost", nil)
+	if err := tokens.authorize(req); err != nil {
+		t.Fatalf("authorize err: %s", err)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer token2" {
+		t.Errorf("Authorization == %q wanted %q", got, "Bearer token2")
+	}
+	//once the token endpoint is gone, expired tokens can't be replaced
+	server.Close()
+	if err := tokens.authorize(req); err == nil {
+		t.Errorf("authorize succeeded with the token endpoint down, wanted an error")
+	}
+}
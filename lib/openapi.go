@@ -0,0 +1,654 @@
+package pewpew
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+//OpenAPIOptions chooses how the operations of an OpenAPI specification become targets
+type OpenAPIOptions struct {
+	//Scheme, host and base path to send the requests to, like "https://staging.example.com/v1".
+	//Empty string means the first server of the specification.
+	BaseURL string
+}
+
+//the parts of an OpenAPI 3 document that describe the requests
+type openAPISpec struct {
+	OpenAPI string `yaml:"openapi"`
+	Swagger string `yaml:"swagger"`
+	Servers []struct {
+		URL       string `yaml:"url"`
+		Variables map[string]struct {
+			Default string `yaml:"default"`
+		} `yaml:"variables"`
+	} `yaml:"servers"`
+	Paths      map[string]openAPIPathItem `yaml:"paths"`
+	Components struct {
+		Schemas       map[string]*openAPISchema      `yaml:"schemas"`
+		Parameters    map[string]*openAPIParameter   `yaml:"parameters"`
+		RequestBodies map[string]*openAPIRequestBody `yaml:"requestBodies"`
+		Examples      map[string]*openAPIExample     `yaml:"examples"`
+	} `yaml:"components"`
+}
+
+type openAPIPathItem struct {
+	Parameters []*openAPIParameter `yaml:"parameters"`
+	Get        *openAPIOperation   `yaml:"get"`
+	Put        *openAPIOperation   `yaml:"put"`
+	Post       *openAPIOperation   `yaml:"post"`
+	Delete     *openAPIOperation   `yaml:"delete"`
+	Options    *openAPIOperation   `yaml:"options"`
+	Head       *openAPIOperation   `yaml:"head"`
+	Patch      *openAPIOperation   `yaml:"patch"`
+	Trace      *openAPIOperation   `yaml:"trace"`
+}
+
+type openAPIOperation struct {
+	Parameters  []*openAPIParameter `yaml:"parameters"`
+	RequestBody *openAPIRequestBody `yaml:"requestBody"`
+}
+
+type openAPIParameter struct {
+	Ref      string                     `yaml:"$ref"`
+	Name     string                     `yaml:"name"`
+	In       string                     `yaml:"in"`
+	Required bool                       `yaml:"required"`
+	Schema   *openAPISchema             `yaml:"schema"`
+	Example  interface{}                `yaml:"example"`
+	Examples map[string]*openAPIExample `yaml:"examples"`
+}
+
+type openAPIRequestBody struct {
+	Ref     string                      `yaml:"$ref"`
+	Content map[string]openAPIMediaType `yaml:"content"`
+}
+
+type openAPIMediaType struct {
+	Schema   *openAPISchema             `yaml:"schema"`
+	Example  interface{}                `yaml:"example"`
+	Examples map[string]*openAPIExample `yaml:"examples"`
+}
+
+type openAPIExample struct {
+	Ref   string      `yaml:"$ref"`
+	Value interface{} `yaml:"value"`
+}
+
+type openAPISchema struct {
+	Ref string `yaml:"$ref"`
+	//a string, or a list of them like ["string", "null"] in OpenAPI 3.1
+	Type       interface{}               `yaml:"type"`
+	Format     string                    `yaml:"format"`
+	Pattern    string                    `yaml:"pattern"`
+	Enum       []interface{}             `yaml:"enum"`
+	Example    interface{}               `yaml:"example"`
+	Examples   []interface{}             `yaml:"examples"`
+	Default    interface{}               `yaml:"default"`
+	Minimum    *float64                  `yaml:"minimum"`
+	Maximum    *float64                  `yaml:"maximum"`
+	ReadOnly   bool                      `yaml:"readOnly"`
+	Items      *openAPISchema            `yaml:"items"`
+	Properties map[string]*openAPISchema `yaml:"properties"`
+	AllOf      []*openAPISchema          `yaml:"allOf"`
+	OneOf      []*openAPISchema          `yaml:"oneOf"`
+	AnyOf      []*openAPISchema          `yaml:"anyOf"`
+}
+
+//most references followed in a row, to get out of ones that only point at each other
+const openAPIMaxRefs = 16
+
+//regular expressions that generate values of string formats, for path and query parameters without examples
+var openAPIFormatPatterns = map[string]string{
+	"uuid": `[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}`,
+	"date": `20[0-9]{2}-(0[1-9]|1[0-2])-(0[1-9]|1[0-9]|2[0-8])`,
+}
+
+//sample values of string formats, for bodies and headers
+var openAPIFormatSamples = map[string]string{
+	"date":      "2024-01-01",
+	"date-time": "2024-01-01T00:00:00Z",
+	"email":     "pewpew@example.com",
+	"uuid":      "3fa85f64-5717-4562-b3fc-2c963f66afa6",
+	"uri":       "https://example.com",
+	"hostname":  "example.com",
+	"ipv4":      "192.0.2.1",
+	"ipv6":      "2001:db8::1",
+	"byte":      "cGV3cGV3",
+}
+
+//TargetsFromOpenAPI turns every operation of an OpenAPI 3 specification, in YAML or JSON, into a Target.
+//Path and required query parameters are filled with their examples, or else values generated from
+//their schemas, which makes the target a RegexURL that generates new ones for each request.
+//Request bodies are the media type's example, or synthesised from its schema as JSON or a form.
+//Count and Concurrency are left for the caller to set.
+func TargetsFromOpenAPI(spec []byte, opts OpenAPIOptions) ([]Target, error) {
+	var doc openAPISpec
+	if err := yaml.Unmarshal(spec, &doc); err != nil {
+		return nil, errors.New("failed to parse OpenAPI specification: " + err.Error())
+	}
+	if doc.Swagger != "" || !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, errors.New("only OpenAPI 3 specifications are supported")
+	}
+	baseURL := opts.BaseURL
+	if baseURL == "" && len(doc.Servers) > 0 {
+		baseURL = doc.Servers[0].URL
+		for name, variable := range doc.Servers[0].Variables {
+			baseURL = strings.Replace(baseURL, "{"+name+"}", variable.Default, -1)
+		}
+	}
+	if !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
+		return nil, errors.New("specification has no absolute server URL, so a base URL is needed: " + baseURL)
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	var targets []Target
+	for _, path := range paths {
+		item := doc.Paths[path]
+		operations := []struct {
+			method    string
+			operation *openAPIOperation
+		}{
+			{"GET", item.Get}, {"PUT", item.Put}, {"POST", item.Post}, {"DELETE", item.Delete},
+			{"OPTIONS", item.Options}, {"HEAD", item.Head}, {"PATCH", item.Patch}, {"TRACE", item.Trace},
+		}
+		for _, op := range operations {
+			if op.operation == nil {
+				continue
+			}
+			target, err := doc.target(baseURL, path, op.method, item.Parameters, op.operation)
+			if err != nil {
+				return nil, errors.New("failed to import " + op.method + " " + path + ": " + err.Error())
+			}
+			targets = append(targets, target)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, errors.New("no operations in OpenAPI specification to import")
+	}
+	return targets, nil
+}
+
+//the target of an operation, with the parameters of its path item
+func (doc *openAPISpec) target(baseURL, path, method string, pathParams []*openAPIParameter, operation *openAPIOperation) (Target, error) {
+	target := Target{Method: method}
+	//operation parameters override those of the path with the same name and location
+	params := make(map[string]*openAPIParameter)
+	var order []string
+	for _, param := range append(append([]*openAPIParameter{}, pathParams...), operation.Parameters...) {
+		resolved, err := doc.resolveParameter(param)
+		if err != nil {
+			return Target{}, err
+		}
+		key := resolved.In + ":" + resolved.Name
+		if _, seen := params[key]; !seen {
+			order = append(order, key)
+		}
+		params[key] = resolved
+	}
+
+	//literal parts of the URL, and regular expressions generating the rest
+	generated := false
+	urlPattern := regexp.QuoteMeta(baseURL)
+	for len(path) > 0 {
+		open := strings.Index(path, "{")
+		closing := strings.Index(path, "}")
+		if open == -1 || closing < open {
+			urlPattern += regexp.QuoteMeta(path)
+			break
+		}
+		urlPattern += regexp.QuoteMeta(path[:open])
+		name := path[open+1 : closing]
+		param, ok := params["path:"+name]
+		if !ok {
+			return Target{}, errors.New("no parameter for path template {" + name + "}")
+		}
+		pattern, isGenerated := doc.parameterPattern(param, url.PathEscape)
+		generated = generated || isGenerated
+		urlPattern += pattern
+		path = path[closing+1:]
+	}
+	var query []string
+	for _, key := range order {
+		param := params[key]
+		switch {
+		case !param.Required:
+		case param.In == "query":
+			pattern, isGenerated := doc.parameterPattern(param, url.QueryEscape)
+			generated = generated || isGenerated
+			query = append(query, regexp.QuoteMeta(url.QueryEscape(param.Name)+"=")+pattern)
+		case param.In == "header":
+			target.HeaderList = append(target.HeaderList, param.Name+": "+sampleString(doc.parameterSample(param)))
+		}
+	}
+	if len(query) > 0 {
+		urlPattern += regexp.QuoteMeta("?") + strings.Join(query, "&")
+	}
+	if generated {
+		target.URL = urlPattern
+		target.RegexURL = true
+	} else {
+		//nothing to generate, so the plain URL is easier to read
+		target.URL = unquoteMeta(urlPattern)
+	}
+
+	if err := doc.setBody(&target, operation.RequestBody); err != nil {
+		return Target{}, err
+	}
+	return target, nil
+}
+
+//a regular expression generating values of a path or query parameter, escaped with escape,
+//and whether they are generated rather than a fixed example
+func (doc *openAPISpec) parameterPattern(param *openAPIParameter, escape func(string) string) (string, bool) {
+	if example, ok := doc.parameterExample(param); ok {
+		return regexp.QuoteMeta(escape(sampleString(example))), false
+	}
+	schema := doc.resolveSchema(param.Schema)
+	if schema == nil {
+		return regexp.QuoteMeta(escape("pewpew")), false
+	}
+	if len(schema.Enum) > 0 {
+		values := make([]string, len(schema.Enum))
+		for i, value := range schema.Enum {
+			values[i] = regexp.QuoteMeta(escape(sampleString(value)))
+		}
+		return "(" + strings.Join(values, "|") + ")", true
+	}
+	switch schemaType(schema) {
+	case "integer":
+		//bounds narrower than the generated values get the smallest allowed
+		if (schema.Minimum != nil && *schema.Minimum > 1) || (schema.Maximum != nil && *schema.Maximum < 999) {
+			return regexp.QuoteMeta(sampleString(doc.sampleValue(schema))), false
+		}
+		return "[1-9][0-9]{0,2}", true
+	case "number":
+		if schema.Minimum != nil || schema.Maximum != nil {
+			return regexp.QuoteMeta(sampleString(doc.sampleValue(schema))), false
+		}
+		return `[1-9][0-9]{0,2}\.[0-9]{1,2}`, true
+	case "boolean":
+		return "(true|false)", true
+	case "string":
+		if pattern, ok := openAPIFormatPatterns[schema.Format]; ok {
+			return pattern, true
+		}
+		//generated values go into the URL as they are, so only patterns that can't make characters needing escaping are used
+		if schema.Format == "" && schema.Pattern != "" && urlSafePattern(schema.Pattern) {
+			return "(" + strings.TrimSuffix(strings.TrimPrefix(schema.Pattern, "^"), "$") + ")", true
+		}
+		if _, ok := openAPIFormatSamples[schema.Format]; ok {
+			return regexp.QuoteMeta(escape(sampleString(doc.sampleValue(schema)))), false
+		}
+		return "[a-z0-9]{8}", true
+	}
+	return regexp.QuoteMeta(escape(sampleString(doc.sampleValue(schema)))), false
+}
+
+//whether every value pattern matches is made of characters that are left as they are in a URL:
+//letters, digits, and "-", ".", "_" and "~"
+func urlSafePattern(pattern string) bool {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return false
+	}
+	return urlSafeRegexp(re)
+}
+
+func urlSafeRegexp(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if !isURLUnreserved(r) {
+				return false
+			}
+		}
+	case syntax.OpCharClass:
+		//ranges are pairs of their first and last characters
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			for r := re.Rune[i]; r <= re.Rune[i+1]; r++ {
+				if !isURLUnreserved(r) {
+					return false
+				}
+			}
+		}
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return false
+	}
+	for _, sub := range re.Sub {
+		if !urlSafeRegexp(sub) {
+			return false
+		}
+	}
+	return true
+}
+
+//whether r is one of the characters URLs never escape
+func isURLUnreserved(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || strings.ContainsRune("-._~", r)
+}
+
+//the example of a parameter, from the parameter or its schema
+func (doc *openAPISpec) parameterExample(param *openAPIParameter) (interface{}, bool) {
+	if param.Example != nil {
+		return param.Example, true
+	}
+	if example, ok := doc.firstExample(param.Examples); ok {
+		return example, true
+	}
+	if schema := doc.resolveSchema(param.Schema); schema != nil {
+		if schema.Example != nil {
+			return schema.Example, true
+		}
+		if len(schema.Examples) > 0 {
+			return schema.Examples[0], true
+		}
+		if schema.Default != nil {
+			return schema.Default, true
+		}
+	}
+	return nil, false
+}
+
+//a value of a parameter that can't be generated for each request, like a header
+func (doc *openAPISpec) parameterSample(param *openAPIParameter) interface{} {
+	if example, ok := doc.parameterExample(param); ok {
+		return example
+	}
+	return doc.sampleValue(param.Schema)
+}
+
+//set the body of an operation's target, and its Content-Type, from the first media type
+//it can be made for. JSON comes first, as the most likely to be synthesised right.
+func (doc *openAPISpec) setBody(target *Target, body *openAPIRequestBody) error {
+	if body == nil {
+		return nil
+	}
+	if body.Ref != "" {
+		resolved, ok := doc.Components.RequestBodies[strings.TrimPrefix(body.Ref, "#/components/requestBodies/")]
+		if !ok || !strings.HasPrefix(body.Ref, "#/components/requestBodies/") {
+			return errors.New("unresolved request body reference " + body.Ref)
+		}
+		body = resolved
+	}
+	mediaTypes := make([]string, 0, len(body.Content))
+	for mediaType := range body.Content {
+		mediaTypes = append(mediaTypes, mediaType)
+	}
+	sort.Strings(mediaTypes)
+	sort.SliceStable(mediaTypes, func(i, j int) bool {
+		return mediaTypeRank(mediaTypes[i]) < mediaTypeRank(mediaTypes[j])
+	})
+	for _, mediaType := range mediaTypes {
+		media := body.Content[mediaType]
+		value, ok := media.Example, media.Example != nil
+		if !ok {
+			value, ok = doc.firstExample(media.Examples)
+		}
+		if ok {
+			value = jsonCompatible(value)
+		} else {
+			value = doc.sampleValue(media.Schema)
+		}
+		switch mediaTypeRank(mediaType) {
+		case 0:
+			if value == nil {
+				continue
+			}
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return errors.New("failed to encode " + mediaType + " body: " + err.Error())
+			}
+			target.Body = string(encoded)
+		case 1:
+			fields, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			names := make([]string, 0, len(fields))
+			for name := range fields {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				target.Form = append(target.Form, name+"="+sampleString(fields[name]))
+			}
+			//Form sets its own Content-Type
+			return nil
+		default:
+			text, ok := value.(string)
+			if !ok {
+				continue
+			}
+			target.Body = text
+		}
+		target.HeaderList = append(target.HeaderList, "Content-Type: "+mediaType)
+		return nil
+	}
+	return nil
+}
+
+//the order media types of a body are tried in: JSON, then forms, then the rest
+func mediaTypeRank(mediaType string) int {
+	base := strings.ToLower(strings.TrimSpace(strings.SplitN(mediaType, ";", 2)[0]))
+	switch {
+	case base == "application/json" || strings.HasSuffix(base, "+json"):
+		return 0
+	case base == "application/x-www-form-urlencoded":
+		return 1
+	}
+	return 2
+}
+
+//the value of the first of examples, in order of name so it is always the same one
+func (doc *openAPISpec) firstExample(examples map[string]*openAPIExample) (interface{}, bool) {
+	names := make([]string, 0, len(examples))
+	for name := range examples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		example := examples[name]
+		if example.Ref != "" {
+			example = doc.Components.Examples[strings.TrimPrefix(example.Ref, "#/components/examples/")]
+		}
+		if example != nil && example.Value != nil {
+			return example.Value, true
+		}
+	}
+	return nil, false
+}
+
+func (doc *openAPISpec) resolveParameter(param *openAPIParameter) (*openAPIParameter, error) {
+	if param.Ref == "" {
+		return param, nil
+	}
+	resolved, ok := doc.Components.Parameters[strings.TrimPrefix(param.Ref, "#/components/parameters/")]
+	if !ok || !strings.HasPrefix(param.Ref, "#/components/parameters/") {
+		return nil, errors.New("unresolved parameter reference " + param.Ref)
+	}
+	return resolved, nil
+}
+
+//the schema a reference points to, nil for missing or external ones
+func (doc *openAPISpec) resolveSchema(schema *openAPISchema) *openAPISchema {
+	for refs := 0; schema != nil && schema.Ref != ""; refs++ {
+		if refs == openAPIMaxRefs || !strings.HasPrefix(schema.Ref, "#/components/schemas/") {
+			return nil
+		}
+		schema = doc.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	return schema
+}
+
+//the type of a schema, guessed from its other keywords when it has none
+func schemaType(schema *openAPISchema) string {
+	switch t := schema.Type.(type) {
+	case string:
+		return t
+	case []interface{}:
+		for _, listed := range t {
+			if name, ok := listed.(string); ok && name != "null" {
+				return name
+			}
+		}
+	}
+	switch {
+	case schema.Properties != nil:
+		return "object"
+	case schema.Items != nil:
+		return "array"
+	}
+	return ""
+}
+
+//a value the schema allows, from its examples if it has any, with objects and arrays
+//as maps and slices ready to be encoded as JSON
+func (doc *openAPISpec) sampleValue(schema *openAPISchema) interface{} {
+	return doc.sample(schema, make(map[string]bool))
+}
+
+//sampleValue of a schema inside the referenced schemas being expanded,
+//which are left out where they refer back to themselves
+func (doc *openAPISpec) sample(schema *openAPISchema, expanding map[string]bool) interface{} {
+	if schema != nil && schema.Ref != "" {
+		if expanding[schema.Ref] {
+			return nil
+		}
+		expanding[schema.Ref] = true
+		defer delete(expanding, schema.Ref)
+		schema = doc.resolveSchema(schema)
+	}
+	if schema == nil {
+		return nil
+	}
+	switch {
+	case schema.Example != nil:
+		return jsonCompatible(schema.Example)
+	case len(schema.Examples) > 0:
+		return jsonCompatible(schema.Examples[0])
+	case schema.Default != nil:
+		return jsonCompatible(schema.Default)
+	case len(schema.Enum) > 0:
+		return jsonCompatible(schema.Enum[0])
+	case len(schema.AllOf) > 0:
+		merged := make(map[string]interface{})
+		for _, part := range schema.AllOf {
+			if object, ok := doc.sample(part, expanding).(map[string]interface{}); ok {
+				for name, value := range object {
+					merged[name] = value
+				}
+			}
+		}
+		return merged
+	case len(schema.OneOf) > 0:
+		return doc.sample(schema.OneOf[0], expanding)
+	case len(schema.AnyOf) > 0:
+		return doc.sample(schema.AnyOf[0], expanding)
+	}
+	switch schemaType(schema) {
+	case "object":
+		object := make(map[string]interface{})
+		for name, property := range schema.Properties {
+			if resolved := doc.resolveSchema(property); resolved != nil && resolved.ReadOnly {
+				continue
+			}
+			if value := doc.sample(property, expanding); value != nil {
+				object[name] = value
+			}
+		}
+		return object
+	case "array":
+		if item := doc.sample(schema.Items, expanding); item != nil {
+			return []interface{}{item}
+		}
+		return []interface{}{}
+	case "integer":
+		if schema.Minimum != nil {
+			return int64(*schema.Minimum)
+		}
+		return int64(1)
+	case "number":
+		if schema.Minimum != nil {
+			return *schema.Minimum
+		}
+		return 1.5
+	case "boolean":
+		return true
+	case "string":
+		if sample, ok := openAPIFormatSamples[schema.Format]; ok {
+			return sample
+		}
+		return "pewpew"
+	}
+	return nil
+}
+
+//v with the maps YAML decodes as map[interface{}]interface{} turned into ones JSON can encode
+func jsonCompatible(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			converted[fmt.Sprint(key)] = jsonCompatible(item)
+		}
+		return converted
+	case map[string]interface{}:
+		converted := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			converted[key] = jsonCompatible(item)
+		}
+		return converted
+	case []interface{}:
+		converted := make([]interface{}, len(value))
+		for i, item := range value {
+			converted[i] = jsonCompatible(item)
+		}
+		return converted
+	}
+	return v
+}
+
+//a sample value as it is written in a URL or header
+func sampleString(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case []interface{}:
+		items := make([]string, len(value))
+		for i, item := range value {
+			items[i] = sampleString(item)
+		}
+		return strings.Join(items, ",")
+	case map[string]interface{}, map[interface{}]interface{}:
+		encoded, _ := json.Marshal(jsonCompatible(value))
+		return string(encoded)
+	}
+	return fmt.Sprint(v)
+}
+
+//undo regexp.QuoteMeta
+func unquoteMeta(s string) string {
+	var unquoted strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		unquoted.WriteByte(s[i])
+	}
+	return unquoted.String()
+}
@@ -0,0 +1,228 @@
+package pewpew
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	reggen "github.com/lucasjones/reggen"
+)
+
+const testOpenAPISpec = `
+openapi: 3.0.3
+info: {title: Pets, version: "1"}
+servers:
+  - url: https://{env}.example.com/v1
+    variables:
+      env: {default: api}
+paths:
+  /pets:
+    get:
+      parameters:
+        - {name: limit, in: query, required: true, schema: {type: integer, maximum: 50}}
+        - {name: cursor, in: query, schema: {type: string}}
+        - {name: X-Tenant, in: header, required: true, schema: {type: string, example: acme}}
+      responses:
+        200: {description: ok}
+    post:
+      requestBody:
+        content:
+          application/xml:
+            schema: {$ref: "#/components/schemas/Pet"}
+          application/json:
+            schema: {$ref: "#/components/schemas/Pet"}
+      responses:
+        201: {description: created}
+  /pets/{petId}:
+    parameters:
+      - $ref: "#/components/parameters/PetID"
+    get:
+      responses:
+        200: {description: ok}
+    put:
+      parameters:
+        - {name: petId, in: path, required: true, example: 42}
+      requestBody:
+        $ref: "#/components/requestBodies/PetForm"
+      responses:
+        200: {description: ok}
+  /search:
+    get:
+      parameters:
+        - {name: q, in: query, required: true, examples: {b: {value: "two words"}, a: {value: "cats & dogs"}}}
+        - {name: kind, in: query, required: true, schema: {type: string, enum: [cat, dog]}}
+      responses:
+        200: {description: ok}
+  /notes:
+    post:
+      requestBody:
+        content:
+          text/plain:
+            example: "hello"
+      responses:
+        201: {description: created}
+components:
+  parameters:
+    PetID: {name: petId, in: path, required: true, schema: {type: string, format: uuid}}
+  requestBodies:
+    PetForm:
+      content:
+        application/x-www-form-urlencoded:
+          schema:
+            type: object
+            properties:
+              name: {type: string, example: Rex}
+              age: {type: integer, minimum: 2}
+  schemas:
+    Pet:
+      allOf:
+        - $ref: "#/components/schemas/Named"
+        - type: object
+          properties:
+            id: {type: integer, readOnly: true}
+            born: {type: string, format: date}
+            tags: {type: array, items: {type: string, enum: [cute, loud]}}
+            owner: {$ref: "#/components/schemas/Owner"}
+            weight: {type: [number, "null"]}
+    Named:
+      type: object
+      properties:
+        name: {type: string}
+    Owner:
+      type: object
+      properties:
+        email: {type: string, format: email}
+        pets: {type: array, items: {$ref: "#/components/schemas/Pet"}}
+`
+
+func TestTargetsFromOpenAPI(t *testing.T) {
+	targets, err := TargetsFromOpenAPI([]byte(testOpenAPISpec), OpenAPIOptions{})
+	if err != nil {
+		t.Fatalf("TargetsFromOpenAPI err: %s", err)
+	}
+	want := []Target{
+		{Method: "POST", URL: "https://api.example.com/v1/notes", Body: "hello", HeaderList: []string{"Content-Type: text/plain"}},
+		{Method: "GET", URL: "https://api.example.com/v1/pets?limit=1", HeaderList: []string{"X-Tenant: acme"}},
+		{Method: "POST", URL: "https://api.example.com/v1/pets", HeaderList: []string{"Content-Type: application/json"}},
+		{Method: "GET", URL: `https://api\.example\.com/v1/pets/[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}`, RegexURL: true},
+		{Method: "PUT", URL: "https://api.example.com/v1/pets/42", Form: []string{"age=2", "name=Rex"}},
+		{Method: "GET", URL: `https://api\.example\.com/v1/search\?q=cats\+%26\+dogs&kind=(cat|dog)`, RegexURL: true},
+	}
+	if len(targets) != len(want) {
+		t.Fatalf("TargetsFromOpenAPI made %d targets wanted %d: %+v", len(targets), len(want), targets)
+	}
+	//the JSON body is checked on its own, as its keys can be in any order
+	wantPet := `{"born":"2024-01-01","name":"pewpew","owner":{"email":"pewpew@example.com","pets":[]},"tags":["cute"],"weight":1.5}`
+	if targets[2].Body != wantPet {
+		t.Errorf("POST /pets body == %s wanted %s", targets[2].Body, wantPet)
+	}
+	targets[2].Body = ""
+	for i := range want {
+		if !reflect.DeepEqual(targets[i], want[i]) {
+			t.Errorf("target %d == %+v wanted %+v", i, targets[i], want[i])
+		}
+	}
+
+	//generated URLs must be valid
+	generatedURLs := []struct {
+		target Target
+		want   *regexp.Regexp
+	}{
+		{targets[3], regexp.MustCompile(`^https://api\.example\.com/v1/pets/[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)},
+		{targets[5], regexp.MustCompile(`^https://api\.example\.com/v1/search\?q=cats\+%26\+dogs&kind=(cat|dog)$`)},
+	}
+	for _, c := range generatedURLs {
+		for i := 0; i < 10; i++ {
+			generated, err := reggen.Generate(c.target.URL, 10)
+			if err != nil || !c.want.MatchString(generated) {
+				t.Errorf("URL generated from %q == %q err: %v", c.target.URL, generated, err)
+			}
+		}
+	}
+
+	badSpecs := []struct {
+		spec string
+		opts OpenAPIOptions
+	}{
+		{`swagger: "2.0"`, OpenAPIOptions{}},
+		{`not: [valid`, OpenAPIOptions{}},
+		{"openapi: 3.0.0\npaths: {}", OpenAPIOptions{BaseURL: "https://example.com"}},
+		//no absolute server URL
+		{"openapi: 3.0.0\nservers: [{url: /v1}]\npaths: {/a: {get: {}}}", OpenAPIOptions{}},
+		{"openapi: 3.0.0\npaths: {/a/{id}: {get: {}}}", OpenAPIOptions{BaseURL: "https://example.com"}},
+		{"openapi: 3.0.0\npaths: {/a: {get: {parameters: [{$ref: '#/components/parameters/Missing'}]}}}", OpenAPIOptions{BaseURL: "https://example.com"}},
+	}
+	for _, c := range badSpecs {
+		if _, err := TargetsFromOpenAPI([]byte(c.spec), c.opts); err == nil {
+			t.Errorf("TargetsFromOpenAPI(%q) succeeded, wanted an error", c.spec)
+		}
+	}
+	//the base URL takes the place of the servers
+	targets, err = TargetsFromOpenAPI([]byte("openapi: 3.1.0\nservers: [{url: /v1}]\npaths: {/a: {delete: {}}}"), OpenAPIOptions{BaseURL: "http://localhost:8080/"})
+	if err != nil || len(targets) != 1 || targets[0].URL != "http://localhost:8080/a" || targets[0].Method != "DELETE" {
+		t.Errorf("TargetsFromOpenAPI with a base URL == %+v, %v", targets, err)
+	}
+}
+
+func TestOpenAPIParameterPatterns(t *testing.T) {
+	spec := `
+openapi: 3.0.3
+paths:
+  /users/{name}:
+    get:
+      parameters:
+        - {name: name, in: path, required: true, schema: {type: string, pattern: "^[a-z]{3,8}$"}}
+        - {name: tag, in: query, required: true, schema: {type: string, pattern: '\w+'}}
+      responses:
+        200: {description: ok}
+  /files/{path}:
+    get:
+      parameters:
+        - {name: path, in: path, required: true, schema: {type: string, pattern: ".+"}}
+        - {name: dir, in: query, required: true, schema: {type: string, pattern: "[^/]+"}}
+      responses:
+        200: {description: ok}
+`
+	targets, err := TargetsFromOpenAPI([]byte(spec), OpenAPIOptions{BaseURL: "https://example.com"})
+	if err != nil {
+		t.Fatalf("TargetsFromOpenAPI err: %s", err)
+	}
+	//patterns that could generate characters a URL needs escaped fall back to plain ones
+	want := []string{
+		`https://example\.com/files/[a-z0-9]{8}\?dir=[a-z0-9]{8}`,
+		`https://example\.com/users/([a-z]{3,8})\?tag=(\w+)`,
+	}
+	if len(targets) != len(want) {
+		t.Fatalf("TargetsFromOpenAPI made %d targets wanted %d: %+v", len(targets), len(want), targets)
+	}
+	for i := range want {
+		if targets[i].URL != want[i] {
+			t.Errorf("target %d URL == %q wanted %q", i, targets[i].URL, want[i])
+		}
+	}
+}
+
+func TestURLSafePattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		want    bool
+	}{
+		{"^[a-z]{3,8}$", true},
+		{`\w+`, true},
+		{`[A-Z][0-9]{2}\.v~(-alpha|_beta)?`, true},
+		{"(?i)abc", true},
+		{".+", false},
+		{"[^/]+", false},
+		{`\S+`, false},
+		{"a b", false},
+		{"[a-z]+/[a-z]+", false},
+		{"[a-z#]", false},
+		{"é", false},
+		{"[a-z", false},
+	}
+	for _, c := range cases {
+		if got := urlSafePattern(c.pattern); got != c.want {
+			t.Errorf("urlSafePattern(%q) == %t wanted %t", c.pattern, got, c.want)
+		}
+	}
+}
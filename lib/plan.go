@@ -0,0 +1,234 @@
+package pewpew
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+//the most of a sample request's body that's printed
+const maxPlanBodySize = 512
+
+//settings holding credentials, which the plan masks wherever they came from
+var planCredentialSettings = []string{"BasicAuthPass", "BearerToken", "DigestAuthPass", "OAuth2ClientSecret", "OAuth2Password",
+	"HMACKey", "AWSSecretAccessKey", "AWSSessionToken"}
+
+//PrintPlan writes what RunStress would do with s without sending anything: each target's settings,
+//after the global settings have been applied to it, and samples of the requests it would send.
+//The settings are written even when s is invalid, and the error is returned after them.
+//Its Secrets are redacted, and credentials are masked, like passwords and the values of Authorization headers.
+func PrintPlan(s StressConfig, samples int, w io.Writer) error {
+	if w == nil {
+		return errors.New("nil writer")
+	}
+	var plan bytes.Buffer
+	err := printPlan(s, samples, &plan)
+	io.WriteString(w, newRedactor(s).Replace(plan.String()))
+	return err
+}
+
+func printPlan(s StressConfig, samples int, w io.Writer) error {
+	fmt.Fprintln(w, "Global settings:")
+	fmt.Fprintf(w, "  NoHTTP2 = %t\n", s.NoHTTP2)
+	fmt.Fprintf(w, "  H2C = %t\n", s.H2C)
+	fmt.Fprintf(w, "  EnforceSSL = %t\n", s.EnforceSSL)
+
+	total := 0
+	for i, target := range s.Targets {
+		fmt.Fprintf(w, "\nTarget %d: %d requests, %d at a time\n", i+1, target.Count, target.Concurrency)
+		targetVal := reflect.ValueOf(maskCredentials(target))
+		for j := 0; j < targetVal.NumField(); j++ {
+			field := targetVal.Field(j)
+			//an empty list is as unset as a nil one
+			if field.IsZero() || ((field.Kind() == reflect.Slice || field.Kind() == reflect.Map) && field.Len() == 0) {
+				continue
+			}
+			fmt.Fprintf(w, "  %s = %s\n", targetVal.Type().Field(j).Name, planValue(field))
+		}
+		total += target.Count
+	}
+
+	if err := validateTargets(s); err != nil {
+		return errors.New("invalid configuration: " + err.Error())
+	}
+
+	for i, target := range s.Targets {
+		count := samples
+		if count > target.Count {
+			count = target.Count
+		}
+		fmt.Fprintf(w, "\nSample requests of target %d:\n", i+1)
+		body, err := loadBody(target)
+		if err != nil {
+			return errors.New("failed to create request with target configuration: " + err.Error())
+		}
+		variables, err := parseGraphQLVariables(target.Variables)
+		if err != nil {
+			return errors.New("failed to create request with target configuration: " + err.Error())
+		}
+		for j := 0; j < count; j++ {
+			reqTarget := target
+			if len(target.ReplayRequests) > 0 {
+				reqTarget = replayedTarget(target, target.ReplayRequests[j])
+			}
+			req, err := buildRequest(reqTarget, body)
+			if err == nil && target.Query != "" {
+				err = setGraphQLBody(&req, target, variables, j)
+			}
+			if err != nil {
+				return errors.New("failed to create request with target configuration: " + err.Error())
+			}
+			printPlanRequest(&req, w)
+		}
+		for _, note := range planNotes(target) {
+			fmt.Fprintf(w, "  (%s)\n", note)
+		}
+	}
+	fmt.Fprintf(w, "\n%d requests to %d targets in total\n", total, len(s.Targets))
+	return nil
+}
+
+//a setting's value as it's written in the plan
+func planValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Slice:
+		//like the requests of an access log, which are too many to list
+		if v.Type().Elem().Kind() == reflect.Struct {
+			return strconv.Itoa(v.Len()) + " of them"
+		}
+		return fmt.Sprintf("%q", v.Interface())
+	case reflect.String, reflect.Map:
+		return fmt.Sprintf("%q", v.Interface())
+	case reflect.Interface:
+		//like a Signer, which is only set by library users
+		return v.Elem().Type().String()
+	}
+	return fmt.Sprintf("%v", v.Interface())
+}
+
+//write the request line, headers and the start of the body of req
+func printPlanRequest(req *http.Request, w io.Writer) {
+	fmt.Fprintf(w, "  %s %s\n", req.Method, req.URL)
+	for _, name := range sortedHeaderNames(req.Header) {
+		for _, value := range req.Header[name] {
+			if isCredentialHeader(name) {
+				value = maskCredentialHeader(value)
+			}
+			fmt.Fprintf(w, "    %s: %s\n", name, value)
+		}
+	}
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody == nil {
+		return
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		fmt.Fprintf(w, "    (failed to read body: %s)\n", err.Error())
+		return
+	}
+	defer body.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(body, maxPlanBodySize+1))
+	if err != nil {
+		fmt.Fprintf(w, "    (failed to read body: %s)\n", err.Error())
+		return
+	}
+	length := "unknown length"
+	if req.ContentLength >= 0 {
+		length = strconv.FormatInt(req.ContentLength, 10) + " bytes"
+	}
+	fmt.Fprintf(w, "    (body of %s)\n", length)
+	//only short text is worth reading
+	if len(data) > maxPlanBodySize || !isPrintableText(data) {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fmt.Fprintf(w, "    %s\n", line)
+	}
+}
+
+//what's done to the target's requests as they are sent, so isn't in the samples
+func planNotes(t Target) []string {
+	var notes []string
+	if t.OAuth2TokenURL != "" {
+		notes = append(notes, "an Authorization header with a token from "+t.OAuth2TokenURL+" is added as each request is sent")
+	}
+	if t.DigestAuthUser != "" {
+		notes = append(notes, "Digest auth challenges are answered as "+t.DigestAuthUser)
+	}
+	if t.HMACKey != "" || t.AWSAccessKeyID != "" || t.Signer != nil {
+		notes = append(notes, "requests are signed as they are sent")
+	}
+	if t.RegexURL {
+		notes = append(notes, "a new URL is generated from "+t.URL+" for each request")
+	}
+	if len(t.ReplayRequests) > 0 && t.ReplayRequests[len(t.ReplayRequests)-1].Offset > 0 {
+		notes = append(notes, "requests are sent as far apart as they were logged, over "+t.ReplayRequests[len(t.ReplayRequests)-1].Offset.String())
+	}
+	return notes
+}
+
+//t with the values of its credential settings and headers masked
+func maskCredentials(t Target) Target {
+	targetVal := reflect.ValueOf(&t).Elem()
+	for _, setting := range planCredentialSettings {
+		if field := targetVal.FieldByName(setting); field.String() != "" {
+			field.SetString(redacted)
+		}
+	}
+	//the user is kept, as it tells who the requests are sent as
+	if parts := strings.SplitN(t.BasicAuth, ":", 2); len(parts) == 2 {
+		t.BasicAuth = parts[0] + ":" + redacted
+	}
+	if t.Headers != "" {
+		headers := strings.Split(t.Headers, ",")
+		for i, header := range headers {
+			headers[i] = maskHeader(header)
+		}
+		t.Headers = strings.Join(headers, ",")
+	}
+	if len(t.HeaderList) > 0 {
+		headerList := make([]string, len(t.HeaderList))
+		for i, header := range t.HeaderList {
+			headerList[i] = maskHeader(header)
+		}
+		t.HeaderList = headerList
+	}
+	if len(t.HeaderMap) > 0 {
+		headerMap := make(map[string]string, len(t.HeaderMap))
+		for name, value := range t.HeaderMap {
+			if isCredentialHeader(name) {
+				value = maskCredentialHeader(value)
+			}
+			headerMap[name] = value
+		}
+		t.HeaderMap = headerMap
+	}
+	return t
+}
+
+//a header like "Name: value", with the value masked when it's a credential
+func maskHeader(header string) string {
+	parts := strings.SplitN(header, ":", 2)
+	if len(parts) != 2 || !isCredentialHeader(strings.TrimSpace(parts[0])) {
+		return header
+	}
+	return parts[0] + ": " + maskCredentialHeader(strings.TrimSpace(parts[1]))
+}
+
+//whether the values of the header are credentials
+func isCredentialHeader(name string) bool {
+	return strings.EqualFold(name, "Authorization") || strings.EqualFold(name, "Proxy-Authorization")
+}
+
+//the value of a credential header with its credentials masked, keeping the scheme, like "Bearer"
+func maskCredentialHeader(value string) string {
+	if parts := strings.SplitN(value, " ", 2); len(parts) == 2 && parts[0] != "" {
+		return parts[0] + " " + redacted
+	}
+	return redacted
+}
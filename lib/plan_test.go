@@ -0,0 +1,137 @@
+package pewpew
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrintPlan(t *testing.T) {
+	cases := []struct {
+		s       StressConfig
+		samples int
+		w       io.Writer
+		want    []string
+		wantErr bool
+	}{
+		{StressConfig{}, 3, nil, nil, true},
+		{StressConfig{}, 3, &bytes.Buffer{}, []string{"Global settings:\n"}, true},
+		//settings are written before the error
+		{StressConfig{Targets: []Target{{URL: "http://localhost", Method: "GET", Count: 1, Concurrency: 2}}}, 3, &bytes.Buffer{},
+			[]string{"Target 1: 1 requests, 2 at a time\n", "  Concurrency = 2\n"}, true},
+		{StressConfig{EnforceSSL: true, Targets: []Target{
+			{URL: "http://localhost/a", Method: "POST", Count: 10, Concurrency: 2, Body: "line one\nline two", UserAgent: "pewpew",
+				HeaderList: []string{"Accept: text/plain"}, CookieList: []string{}, Timeout: "2s", HMACKey: "key"},
+			{URL: "localhost/b", Method: "PUT", Count: 2, Concurrency: 1, BodySize: "1KB"},
+		}}, 3, &bytes.Buffer{}, []string{
+			"  EnforceSSL = true\n",
+			"Target 1: 10 requests, 2 at a time\n",
+			"  URL = \"http://localhost/a\"\n",
+			"  HeaderList = [\"Accept: text/plain\"]\n",
+			"  Timeout = \"2s\"\n",
+			"  Body = \"line one\\nline two\"\n",
+			"  POST http://localhost/a\n    Accept: text/plain\n    User-Agent: pewpew\n    (body of 17 bytes)\n    line one\n    line two\n",
+			"  (requests are signed as they are sent)\n",
+			"  PUT http://localhost/b\n    User-Agent: \n    (body of 1024 bytes)\n",
+			"12 requests to 2 targets in total\n",
+		}, false},
+		//samples of replayed requests are the first ones of the log
+		{StressConfig{Targets: []Target{{URL: "http://localhost", Method: "GET", Count: 2, Concurrency: 1, UserAgent: "pewpew",
+			ReplayRequests: []ReplayedRequest{{Method: "POST", URI: "/orders", Referer: "http://localhost/"}, {Method: "GET", URI: "/b", Offset: 1500 * time.Millisecond}},
+		}}}, 3, &bytes.Buffer{}, []string{
+			"  ReplayRequests = 2 of them\n",
+			"  POST http://localhost/orders\n    Referer: http://localhost/\n    User-Agent: pewpew\n",
+			"  GET http://localhost/b\n    User-Agent: pewpew\n",
+			"  (requests are sent as far apart as they were logged, over 1.5s)\n",
+		}, false},
+	}
+	for _, c := range cases {
+		err := PrintPlan(c.s, c.samples, c.w)
+		if (err != nil) != c.wantErr {
+			t.Errorf("PrintPlan(%+v) err: %v wanted error: %t", c.s, err, c.wantErr)
+			continue
+		}
+		if c.w == nil {
+			continue
+		}
+		plan := c.w.(*bytes.Buffer).String()
+		for _, want := range c.want {
+			if !strings.Contains(plan, want) {
+				t.Errorf("PrintPlan(%+v) is missing %q:\n%s", c.s, want, plan)
+			}
+		}
+		if strings.Contains(plan, "= []") {
+			t.Errorf("PrintPlan(%+v) wrote an empty list:\n%s", c.s, plan)
+		}
+	}
+
+	//samples are capped at the count, and each generates its own URL
+	var w bytes.Buffer
+	err := PrintPlan(StressConfig{Targets: []Target{{URL: `http://localhost/[a-z]{8}`, RegexURL: true, Method: "GET", Count: 4, Concurrency: 1}}}, 10, &w)
+	if err != nil {
+		t.Fatalf("PrintPlan of a regex target err: %s", err)
+	}
+	urls := regexp.MustCompile(`(?m)^  GET http://localhost/[a-z]{8}$`).FindAllString(w.String(), -1)
+	if len(urls) != 4 {
+		t.Errorf("PrintPlan of a regex target wrote %d samples wanted 4:\n%s", len(urls), w.String())
+	}
+
+	//credentials are masked however they were given
+	w.Reset()
+	err = PrintPlan(StressConfig{Targets: []Target{
+		{URL: "http://localhost/a", Method: "GET", Count: 1, Concurrency: 1, BasicAuthUser: "admin", BasicAuthPass: "pass-1",
+			HeaderList: []string{"Authorization: Bearer token-2", "Accept: text/plain"}, Headers: "proxy-authorization:Basic token-3"},
+		{URL: "http://localhost/b", Method: "GET", Count: 1, Concurrency: 1, BearerToken: "token-4", HMACKey: "key-6",
+			HeaderMap: map[string]string{"authorization": "token-7"}},
+		{URL: "http://localhost/b", Method: "GET", Count: 1, Concurrency: 1, DigestAuthUser: "admin", DigestAuthPass: "pass-5"},
+		{URL: "http://localhost/b", Method: "GET", Count: 1, Concurrency: 1, BasicAuth: "admin:pass-8"},
+		{URL: "http://localhost/c", Method: "GET", Count: 1, Concurrency: 1, OAuth2TokenURL: "http://localhost/token", OAuth2ClientID: "pewpew",
+			OAuth2ClientSecret: "secret-9", OAuth2Username: "admin", OAuth2Password: "pass-10"},
+		{URL: "http://localhost/c", Method: "GET", Count: 1, Concurrency: 1, AWSAccessKeyID: "AKID", AWSSecretAccessKey: "secret-11",
+			AWSSessionToken: "token-12", AWSRegion: "us-east-1", AWSService: "execute-api"},
+	}}, 1, &w)
+	if err != nil {
+		t.Fatalf("PrintPlan of targets with credentials err: %s", err)
+	}
+	for _, credential := range []string{"pass-1", "YWRtaW46cGFzcy0x", "token-2", "token-3", "token-4", "pass-5", "key-6", "token-7", "pass-8",
+		"YWRtaW46cGFzcy04", "secret-9", "pass-10", "secret-11", "token-12"} {
+		if strings.Contains(w.String(), credential) {
+			t.Errorf("PrintPlan of targets with credentials wrote %q:\n%s", credential, w.String())
+		}
+	}
+	for _, want := range []string{
+		"  BasicAuthPass = \"[REDACTED]\"\n",
+		"  HeaderList = [\"Authorization: Bearer [REDACTED]\" \"Accept: text/plain\"]\n",
+		"  Headers = \"proxy-authorization: Basic [REDACTED]\"\n",
+		"  BasicAuth = \"admin:[REDACTED]\"\n",
+		"  DigestAuthUser = \"admin\"\n",
+		"    Authorization: Basic [REDACTED]\n",
+		"    Authorization: Bearer [REDACTED]\n",
+		"    Proxy-Authorization: Basic [REDACTED]\n",
+	} {
+		if !strings.Contains(w.String(), want) {
+			t.Errorf("PrintPlan of targets with credentials is missing %q:\n%s", want, w.String())
+		}
+	}
+
+	//secrets are redacted from the settings and the samples, including encoded in basic auth
+	w.Reset()
+	err = PrintPlan(StressConfig{Secrets: []string{"hunter2", "s3cr3t token"}, Targets: []Target{{URL: "http://localhost/?token=s3cr3t+token", Method: "GET", Count: 1, Concurrency: 1,
+		BasicAuthUser: "admin", BasicAuthPass: "hunter2", HeaderMap: map[string]string{"X-Api-Key": "s3cr3t token"}}}}, 1, &w)
+	if err != nil {
+		t.Fatalf("PrintPlan of a target with secrets err: %s", err)
+	}
+	for _, secret := range []string{"hunter2", "s3cr3t", "YWRtaW46aHVudGVyMg=="} {
+		if strings.Contains(w.String(), secret) {
+			t.Errorf("PrintPlan of a target with secrets wrote %q:\n%s", secret, w.String())
+		}
+	}
+	for _, want := range []string{"  BasicAuthPass = \"[REDACTED]\"\n", "    Authorization: Basic [REDACTED]\n", "    X-Api-Key: [REDACTED]\n", "?token=[REDACTED]\n"} {
+		if !strings.Contains(w.String(), want) {
+			t.Errorf("PrintPlan of a target with secrets is missing %q:\n%s", want, w.String())
+		}
+	}
+}
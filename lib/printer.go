@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"sort"
+	"strings"
 
 	color "github.com/fatih/color"
 )
@@ -27,7 +28,127 @@ func CreateTextSummary(reqStatSummary RequestStatSummary) string {
 	summary += "Smallest query:  " + fmt.Sprintf("%d", reqStatSummary.minDataTransferred) + " bytes\n"
 	summary += "Total:           " + fmt.Sprintf("%d", reqStatSummary.totalDataTransferred) + " bytes\n"
 
-	summary = summary + "\nResponse Codes\n"
+	if len(reqStatSummary.contentEncodings) > 0 {
+		summary += "\nContent Encodings\n"
+		summary += "Compressed:         " + fmt.Sprintf("%d", reqStatSummary.encodedBodyBytes) + " bytes\n"
+		summary += "Decompressed:       " + fmt.Sprintf("%d", reqStatSummary.decodedBodyBytes) + " bytes\n"
+		if reqStatSummary.encodedBodyBytes > 0 {
+			summary += "Compression ratio:  " + fmt.Sprintf("%.2f", float64(reqStatSummary.decodedBodyBytes)/float64(reqStatSummary.encodedBodyBytes)) + "\n"
+		}
+		var encodings []string
+		for encoding := range reqStatSummary.contentEncodings {
+			encodings = append(encodings, encoding)
+		}
+		sort.Strings(encodings)
+		for _, encoding := range encodings {
+			summary += encoding + ": " + fmt.Sprintf("%d", reqStatSummary.contentEncodings[encoding]) + " responses\n"
+		}
+	}
+
+	if reqStatSummary.authChallenged > 0 {
+		summary += "\nAuth Challenges\n"
+		summary += "Challenged requests:  " + fmt.Sprintf("%d", reqStatSummary.authChallenged) + "\n"
+		summary += "Extra round trips:    " + fmt.Sprintf("%d", reqStatSummary.authRoundTrips) + "\n"
+		summary += "Mean round trip:      " + fmt.Sprintf("%d", reqStatSummary.avgAuthRoundTripTime/1000000) + " ms\n"
+	}
+
+	if reqStatSummary.connCount > 0 {
+		summary += "\nConnections\n"
+		summary += "Opened:                  " + fmt.Sprintf("%d", reqStatSummary.connCount) + "\n"
+		summary += "Max concurrent streams:  " + fmt.Sprintf("%d", reqStatSummary.maxConnStreams) + " per connection\n"
+		summary += "Mean requests served:    " + fmt.Sprintf("%.2f", reqStatSummary.avgConnRequests) + " per connection\n"
+		summary += "Most requests served:    " + fmt.Sprintf("%d", reqStatSummary.maxConnRequests) + " per connection\n"
+		summary += "Fewest requests served:  " + fmt.Sprintf("%d", reqStatSummary.minConnRequests) + " per connection\n"
+	}
+
+	if reqStatSummary.handshakeCount > 0 {
+		summary += "\nHandshakes\n"
+		summary += "Count:           " + fmt.Sprintf("%d", reqStatSummary.handshakeCount) + "\n"
+		summary += "Mean duration:   " + fmt.Sprintf("%d", reqStatSummary.avgHandshakeTime/1000000) + " ms\n"
+		summary += "Sent as 0-RTT:   " + fmt.Sprintf("%d", reqStatSummary.zeroRTTCount) + " requests\n"
+	}
+
+	if reqStatSummary.wsSessions > 0 {
+		summary += "\nWebSocket\n"
+		summary += "Sessions:             " + fmt.Sprintf("%d", reqStatSummary.wsSessions) + "\n"
+		summary += "Mean session length:  " + fmt.Sprintf("%d", reqStatSummary.avgWSSession/1000000) + " ms\n"
+		summary += "Messages sent:        " + fmt.Sprintf("%d", reqStatSummary.wsMessagesSent) + "\n"
+		summary += "Messages received:    " + fmt.Sprintf("%d", reqStatSummary.wsMessagesReceived) + "\n"
+		summary += "Unanswered messages:  " + fmt.Sprintf("%d", reqStatSummary.wsUnanswered) + "\n"
+		summary += "Mean round trip:      " + fmt.Sprintf("%d", reqStatSummary.avgWSRoundTrip/1000000) + " ms\n"
+		summary += "Fastest round trip:   " + fmt.Sprintf("%d", reqStatSummary.minWSRoundTrip/1000000) + " ms\n"
+		summary += "Slowest round trip:   " + fmt.Sprintf("%d", reqStatSummary.maxWSRoundTrip/1000000) + " ms\n"
+		var reasons []string
+		for reason := range reqStatSummary.wsDisconnectReasons {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+		for _, reason := range reasons {
+			summary += "Disconnected, " + reason + ": " + fmt.Sprintf("%d", reqStatSummary.wsDisconnectReasons[reason]) + " sessions\n"
+		}
+	}
+
+	if reqStatSummary.streams > 0 {
+		summary += "\nStreams\n"
+		summary += "Count:                  " + fmt.Sprintf("%d", reqStatSummary.streams) + "\n"
+		summary += "Events:                 " + fmt.Sprintf("%d", reqStatSummary.streamEvents) + "\n"
+		summary += "Mean events:            " + fmt.Sprintf("%.2f", float64(reqStatSummary.streamEvents)/float64(reqStatSummary.streams)) + " per stream\n"
+		summary += "Mean time to 1st event: " + fmt.Sprintf("%d", reqStatSummary.avgTimeToFirstEvent/1000000) + " ms\n"
+		summary += "Mean event gap:         " + fmt.Sprintf("%d", reqStatSummary.avgEventGap/1000000) + " ms\n"
+		summary += "Shortest event gap:     " + fmt.Sprintf("%d", reqStatSummary.minEventGap/1000000) + " ms\n"
+		summary += "Longest event gap:      " + fmt.Sprintf("%d", reqStatSummary.maxEventGap/1000000) + " ms\n"
+		summary += "Mean stream lifetime:   " + fmt.Sprintf("%d", reqStatSummary.avgStreamLifetime/1000000) + " ms\n"
+		var reasons []string
+		for reason := range reqStatSummary.streamEndReasons {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+		for _, reason := range reasons {
+			summary += "Ended, " + reason + ": " + fmt.Sprintf("%d", reqStatSummary.streamEndReasons[reason]) + " streams\n"
+		}
+	}
+
+	if len(reqStatSummary.graphQLOperations) > 0 {
+		summary += "\nGraphQL Operations\n"
+		var names []string
+		for name := range reqStatSummary.graphQLOperations {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			operation := reqStatSummary.graphQLOperations[name]
+			summary += name + ": " + fmt.Sprintf("%d", operation.requests) + " requests, " +
+				fmt.Sprintf("%d", operation.failures) + " failed (" +
+				fmt.Sprintf("%.2f", 100*float64(operation.failures)/float64(operation.requests)) + "%), " +
+				fmt.Sprintf("%d", operation.errors) + " errors, mean " +
+				fmt.Sprintf("%d", operation.avgDuration/1000000) + " ms\n"
+		}
+	}
+
+	if len(reqStatSummary.grpcCodes) > 0 {
+		summary += "\ngRPC\n"
+		summary += "Messages sent:          " + fmt.Sprintf("%d", reqStatSummary.grpcMessagesSent) + "\n"
+		summary += "Messages received:      " + fmt.Sprintf("%d", reqStatSummary.grpcMessagesReceived) + "\n"
+		if reqStatSummary.grpcStreamMessages > 0 {
+			summary += "Mean stream message:    " + fmt.Sprintf("%d", reqStatSummary.avgGRPCMessageTime/1000000) + " ms\n"
+			summary += "Fastest stream message: " + fmt.Sprintf("%d", reqStatSummary.minGRPCMessageTime/1000000) + " ms\n"
+			summary += "Slowest stream message: " + fmt.Sprintf("%d", reqStatSummary.maxGRPCMessageTime/1000000) + " ms\n"
+		}
+
+		summary += "\ngRPC Status Codes\n"
+		var grpcCodes []string
+		totalCalls := 0
+		for code, count := range reqStatSummary.grpcCodes {
+			grpcCodes = append(grpcCodes, code)
+			totalCalls += count
+		}
+		sort.Strings(grpcCodes)
+		for _, code := range grpcCodes {
+			summary += code + ": " + fmt.Sprintf("%d", reqStatSummary.grpcCodes[code]) + " calls"
+			summary += " (" + fmt.Sprintf("%.2f", 100*float64(reqStatSummary.grpcCodes[code])/float64(totalCalls)) + "%)\n"
+		}
+	}
+
 	//sort the status codes
 	var codes []int
 	totalResponses := 0
@@ -36,6 +157,10 @@ func CreateTextSummary(reqStatSummary RequestStatSummary) string {
 		totalResponses += val
 	}
 	sort.Ints(codes)
+	//only gRPC calls were made
+	if len(codes) > 0 || len(reqStatSummary.grpcCodes) == 0 {
+		summary = summary + "\nResponse Codes\n"
+	}
 	for _, code := range codes {
 		if code == 0 {
 			summary += "Failed"
@@ -50,14 +175,44 @@ func CreateTextSummary(reqStatSummary RequestStatSummary) string {
 		}
 		summary += " (" + fmt.Sprintf("%.2f", 100*float64(reqStatSummary.statusCodes[code])/float64(totalResponses)) + "%)\n"
 	}
+
+	if len(reqStatSummary.errorKinds) > 0 {
+		summary += "\nFailed Requests\n"
+		var kinds []string
+		for kind := range reqStatSummary.errorKinds {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		for _, kind := range kinds {
+			summary += kind + ": " + fmt.Sprintf("%d", reqStatSummary.errorKinds[kind]) + " requests\n"
+		}
+	}
 	return summary
 }
 
-//print colored single line stats per RequestStat
-func printStat(stat RequestStat, w io.Writer) {
+//print colored single line stats per RequestStat, with secrets replaced by redactor
+func printStat(stat RequestStat, redactor *strings.Replacer, w io.Writer) {
 	if stat.Error != nil {
 		color.Set(color.FgRed)
-		fmt.Fprintln(w, "Failed to make request: "+stat.Error.Error())
+		if stat.ErrorKind != "" {
+			fmt.Fprintln(w, "Failed to make request ("+stat.ErrorKind+"): "+redactor.Replace(stat.Error.Error()))
+		} else {
+			fmt.Fprintln(w, "Failed to make request: "+redactor.Replace(stat.Error.Error()))
+		}
+		color.Unset()
+	} else if stat.GRPC != nil {
+		if stat.GRPC.Code == "OK" {
+			color.Set(color.FgGreen)
+		} else {
+			color.Set(color.FgRed)
+		}
+		fmt.Fprintf(w, "%s %s\t%d bytes\t%d ms\t-> %s %s\n",
+			stat.Proto,
+			stat.GRPC.Code,
+			stat.DataTransferred,
+			stat.Duration.Nanoseconds()/1000000,
+			stat.Method,
+			redactor.Replace(stat.URL))
 		color.Unset()
 	} else {
 		if stat.StatusCode >= 100 && stat.StatusCode < 200 {
@@ -77,13 +232,13 @@ func printStat(stat RequestStat, w io.Writer) {
 			stat.DataTransferred,
 			stat.Duration.Nanoseconds()/1000000,
 			stat.Method,
-			stat.URL)
+			redactor.Replace(stat.URL))
 		color.Unset()
 	}
 }
 
-//print tons of info about the request, response and response body
-func printVerbose(req *http.Request, response *http.Response, w io.Writer) {
+//print tons of info about the request, response and response body, with secrets replaced by redactor
+func printVerbose(req *http.Request, response *http.Response, redactor *strings.Replacer, w io.Writer) {
 	if req == nil {
 		return
 	}
@@ -92,7 +247,7 @@ func printVerbose(req *http.Request, response *http.Response, w io.Writer) {
 	}
 	var requestInfo string
 	//request details
-	requestInfo = requestInfo + fmt.Sprintf("Request:\n%+v\n\n", &req)
+	requestInfo = requestInfo + fmt.Sprintf("Request:\n%+v\n\n", req)
 
 	//reponse metadata
 	requestInfo = requestInfo + fmt.Sprintf("Response:\n%+v\n\n", response)
@@ -105,5 +260,5 @@ func printVerbose(req *http.Request, response *http.Response, w io.Writer) {
 		requestInfo = requestInfo + fmt.Sprintf("Body:\n%s\n\n", body)
 		response.Body.Close()
 	}
-	fmt.Fprintln(w, requestInfo)
+	fmt.Fprintln(w, redactor.Replace(requestInfo))
 }
@@ -1,9 +1,11 @@
 package pewpew
 
 import (
+	"bytes"
 	"errors"
 	"io/ioutil"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 )
@@ -25,6 +27,50 @@ func TestCreateTextSummary(t *testing.T) {
 			maxDataTransferred:   12345,
 			minDataTransferred:   1234,
 			totalDataTransferred: 123456,
+			connCount:            12,
+			maxConnStreams:       3,
+			avgConnRequests:      2.5,
+			maxConnRequests:      5,
+			minConnRequests:      1,
+			handshakeCount:       4,
+			avgHandshakeTime:     1234,
+			zeroRTTCount:         2,
+			errorKinds:           map[string]int{ErrorDialTimeout: 1, ErrorOther: 2},
+			wsSessions:           3,
+			wsMessagesSent:       30,
+			wsMessagesReceived:   29,
+			wsUnanswered:         1,
+			wsRoundTrips:         29,
+			avgWSRoundTrip:       1234,
+			maxWSRoundTrip:       2345,
+			minWSRoundTrip:       123,
+			avgWSSession:         12345,
+			wsDisconnectReasons:  map[string]int{"client closed": 2, "close 1011: gave up": 1},
+			grpcCodes:            map[string]int{"OK": 9, "Unavailable": 1},
+			grpcMessagesSent:     10,
+			grpcMessagesReceived: 30,
+			grpcStreamMessages:   30,
+			avgGRPCMessageTime:   1234,
+			maxGRPCMessageTime:   2345,
+			minGRPCMessageTime:   123,
+			streams:              4,
+			streamEvents:         40,
+			avgTimeToFirstEvent:  1234,
+			avgEventGap:          1234,
+			maxEventGap:          2345,
+			minEventGap:          123,
+			avgStreamLifetime:    12345,
+			streamEndReasons:     map[string]int{"server closed": 3, "client closed": 1},
+			graphQLOperations: map[string]graphQLOperationSummary{
+				"GetUser": {requests: 9, failures: 1, avgDuration: 1234, errors: 1},
+				"AddUser": {requests: 1, failures: 1, errors: 2},
+			},
+			contentEncodings:     map[string]int{EncodingGzip: 3, EncodingBrotli: 1},
+			encodedBodyBytes:     1234,
+			decodedBodyBytes:     12345,
+			authChallenged:       3,
+			authRoundTrips:       4,
+			avgAuthRoundTripTime: 1234,
 		}}, //nonzero values for everything
 	}
 	for _, c := range cases {
@@ -46,9 +92,13 @@ func TestPrintStat(t *testing.T) {
 		{RequestStat{StatusCode: 500}},
 		//error case
 		{RequestStat{Error: errors.New("this is an error")}},
+		{RequestStat{Error: errors.New("this is an error"), ErrorKind: ErrorTimeout}},
+		//gRPC status codes
+		{RequestStat{GRPC: &GRPCStat{Code: "OK"}}},
+		{RequestStat{GRPC: &GRPCStat{Code: "Unavailable"}}},
 	}
 	for _, c := range cases {
-		printStat(c.r, ioutil.Discard)
+		printStat(c.r, strings.NewReplacer(), ioutil.Discard)
 	}
 }
 
@@ -63,6 +113,19 @@ func TestPrintVerbose(t *testing.T) {
 		{&http.Request{}, &http.Response{Body: http.NoBody}},
 	}
 	for _, c := range cases {
-		printVerbose(c.req, c.resp, ioutil.Discard)
+		printVerbose(c.req, c.resp, strings.NewReplacer(), ioutil.Discard)
+	}
+
+	//secrets are redacted from the request and the response
+	req, err := http.NewRequest("GET", "http://localhost/?key=hunter2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer hunter2")
+	resp := &http.Response{Body: ioutil.NopCloser(strings.NewReader(`{"echo": "hunter2"}`))}
+	var w bytes.Buffer
+	printVerbose(req, resp, newRedactor(StressConfig{Secrets: []string{"hunter2"}}), &w)
+	if strings.Contains(w.String(), "hunter2") || !strings.Contains(w.String(), "Bearer [REDACTED]") {
+		t.Errorf("printVerbose didn't redact the secret:\n%s", w.String())
 	}
 }
@@ -1,17 +1,120 @@
 package pewpew
 
 import (
+	"context"
+	"crypto/tls"
+	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/http/httputil"
+	"sync"
 	"time"
 )
 
-func runRequest(req http.Request, client *http.Client) (response *http.Response, stat RequestStat) {
+//make the request, and read the response body in full or as a stream of events when stream is set
+func runRequest(req http.Request, client *http.Client, conns *connTracker, responseHeaderTimeout time.Duration, stream *streamOptions) (response *http.Response, stat RequestStat) {
+	//record which connection the request went out on
+	var connID, connStreams int
+	var sourceIP string
+	//record the handshake if the request opened a new connection,
+	//locked as the transport may finish dialing after the request is done
+	var traceLock sync.Mutex
+	var tlsStartTime time.Time
+	var handshakeTime time.Duration
+	//cancel the request when the response headers don't arrive in time after it was sent
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+	var responseHeaderTimer *time.Timer
+	responseHeaderTimedOut := false
+	//the client has no timeout for streams, as it would cut them off, so limit the wait for headers here
+	var streamTimer *time.Timer
+	streamTimedOut := false
+	if stream != nil && stream.timeout > 0 {
+		streamTimer = time.AfterFunc(stream.timeout, func() {
+			traceLock.Lock()
+			streamTimedOut = true
+			traceLock.Unlock()
+			cancel()
+		})
+	}
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if host, _, err := net.SplitHostPort(info.Conn.LocalAddr().String()); err == nil {
+				sourceIP = host
+			}
+			if conns == nil {
+				return
+			}
+			if connID != 0 {
+				//request was retried on another connection
+				conns.release(connID)
+			}
+			connID, connStreams = conns.acquire(info.Conn)
+		},
+		TLSHandshakeStart: func() {
+			traceLock.Lock()
+			tlsStartTime = time.Now()
+			traceLock.Unlock()
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			traceLock.Lock()
+			if err == nil {
+				handshakeTime = time.Since(tlsStartTime)
+			}
+			traceLock.Unlock()
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			if responseHeaderTimeout == 0 {
+				return
+			}
+			traceLock.Lock()
+			if responseHeaderTimer == nil {
+				responseHeaderTimer = time.AfterFunc(responseHeaderTimeout, func() {
+					traceLock.Lock()
+					responseHeaderTimedOut = true
+					traceLock.Unlock()
+					cancel()
+				})
+			}
+			traceLock.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			traceLock.Lock()
+			if responseHeaderTimer != nil {
+				responseHeaderTimer.Stop()
+			}
+			traceLock.Unlock()
+		},
+	}
+	quicHandshake := &quicHandshake{}
+	ctx = httptrace.WithClientTrace(ctx, trace)
+	ctx = context.WithValue(ctx, quicHandshakeKey{}, quicHandshake)
+	sentReq := req.WithContext(ctx)
+
 	reqStartTime := time.Now()
-	response, responseErr := (*client).Do(&req)
+	response, responseErr := (*client).Do(sentReq)
 	reqEndTime := time.Now()
+	traceLock.Lock()
+	if responseHeaderTimer != nil {
+		responseHeaderTimer.Stop()
+	}
+	if streamTimer != nil {
+		streamTimer.Stop()
+	}
+	traceLock.Unlock()
 
 	if responseErr != nil {
+		if connID != 0 {
+			conns.release(connID)
+		}
+		errorKind := classifyError(responseErr)
+		traceLock.Lock()
+		if responseHeaderTimedOut {
+			errorKind = ErrorResponseHeaderTimeout
+		} else if streamTimedOut {
+			errorKind = ErrorTimeout
+		}
+		traceLock.Unlock()
 		stat = RequestStat{
 			Proto:           req.Proto,
 			URL:             req.URL.String(),
@@ -22,16 +125,63 @@ func runRequest(req http.Request, client *http.Client) (response *http.Response,
 			StatusCode:      0,
 			Error:           responseErr,
 			DataTransferred: 0,
+			ErrorKind:       errorKind,
 		}
 		return
 	}
 
-	//get size of request
-	reqDump, _ := httputil.DumpRequestOut(&req, true)
-	respDump, _ := httputil.DumpResponse(response, true)
+	//get size of request, without reading a large body into memory again
+	reqDump, _ := httputil.DumpRequestOut(&req, false)
+	var streamStat *StreamStat
+	totalSizeReceivedBytes := 0
+	//responses are decoded here, as the transports don't ask for compression themselves
+	contentEncoding := responseEncoding(response.Header.Get("Content-Encoding"))
+	var encodedBodySize, decodedBodySize int
+	var errorKind string
+	if stream != nil {
+		respDump, _ := httputil.DumpResponse(response, false)
+		var decoder *streamDecoder
+		if contentEncoding != "" {
+			decoder = &streamDecoder{encoding: contentEncoding, body: response.Body}
+			response.Body = decoder
+		}
+		var bodyBytes int
+		streamStat, bodyBytes = readStream(response, reqStartTime, stream.duration, cancel)
+		if decoder != nil {
+			encodedBodySize, decodedBodySize = decoder.encodedBytes, bodyBytes
+			bodyBytes = decoder.encodedBytes
+		}
+		totalSizeReceivedBytes = len(respDump) + bodyBytes
+		//the body has been consumed by the stream
+		response.Body = http.NoBody
+	} else {
+		respDump, _ := httputil.DumpResponse(response, true)
+		totalSizeReceivedBytes = len(respDump)
+		if contentEncoding != "" {
+			encodedBodySize, decodedBodySize, responseErr = decodeResponseBody(response, contentEncoding)
+			if responseErr != nil {
+				errorKind = ErrorContentEncoding
+			}
+		}
+	}
 	totalSizeSentBytes := len(reqDump)
-	totalSizeReceivedBytes := len(respDump)
+	if req.ContentLength > 0 {
+		totalSizeSentBytes += int(req.ContentLength)
+	}
 	totalSizeBytes := totalSizeSentBytes + totalSizeReceivedBytes
+	//the response body has been read in full, so the stream is done
+	if connID != 0 {
+		conns.release(connID)
+	}
+
+	//QUIC handshakes finish in the background when the request was sent as 0-RTT data
+	quicHandshake.wg.Wait()
+	traceLock.Lock()
+	if quicHandshake.duration != 0 {
+		handshakeTime = quicHandshake.duration
+	}
+	reqHandshakeTime := handshakeTime
+	traceLock.Unlock()
 
 	stat = RequestStat{
 		Proto:           response.Proto,
@@ -43,6 +193,16 @@ func runRequest(req http.Request, client *http.Client) (response *http.Response,
 		StatusCode:      response.StatusCode,
 		Error:           responseErr,
 		DataTransferred: totalSizeBytes,
+		ConnID:          connID,
+		ConnStreams:     connStreams,
+		HandshakeTime:   reqHandshakeTime,
+		Used0RTT:        quicHandshake.used0RTT,
+		SourceIP:        sourceIP,
+		Stream:          streamStat,
+		ErrorKind:       errorKind,
+		ContentEncoding: contentEncoding,
+		EncodedBodySize: encodedBodySize,
+		DecodedBodySize: decodedBodySize,
 	}
 	return
 }
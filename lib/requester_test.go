@@ -18,11 +18,14 @@ func TestRunRequest(t *testing.T) {
 	cases := []struct {
 		r http.Request
 		c *http.Client
+		t *connTracker
 	}{
-		{*badRequest, &http.Client{}},
-		{*goodRequest, &http.Client{}},
+		{*badRequest, &http.Client{}, nil},
+		{*goodRequest, &http.Client{}, nil},
+		{*badRequest, &http.Client{}, newConnTracker()},
+		{*goodRequest, &http.Client{}, newConnTracker()},
 	}
 	for _, c := range cases {
-		runRequest(c.r, c.c)
+		runRequest(c.r, c.c, c.t, 0, nil)
 	}
 }
@@ -0,0 +1,247 @@
+package pewpew
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//RequestSigner signs a request just before it is sent, after buildRequest and any other auth.
+//It is called once per request, from many workers at once.
+type RequestSigner interface {
+	Sign(req *http.Request) error
+}
+
+//Default headers of HMAC signing
+const (
+	DefaultHMACHeader          = "X-Signature"
+	DefaultHMACTimestampHeader = "X-Timestamp"
+)
+
+//the signer of the target's requests: its own Signer, or one of the built in ones, nil if it has none
+func newRequestSigner(t Target) RequestSigner {
+	switch {
+	case t.Signer != nil:
+		return t.Signer
+	case t.HMACKey != "":
+		signer := &HMACSigner{Key: t.HMACKey, Header: t.HMACHeader, TimestampHeader: t.HMACTimestampHeader}
+		if signer.Header == "" {
+			signer.Header = DefaultHMACHeader
+		}
+		if signer.TimestampHeader == "" {
+			signer.TimestampHeader = DefaultHMACTimestampHeader
+		}
+		return signer
+	case t.AWSAccessKeyID != "":
+		return &AWSSigV4Signer{
+			AccessKeyID:     t.AWSAccessKeyID,
+			SecretAccessKey: t.AWSSecretAccessKey,
+			SessionToken:    t.AWSSessionToken,
+			Region:          t.AWSRegion,
+			Service:         t.AWSService,
+		}
+	}
+	return nil
+}
+
+//hex encoded SHA-256 of the request's body, read from a copy so the body can still be sent
+func hashBody(req *http.Request) (string, error) {
+	hash := sha256.New()
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return "", errors.New("request body can't be read again to sign it")
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return "", errors.New("failed to read request body to sign it: " + err.Error())
+		}
+		_, err = io.Copy(hash, body)
+		body.Close()
+		if err != nil {
+			return "", errors.New("failed to read request body to sign it: " + err.Error())
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+//HMACSigner signs requests with a hex encoded HMAC-SHA256 in Header, of the string
+//	METHOD + "\n" + path and query + "\n" + Unix timestamp + "\n" + hex SHA-256 of the body
+//The timestamp is sent in TimestampHeader.
+type HMACSigner struct {
+	Key             string
+	Header          string
+	TimestampHeader string
+	//for tests, time.Now when nil
+	now func() time.Time
+}
+
+//Sign sets the timestamp and signature headers of req
+func (s *HMACSigner) Sign(req *http.Request) error {
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	bodyHash, err := hashBody(req)
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(now().Unix(), 10)
+	stringToSign := req.Method + "\n" + req.URL.RequestURI() + "\n" + timestamp + "\n" + bodyHash
+	req.Header.Set(s.TimestampHeader, timestamp)
+	req.Header.Set(s.Header, hex.EncodeToString(hmacSHA256([]byte(s.Key), stringToSign)))
+	return nil
+}
+
+//AWSSigV4Signer signs requests with AWS Signature Version 4, in the Authorization header
+type AWSSigV4Signer struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	//for temporary credentials, sent as X-Amz-Security-Token
+	SessionToken string
+	Region       string
+	Service      string
+	//for tests, time.Now when nil
+	now func() time.Time
+}
+
+//Sign sets the date, content hash and Authorization headers of req
+func (s *AWSSigV4Signer) Sign(req *http.Request) error {
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	bodyHash, err := hashBody(req)
+	if err != nil {
+		return err
+	}
+	signTime := now().UTC()
+	amzDate := signTime.Format("20060102T150405Z")
+	date := signTime.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	//S3 requires the payload hash as a header, other services don't use it
+	if s.Service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", bodyHash)
+	}
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	canonicalHeaders, signedHeaders := awsCanonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsCanonicalURI(req.URL, s.Service),
+		awsCanonicalQuery(req.URL),
+		canonicalHeaders,
+		signedHeaders,
+		bodyHash,
+	}, "\n")
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := date + "/" + s.Region + "/" + s.Service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, s.Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+	return nil
+}
+
+//percent encode everything but the unreserved characters of RFC 3986, as SigV4 requires
+func awsURIEncode(s string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for _, b := range []byte(s) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9',
+			b == '-', b == '_', b == '.', b == '~':
+			encoded.WriteByte(b)
+		case b == '/' && !encodeSlash:
+			encoded.WriteByte(b)
+		default:
+			encoded.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{b})))
+		}
+	}
+	return encoded.String()
+}
+
+//the path, encoded once for S3 and twice for every other service
+func awsCanonicalURI(u *url.URL, service string) string {
+	path := u.Path
+	if path == "" {
+		return "/"
+	}
+	encoded := awsURIEncode(path, false)
+	if service != "s3" {
+		encoded = awsURIEncode(encoded, false)
+	}
+	return encoded
+}
+
+//the query parameters, encoded and sorted by name then value
+func awsCanonicalQuery(u *url.URL) string {
+	var params [][2]string
+	for name, values := range u.Query() {
+		for _, value := range values {
+			params = append(params, [2]string{awsURIEncode(name, true), awsURIEncode(value, true)})
+		}
+	}
+	sort.Slice(params, func(i, j int) bool {
+		if params[i][0] != params[j][0] {
+			return params[i][0] < params[j][0]
+		}
+		return params[i][1] < params[j][1]
+	})
+	pairs := make([]string, len(params))
+	for i, param := range params {
+		pairs[i] = param[0] + "=" + param[1]
+	}
+	return strings.Join(pairs, "&")
+}
+
+//the canonical headers and the list of their names. Signs the host, the content type
+//and every X-Amz header, as other headers may be changed by proxies on the way.
+func awsCanonicalHeaders(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name != "content-type" && !strings.HasPrefix(name, "x-amz-") {
+			continue
+		}
+		trimmed := make([]string, len(values))
+		for i, value := range values {
+			trimmed[i] = strings.Join(strings.Fields(value), " ")
+		}
+		headers[name] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonical strings.Builder
+	for _, name := range names {
+		canonical.WriteString(name + ":" + headers[name] + "\n")
+	}
+	return canonical.String(), strings.Join(names, ";")
+}
@@ -0,0 +1,197 @@
+package pewpew
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAWSSigV4Signer(t *testing.T) {
+	//cases from the AWS Signature Version 4 test suite
+	signTime := func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) }
+	cases := []struct {
+		method      string
+		url         string
+		contentType string
+		body        string
+		want        string
+	}{
+		{"GET", "https://example.amazonaws.com/", "", "",
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"GET", "https://example.amazonaws.com/?Param2=value2&Param1=value1", "", "",
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+		{"POST", "https://example.amazonaws.com/", "application/x-www-form-urlencoded", "Param1=value1",
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a"},
+	}
+	signer := &AWSSigV4Signer{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		Region:          "us-east-1",
+		Service:         "service",
+		now:             signTime,
+	}
+	for _, c := range cases {
+		req, err := http.NewRequest(c.method, c.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.body != "" {
+			(&requestBody{data: []byte(c.body)}).setOn(req)
+		}
+		if c.contentType != "" {
+			req.Header.Set("Content-Type", c.contentType)
+		}
+		if err := signer.Sign(req); err != nil {
+			t.Fatalf("Sign(%s %s) err: %s", c.method, c.url, err)
+		}
+		if got := req.Header.Get("Authorization"); got != c.want {
+			t.Errorf("Sign(%s %s) Authorization == %q wanted %q", c.method, c.url, got, c.want)
+		}
+		if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+			t.Errorf("Sign(%s %s) X-Amz-Date == %q", c.method, c.url, got)
+		}
+	}
+
+	//S3 gets the payload hash as a header, and temporary credentials send their token
+	s3Signer := &AWSSigV4Signer{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", SessionToken: "token", Region: "us-east-1", Service: "s3", now: signTime}
+	req, _ := http.NewRequest("GET", "https://bucket.s3.amazonaws.com/photos/cat.png", nil)
+	if err := s3Signer.Sign(req); err != nil {
+		t.Fatalf("Sign err: %s", err)
+	}
+	if got, want := req.Header.Get("X-Amz-Content-Sha256"), "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"; got != want {
+		t.Errorf("X-Amz-Content-Sha256 == %q wanted %q", got, want)
+	}
+	if got := req.Header.Get("X-Amz-Security-Token"); got != "token" {
+		t.Errorf("X-Amz-Security-Token == %q wanted %q", got, "token")
+	}
+	if got := req.Header.Get("Authorization"); !strings.Contains(got, "SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-security-token,") {
+		t.Errorf("Authorization == %q doesn't sign the X-Amz headers", got)
+	}
+}
+
+func TestAWSCanonicalURI(t *testing.T) {
+	cases := []struct {
+		url     string
+		service string
+		want    string
+	}{
+		{"https://localhost", "service", "/"},
+		{"https://localhost/", "service", "/"},
+		{"https://localhost/a b/c", "s3", "/a%20b/c"},
+		{"https://localhost/a b/c", "service", "/a%2520b/c"},
+		{"https://localhost/a~b-c_d.e", "service", "/a~b-c_d.e"},
+	}
+	for _, c := range cases {
+		u, _ := http.NewRequest("GET", c.url, nil)
+		if got := awsCanonicalURI(u.URL, c.service); got != c.want {
+			t.Errorf("awsCanonicalURI(%q, %q) == %q wanted %q", c.url, c.service, got, c.want)
+		}
+	}
+}
+
+//the HMAC-SHA256 signature a server would expect
+func testHMACSignature(key, method, uri, timestamp, body string) string {
+	bodyHash := sha256.Sum256([]byte(body))
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(method + "\n" + uri + "\n" + timestamp + "\n" + hex.EncodeToString(bodyHash[:])))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestHMACSigner(t *testing.T) {
+	signer := &HMACSigner{Key: "secret", Header: "X-Sig", TimestampHeader: "X-Time", now: func() time.Time { return time.Unix(1500000000, 0) }}
+	req, _ := http.NewRequest("POST", "https://localhost/orders?page=2", nil)
+	(&requestBody{data: []byte(`{"item": 1}`)}).setOn(req)
+	if err := signer.Sign(req); err != nil {
+		t.Fatalf("Sign err: %s", err)
+	}
+	if got := req.Header.Get("X-Time"); got != "1500000000" {
+		t.Errorf("X-Time == %q wanted %q", got, "1500000000")
+	}
+	if got, want := req.Header.Get("X-Sig"), testHMACSignature("secret", "POST", "/orders?page=2", "1500000000", `{"item": 1}`); got != want {
+		t.Errorf("X-Sig == %q wanted %q", got, want)
+	}
+	//the body can still be sent
+	body, _ := ioutil.ReadAll(req.Body)
+	if string(body) != `{"item": 1}` {
+		t.Errorf("body after signing == %q", body)
+	}
+}
+
+type testSigner struct {
+	signed int32
+	err    error
+}
+
+func (s *testSigner) Sign(req *http.Request) error {
+	atomic.AddInt32(&s.signed, 1)
+	req.Header.Set("X-Signed", "yes")
+	return s.err
+}
+
+func TestRunStressSigning(t *testing.T) {
+	//accepts requests signed with the key "secret" in the default headers, or by a testSigner
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Signed") == "yes" {
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		timestamp := r.Header.Get(DefaultHMACTimestampHeader)
+		if unix, err := strconv.ParseInt(timestamp, 10, 64); err != nil || time.Since(time.Unix(unix, 0)) > time.Minute {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get(DefaultHMACHeader) != testHMACSignature("secret", r.Method, r.URL.RequestURI(), timestamp, string(body)) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	signer := &testSigner{}
+	failingSigner := &testSigner{err: errors.New("no keys")}
+	cases := []struct {
+		target     Target
+		statusCode int
+		errorKind  string
+	}{
+		{Target{HMACKey: "secret"}, http.StatusOK, ""},
+		{Target{HMACKey: "secret", Method: "POST", Body: "pewpew", URL: server.URL + "/path?a=b"}, http.StatusOK, ""},
+		{Target{HMACKey: "secret", Method: "POST", BodySize: "1KB", BodyEncoding: EncodingGzip}, http.StatusOK, ""},
+		{Target{HMACKey: "wrong"}, http.StatusUnauthorized, ""},
+		{Target{Signer: signer}, http.StatusOK, ""},
+		{Target{Signer: failingSigner}, 0, ErrorSigning},
+	}
+	for _, c := range cases {
+		if c.target.URL == "" {
+			c.target.URL = server.URL
+		}
+		if c.target.Method == "" {
+			c.target.Method = DefaultMethod
+		}
+		c.target.Count = 4
+		c.target.Concurrency = 2
+		targetStats, err := RunStress(StressConfig{Targets: []Target{c.target}, Quiet: true}, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("RunStress(%+v) err: %s", c.target, err)
+		}
+		for _, stat := range targetStats[0] {
+			if stat.StatusCode != c.statusCode {
+				t.Errorf("request of %+v status code == %d wanted %d", c.target, stat.StatusCode, c.statusCode)
+			}
+			if stat.ErrorKind != c.errorKind {
+				t.Errorf("request of %+v error kind == %q wanted %q", c.target, stat.ErrorKind, c.errorKind)
+			}
+		}
+	}
+	if signer.signed != 4 {
+		t.Errorf("signer signed %d requests wanted 4", signer.signed)
+	}
+}
@@ -0,0 +1,114 @@
+package pewpew
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+//sourceIPs hands out the local addresses to send from, taking turns per connection,
+//and the addresses to connect to in place of looking up the host
+type sourceIPs struct {
+	ips     []net.IP
+	counter uint64
+	//"host:port" to the "address:port" to dial instead
+	resolve map[string]string
+}
+
+//parse a comma separated list of local IP addresses, like "10.0.0.1, 10.0.0.2"
+func parseSourceIPs(sourceIPsStr string) ([]net.IP, error) {
+	var ips []net.IP
+	if sourceIPsStr == "" {
+		return ips, nil
+	}
+	for _, ipStr := range strings.Split(sourceIPsStr, ",") {
+		ip := net.ParseIP(strings.TrimSpace(ipStr))
+		if ip == nil {
+			return nil, errors.New("invalid source IP: " + ipStr)
+		}
+		ips = append(ips, ip)
+	}
+	return ips, nil
+}
+
+//parse addresses like curl's --resolve, "example.com:443:127.0.0.1", into
+//the "host:port" they replace and the "address:port" to dial instead
+func parseResolve(resolve []string) (map[string]string, error) {
+	addrs := make(map[string]string)
+	for _, entry := range resolve {
+		host, rest := entry, ""
+		if strings.HasPrefix(entry, "[") {
+			//IPv6 host
+			if end := strings.Index(entry, "]:"); end != -1 {
+				host, rest = entry[1:end], entry[end+2:]
+			}
+		} else if colon := strings.Index(entry, ":"); colon != -1 {
+			host, rest = entry[:colon], entry[colon+1:]
+		}
+		parts := strings.SplitN(rest, ":", 2)
+		if host == "" || len(parts) != 2 {
+			return nil, errors.New("resolve must be like example.com:443:127.0.0.1: " + entry)
+		}
+		port, err := strconv.Atoi(parts[0])
+		if err != nil || port <= 0 || port > 65535 {
+			return nil, errors.New("invalid port to resolve: " + entry)
+		}
+		ip := net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(parts[1], "["), "]"))
+		if ip == nil {
+			return nil, errors.New("invalid address to resolve to: " + entry)
+		}
+		addrs[net.JoinHostPort(strings.ToLower(host), parts[0])] = net.JoinHostPort(ip.String(), parts[0])
+	}
+	return addrs, nil
+}
+
+func newSourceIPs(sourceIPsStr string, resolve []string) *sourceIPs {
+	//already validated
+	ips, _ := parseSourceIPs(sourceIPsStr)
+	addrs, _ := parseResolve(resolve)
+	return &sourceIPs{ips: ips, resolve: addrs}
+}
+
+//next IP to send from, nil means let the system choose
+func (s *sourceIPs) next() net.IP {
+	if s == nil || len(s.ips) == 0 {
+		return nil
+	}
+	i := atomic.AddUint64(&s.counter, 1) - 1
+	return s.ips[i%uint64(len(s.ips))]
+}
+
+//address to dial for addr, which is its resolved one if it has one
+func (s *sourceIPs) remoteAddr(addr string) string {
+	if s == nil {
+		return addr
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if resolved, ok := s.resolve[net.JoinHostPort(strings.ToLower(host), port)]; ok {
+		return resolved
+	}
+	return addr
+}
+
+//dial TCP from the next source IP, to the resolved address if there is one
+func (s *sourceIPs) dialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		addr = s.remoteAddr(addr)
+		sourceDialer := *dialer
+		if ip := s.next(); ip != nil {
+			sourceDialer.LocalAddr = &net.TCPAddr{IP: ip}
+		}
+		conn, err := sourceDialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		//so its connTracker can forget it once it's closed
+		return newClosingConn(conn), nil
+	}
+}
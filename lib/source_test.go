@@ -0,0 +1,98 @@
+package pewpew
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestParseSourceIPs(t *testing.T) {
+	cases := []struct {
+		str    string
+		want   int
+		hasErr bool
+	}{
+		{"", 0, false},
+		{"127.0.0.1", 1, false},
+		{"10.0.0.1, 10.0.0.2,::1", 3, false},
+		{"localhost", 0, true},
+		{"10.0.0.1,", 0, true},
+		{"10.0.0.256", 0, true},
+	}
+	for _, c := range cases {
+		ips, err := parseSourceIPs(c.str)
+		if (err != nil) != c.hasErr {
+			t.Errorf("parseSourceIPs(%q) err: %t wanted %t", c.str, (err != nil), c.hasErr)
+			continue
+		}
+		if len(ips) != c.want {
+			t.Errorf("parseSourceIPs(%q) parsed %d IPs wanted %d", c.str, len(ips), c.want)
+		}
+	}
+}
+
+func TestSourceIPsNext(t *testing.T) {
+	var nilSources *sourceIPs
+	if ip := nilSources.next(); ip != nil {
+		t.Errorf("nil sourceIPs next() == %s wanted nil", ip)
+	}
+	if ip := newSourceIPs("", nil).next(); ip != nil {
+		t.Errorf("empty sourceIPs next() == %s wanted nil", ip)
+	}
+	sources := newSourceIPs("10.0.0.1,10.0.0.2", nil)
+	want := []string{"10.0.0.1", "10.0.0.2", "10.0.0.1"}
+	for i, wantIP := range want {
+		if ip := sources.next(); !ip.Equal(net.ParseIP(wantIP)) {
+			t.Errorf("sourceIPs next() call %d == %s wanted %s", i, ip, wantIP)
+		}
+	}
+}
+
+func TestParseResolve(t *testing.T) {
+	cases := []struct {
+		resolve []string
+		want    map[string]string
+		hasErr  bool
+	}{
+		{nil, map[string]string{}, false},
+		{[]string{"Example.com:443:127.0.0.1"}, map[string]string{"example.com:443": "127.0.0.1:443"}, false},
+		{[]string{"example.com:80:[::1]", "[::1]:8080:10.0.0.1"}, map[string]string{"example.com:80": "[::1]:80", "[::1]:8080": "10.0.0.1:8080"}, false},
+		{[]string{"example.com:443"}, nil, true},
+		{[]string{"example.com:https:127.0.0.1"}, nil, true},
+		{[]string{"example.com:0:127.0.0.1"}, nil, true},
+		{[]string{"example.com:443:localhost"}, nil, true},
+		{[]string{":443:127.0.0.1"}, nil, true},
+	}
+	for _, c := range cases {
+		addrs, err := parseResolve(c.resolve)
+		if (err != nil) != c.hasErr {
+			t.Errorf("parseResolve(%q) err: %t wanted %t", c.resolve, (err != nil), c.hasErr)
+			continue
+		}
+		if !c.hasErr && !reflect.DeepEqual(addrs, c.want) {
+			t.Errorf("parseResolve(%q) == %v wanted %v", c.resolve, addrs, c.want)
+		}
+	}
+}
+
+func TestSourceIPsRemoteAddr(t *testing.T) {
+	var nilSources *sourceIPs
+	if addr := nilSources.remoteAddr("example.com:443"); addr != "example.com:443" {
+		t.Errorf("nil sourceIPs remoteAddr() == %s wanted example.com:443", addr)
+	}
+	sources := newSourceIPs("", []string{"example.com:443:127.0.0.1"})
+	cases := []struct {
+		addr string
+		want string
+	}{
+		{"example.com:443", "127.0.0.1:443"},
+		{"EXAMPLE.com:443", "127.0.0.1:443"},
+		{"example.com:80", "example.com:80"},
+		{"other.com:443", "other.com:443"},
+	}
+	for _, c := range cases {
+		if addr := sources.remoteAddr(c.addr); addr != c.want {
+			t.Errorf("remoteAddr(%s) == %s wanted %s", c.addr, addr, c.want)
+		}
+	}
+}
@@ -18,6 +18,61 @@ type RequestStatSummary struct {
 	maxDataTransferred   int         //bytes
 	minDataTransferred   int         //bytes
 	totalDataTransferred int         //bytes
+	connCount            int         //distinct connections requests were sent over
+	maxConnStreams       int         //most requests in flight on one connection at once
+	avgConnRequests      float64     //requests served per connection
+	maxConnRequests      int         //requests served by the busiest connection
+	minConnRequests      int         //requests served by the least busy connection
+	handshakeCount       int         //requests that opened a connection with a TLS or QUIC handshake
+	avgHandshakeTime     time.Duration
+	zeroRTTCount         int            //requests sent as QUIC 0-RTT data
+	errorKinds           map[string]int //counts of each kind of failed request
+
+	wsSessions          int //WebSocket sessions opened
+	wsMessagesSent      int
+	wsMessagesReceived  int
+	wsUnanswered        int //messages that never got a reply
+	wsRoundTrips        int //messages that got a reply
+	avgWSRoundTrip      time.Duration
+	maxWSRoundTrip      time.Duration
+	minWSRoundTrip      time.Duration
+	avgWSSession        time.Duration
+	wsDisconnectReasons map[string]int //counts of each reason a WebSocket session ended
+
+	grpcCodes            map[string]int //counts of each gRPC status code
+	grpcMessagesSent     int
+	grpcMessagesReceived int
+	grpcStreamMessages   int //messages received on streams, which have their latency timed
+	avgGRPCMessageTime   time.Duration
+	maxGRPCMessageTime   time.Duration
+	minGRPCMessageTime   time.Duration
+
+	streams             int //responses read as streams
+	streamEvents        int
+	avgTimeToFirstEvent time.Duration //of the streams that had any events
+	avgEventGap         time.Duration
+	maxEventGap         time.Duration
+	minEventGap         time.Duration
+	avgStreamLifetime   time.Duration
+	streamEndReasons    map[string]int //counts of each reason a stream ended
+
+	graphQLOperations map[string]graphQLOperationSummary //GraphQL requests grouped by operation name
+
+	contentEncodings map[string]int //counts of each encoding responses were compressed with
+	encodedBodyBytes int            //size of the compressed response bodies
+	decodedBodyBytes int            //size of the compressed response bodies once decoded
+
+	authChallenged       int //requests that had to answer an auth challenge first
+	authRoundTrips       int //extra round trips made to answer auth challenges
+	avgAuthRoundTripTime time.Duration
+}
+
+//summary of the requests of one GraphQL operation
+type graphQLOperationSummary struct {
+	requests    int
+	failures    int //failed requests, including responses with errors
+	avgDuration time.Duration
+	errors      int //errors in responses
 }
 
 //CreateRequestsStats creates a statistical summary out of the individual RequestStats
@@ -37,9 +92,73 @@ func CreateRequestsStats(requestStats []RequestStat) RequestStatSummary {
 		totalDataTransferred: 0,
 	}
 	var totalDurations time.Duration //total time of all requests (concurrent is counted)
+	var totalHandshakeTime, totalAuthRoundTripTime time.Duration
+	var totalWSRoundTrip, totalWSSession time.Duration
+	var totalGRPCMessageTime time.Duration
+	var totalTimeToFirstEvent, totalEventGap, totalStreamLifetime time.Duration
+	streamsWithEvents, eventGaps := 0, 0
+	connRequests := make(map[int]int) //requests served by each connection
 	nonErrCount := 0
+	graphQLDurations := make(map[string]time.Duration) //total time of the succeeded requests of each operation
 	for i := 0; i < len(requestStats); i++ {
+		if graphQL := requestStats[i].GraphQL; graphQL != nil {
+			if summary.graphQLOperations == nil {
+				summary.graphQLOperations = make(map[string]graphQLOperationSummary)
+			}
+			operation := summary.graphQLOperations[graphQL.OperationName]
+			operation.requests++
+			operation.errors += len(graphQL.Errors)
+			if requestStats[i].Error != nil {
+				operation.failures++
+			} else {
+				graphQLDurations[graphQL.OperationName] += requestStats[i].Duration
+			}
+			summary.graphQLOperations[graphQL.OperationName] = operation
+		}
+		//the challenge was answered even if the request then failed
+		if requestStats[i].AuthRoundTrips > 0 {
+			summary.authChallenged++
+			summary.authRoundTrips += requestStats[i].AuthRoundTrips
+			totalAuthRoundTripTime += requestStats[i].AuthRoundTripTime
+		}
+		//sessions that failed partway still exchanged messages
+		if ws := requestStats[i].WebSocket; ws != nil {
+			if summary.wsDisconnectReasons == nil {
+				summary.wsDisconnectReasons = make(map[string]int)
+			}
+			summary.wsSessions++
+			summary.wsMessagesSent += ws.MessagesSent
+			summary.wsMessagesReceived += ws.MessagesReceived
+			summary.wsUnanswered += ws.Unanswered
+			summary.wsDisconnectReasons[ws.DisconnectReason]++
+			totalWSSession += ws.SessionDuration
+			for _, roundTrip := range ws.RoundTrips {
+				summary.wsRoundTrips++
+				totalWSRoundTrip += roundTrip
+				if roundTrip > summary.maxWSRoundTrip {
+					summary.maxWSRoundTrip = roundTrip
+				}
+				if roundTrip < summary.minWSRoundTrip || summary.minWSRoundTrip == 0 {
+					summary.minWSRoundTrip = roundTrip
+				}
+			}
+		}
+		//calls that failed still answered with a status code, like Unavailable
+		if call := requestStats[i].GRPC; call != nil {
+			if summary.grpcCodes == nil {
+				summary.grpcCodes = make(map[string]int)
+			}
+			summary.grpcCodes[call.Code]++
+		}
 		if requestStats[i].Error != nil {
+			if summary.errorKinds == nil {
+				summary.errorKinds = make(map[string]int)
+			}
+			errorKind := requestStats[i].ErrorKind
+			if errorKind == "" {
+				errorKind = ErrorOther
+			}
+			summary.errorKinds[errorKind]++
 			continue
 		}
 		nonErrCount++
@@ -65,7 +184,116 @@ func CreateRequestsStats(requestStats []RequestStat) RequestStatSummary {
 		}
 		summary.totalDataTransferred += requestStats[i].DataTransferred
 
-		summary.statusCodes[requestStats[i].StatusCode]++
+		//gRPC calls have a status code of their own instead
+		if requestStats[i].GRPC == nil {
+			summary.statusCodes[requestStats[i].StatusCode]++
+		}
+
+		if requestStats[i].ConnID != 0 {
+			connRequests[requestStats[i].ConnID]++
+		}
+		if requestStats[i].ConnStreams > summary.maxConnStreams {
+			summary.maxConnStreams = requestStats[i].ConnStreams
+		}
+
+		if requestStats[i].HandshakeTime > 0 {
+			summary.handshakeCount++
+			totalHandshakeTime += requestStats[i].HandshakeTime
+		}
+		if requestStats[i].Used0RTT {
+			summary.zeroRTTCount++
+		}
+
+		if call := requestStats[i].GRPC; call != nil {
+			summary.grpcMessagesSent += call.MessagesSent
+			summary.grpcMessagesReceived += call.MessagesReceived
+			for _, messageTime := range call.MessageLatencies {
+				summary.grpcStreamMessages++
+				totalGRPCMessageTime += messageTime
+				if messageTime > summary.maxGRPCMessageTime {
+					summary.maxGRPCMessageTime = messageTime
+				}
+				if messageTime < summary.minGRPCMessageTime || summary.minGRPCMessageTime == 0 {
+					summary.minGRPCMessageTime = messageTime
+				}
+			}
+		}
+
+		if encoding := requestStats[i].ContentEncoding; encoding != "" {
+			if summary.contentEncodings == nil {
+				summary.contentEncodings = make(map[string]int)
+			}
+			summary.contentEncodings[encoding]++
+			summary.encodedBodyBytes += requestStats[i].EncodedBodySize
+			summary.decodedBodyBytes += requestStats[i].DecodedBodySize
+		}
+
+		if stream := requestStats[i].Stream; stream != nil {
+			if summary.streamEndReasons == nil {
+				summary.streamEndReasons = make(map[string]int)
+			}
+			summary.streams++
+			summary.streamEvents += stream.Events
+			summary.streamEndReasons[stream.EndReason]++
+			totalStreamLifetime += stream.Lifetime
+			if stream.Events > 0 {
+				streamsWithEvents++
+				totalTimeToFirstEvent += stream.TimeToFirstEvent
+			}
+			for _, gap := range stream.EventGaps {
+				eventGaps++
+				totalEventGap += gap
+				if gap > summary.maxEventGap {
+					summary.maxEventGap = gap
+				}
+				if gap < summary.minEventGap || summary.minEventGap == 0 {
+					summary.minEventGap = gap
+				}
+			}
+		}
+	}
+	summary.connCount = len(connRequests)
+	for _, count := range connRequests {
+		if count > summary.maxConnRequests {
+			summary.maxConnRequests = count
+		}
+		if count < summary.minConnRequests || summary.minConnRequests == 0 {
+			summary.minConnRequests = count
+		}
+		summary.avgConnRequests += float64(count)
+	}
+	if summary.connCount > 0 {
+		summary.avgConnRequests /= float64(summary.connCount)
+	}
+	if summary.handshakeCount > 0 {
+		summary.avgHandshakeTime = totalHandshakeTime / time.Duration(summary.handshakeCount)
+	}
+	if summary.authRoundTrips > 0 {
+		summary.avgAuthRoundTripTime = totalAuthRoundTripTime / time.Duration(summary.authRoundTrips)
+	}
+	if summary.wsSessions > 0 {
+		summary.avgWSSession = totalWSSession / time.Duration(summary.wsSessions)
+	}
+	if summary.wsRoundTrips > 0 {
+		summary.avgWSRoundTrip = totalWSRoundTrip / time.Duration(summary.wsRoundTrips)
+	}
+	if summary.grpcStreamMessages > 0 {
+		summary.avgGRPCMessageTime = totalGRPCMessageTime / time.Duration(summary.grpcStreamMessages)
+	}
+	for name, operation := range summary.graphQLOperations {
+		if succeeded := operation.requests - operation.failures; succeeded > 0 {
+			operation.avgDuration = graphQLDurations[name] / time.Duration(succeeded)
+			summary.graphQLOperations[name] = operation
+		}
+	}
+	if summary.streams > 0 {
+		summary.avgStreamLifetime = totalStreamLifetime / time.Duration(summary.streams)
+	}
+	if streamsWithEvents > 0 {
+		summary.avgTimeToFirstEvent = totalTimeToFirstEvent / time.Duration(streamsWithEvents)
+	}
+	if eventGaps > 0 {
+		summary.avgEventGap = totalEventGap / time.Duration(eventGaps)
 	}
 	if nonErrCount == 0 {
 		summary.avgDuration = 0
@@ -55,6 +55,238 @@ func TestCreateRequestsStats(t *testing.T) {
 				startTime:   time.Unix(1000, 0),
 				endTime:     time.Unix(2000, 0),
 				statusCodes: map[int]int{},
+				errorKinds:  map[string]int{ErrorOther: 2},
+			},
+		},
+		//kinds of errors
+		{requestStats: []RequestStat{
+			{StartTime: time.Unix(1000, 0), EndTime: time.Unix(2000, 0), Duration: 1000, Error: errors.New("test error 1"), ErrorKind: ErrorDialTimeout},
+			{StartTime: time.Unix(1000, 0), EndTime: time.Unix(2000, 0), Duration: 1000, Error: errors.New("test error 2"), ErrorKind: ErrorResponseHeaderTimeout},
+			{StartTime: time.Unix(1000, 0), EndTime: time.Unix(2000, 0), Duration: 1000, Error: errors.New("test error 3"), ErrorKind: ErrorResponseHeaderTimeout},
+		},
+			want: RequestStatSummary{
+				startTime:   time.Unix(1000, 0),
+				endTime:     time.Unix(2000, 0),
+				statusCodes: map[int]int{},
+				errorKinds:  map[string]int{ErrorDialTimeout: 1, ErrorResponseHeaderTimeout: 2},
+			},
+		},
+		//connections, multiplexed and not
+		{requestStats: []RequestStat{
+			{StartTime: time.Unix(1000, 0), EndTime: time.Unix(2000, 0), Duration: 1000, StatusCode: 200, ConnID: 1, ConnStreams: 1},
+			{StartTime: time.Unix(1000, 0), EndTime: time.Unix(2000, 0), Duration: 1000, StatusCode: 200, ConnID: 1, ConnStreams: 3},
+			{StartTime: time.Unix(1000, 0), EndTime: time.Unix(2000, 0), Duration: 1000, StatusCode: 200, ConnID: 2, ConnStreams: 2},
+		},
+			want: RequestStatSummary{
+				avgRPS:          0.000000000003,
+				avgDuration:     1000,
+				maxDuration:     1000,
+				minDuration:     1000,
+				startTime:       time.Unix(1000, 0),
+				endTime:         time.Unix(2000, 0),
+				statusCodes:     map[int]int{200: 3},
+				connCount:       2,
+				maxConnStreams:  3,
+				avgConnRequests: 1.5,
+				maxConnRequests: 2,
+				minConnRequests: 1,
+			},
+		},
+		//handshakes, with and without 0-RTT
+		{requestStats: []RequestStat{
+			{StartTime: time.Unix(1000, 0), EndTime: time.Unix(2000, 0), Duration: 1000, StatusCode: 200, HandshakeTime: 300},
+			{StartTime: time.Unix(1000, 0), EndTime: time.Unix(2000, 0), Duration: 1000, StatusCode: 200, HandshakeTime: 100, Used0RTT: true},
+			{StartTime: time.Unix(1000, 0), EndTime: time.Unix(2000, 0), Duration: 1000, StatusCode: 200},
+		},
+			want: RequestStatSummary{
+				avgRPS:           0.000000000003,
+				avgDuration:      1000,
+				maxDuration:      1000,
+				minDuration:      1000,
+				startTime:        time.Unix(1000, 0),
+				endTime:          time.Unix(2000, 0),
+				statusCodes:      map[int]int{200: 3},
+				handshakeCount:   2,
+				avgHandshakeTime: 200,
+				zeroRTTCount:     1,
+			},
+		},
+		//WebSocket sessions
+		{requestStats: []RequestStat{
+			{StartTime: time.Unix(1000, 0), EndTime: time.Unix(2000, 0), Duration: 1000, StatusCode: 101, WebSocket: &WebSocketStat{
+				SessionDuration: 5000, MessagesSent: 3, MessagesReceived: 2, RoundTrips: []time.Duration{100, 300}, Unanswered: 1, DisconnectReason: "client closed"}},
+			{StartTime: time.Unix(1000, 0), EndTime: time.Unix(2000, 0), Duration: 1000, StatusCode: 101, WebSocket: &WebSocketStat{
+				SessionDuration: 1000, MessagesSent: 1, MessagesReceived: 1, RoundTrips: []time.Duration{200}, DisconnectReason: "close 1011: gave up"}},
+		},
+			want: RequestStatSummary{
+				avgRPS:              0.000000000002,
+				avgDuration:         1000,
+				maxDuration:         1000,
+				minDuration:         1000,
+				startTime:           time.Unix(1000, 0),
+				endTime:             time.Unix(2000, 0),
+				statusCodes:         map[int]int{101: 2},
+				wsSessions:          2,
+				wsMessagesSent:      4,
+				wsMessagesReceived:  3,
+				wsUnanswered:        1,
+				wsRoundTrips:        3,
+				avgWSRoundTrip:      200,
+				maxWSRoundTrip:      300,
+				minWSRoundTrip:      100,
+				avgWSSession:        3000,
+				wsDisconnectReasons: map[string]int{"client closed": 1, "close 1011: gave up": 1},
+			},
+		},
+		//WebSocket session that failed after exchanging messages, which still counts
+		{requestStats: []RequestStat{
+			{StartTime: time.Unix(1000, 0), EndTime: time.Unix(2000, 0), Duration: 1000, StatusCode: 101, Error: errors.New("failed to create WebSocket message"),
+				WebSocket: &WebSocketStat{SessionDuration: 2000, MessagesSent: 1, MessagesReceived: 1, RoundTrips: []time.Duration{100}, DisconnectReason: "client closed"}},
+		},
+			want: RequestStatSummary{
+				startTime:           time.Unix(1000, 0),
+				endTime:             time.Unix(2000, 0),
+				statusCodes:         map[int]int{},
+				errorKinds:          map[string]int{ErrorOther: 1},
+				wsSessions:          1,
+				wsMessagesSent:      1,
+				wsMessagesReceived:  1,
+				wsRoundTrips:        1,
+				avgWSRoundTrip:      100,
+				maxWSRoundTrip:      100,
+				minWSRoundTrip:      100,
+				avgWSSession:        2000,
+				wsDisconnectReasons: map[string]int{"client closed": 1},
+			},
+		},
+		//gRPC calls
+		{requestStats: []RequestStat{
+			{StartTime: time.Unix(1000, 0), EndTime: time.Unix(2000, 0), Duration: 1000, GRPC: &GRPCStat{
+				Code: "OK", MessagesSent: 1, MessagesReceived: 3, MessageLatencies: []time.Duration{100, 200, 300}}},
+			{StartTime: time.Unix(1000, 0), EndTime: time.Unix(2000, 0), Duration: 1000, GRPC: &GRPCStat{
+				Code: "Unavailable", Message: "down", MessagesSent: 1}},
+		},
+			want: RequestStatSummary{
+				avgRPS:               0.000000000002,
+				avgDuration:          1000,
+				maxDuration:          1000,
+				minDuration:          1000,
+				startTime:            time.Unix(1000, 0),
+				endTime:              time.Unix(2000, 0),
+				statusCodes:          map[int]int{},
+				grpcCodes:            map[string]int{"OK": 1, "Unavailable": 1},
+				grpcMessagesSent:     2,
+				grpcMessagesReceived: 3,
+				grpcStreamMessages:   3,
+				avgGRPCMessageTime:   200,
+				maxGRPCMessageTime:   300,
+				minGRPCMessageTime:   100,
+			},
+		},
+		//gRPC calls that failed, whose codes are still counted
+		{requestStats: []RequestStat{
+			{StartTime: time.Unix(1000, 0), EndTime: time.Unix(2000, 0), Duration: 1000, GRPC: &GRPCStat{Code: "OK", MessagesSent: 1, MessagesReceived: 1}},
+			{StartTime: time.Unix(1000, 0), EndTime: time.Unix(2000, 0), Duration: 1000, Error: errors.New("deadline exceeded"), ErrorKind: ErrorTimeout,
+				GRPC: &GRPCStat{Code: "DeadlineExceeded", Message: "deadline exceeded", MessagesSent: 1}},
+		},
+			want: RequestStatSummary{
+				avgRPS:               0.000000000001,
+				avgDuration:          1000,
+				maxDuration:          1000,
+				minDuration:          1000,
+				startTime:            time.Unix(1000, 0),
+				endTime:              time.Unix(2000, 0),
+				statusCodes:          map[int]int{},
+				errorKinds:           map[string]int{ErrorTimeout: 1},
+				grpcCodes:            map[string]int{"OK": 1, "DeadlineExceeded": 1},
+				grpcMessagesSent:     1,
+				grpcMessagesReceived: 1,
+			},
+		},
+		//streams
+		{requestStats: []RequestStat{
+			{StartTime: time.Unix(1000, 0), EndTime: time.Unix(2000, 0), Duration: 1000, StatusCode: 200, Stream: &StreamStat{
+				TimeToFirstEvent: 2000, Events: 3, EventGaps: []time.Duration{100, 300}, Lifetime: 5000, EndReason: "server closed"}},
+			{StartTime: time.Unix(1000, 0), EndTime: time.Unix(2000, 0), Duration: 1000, StatusCode: 200, Stream: &StreamStat{
+				Lifetime: 1000, EndReason: "client closed"}},
+		},
+			want: RequestStatSummary{
+				avgRPS:              0.000000000002,
+				avgDuration:         1000,
+				maxDuration:         1000,
+				minDuration:         1000,
+				startTime:           time.Unix(1000, 0),
+				endTime:             time.Unix(2000, 0),
+				statusCodes:         map[int]int{200: 2},
+				streams:             2,
+				streamEvents:        3,
+				avgTimeToFirstEvent: 2000,
+				avgEventGap:         200,
+				maxEventGap:         300,
+				minEventGap:         100,
+				avgStreamLifetime:   3000,
+				streamEndReasons:    map[string]int{"server closed": 1, "client closed": 1},
+			},
+		},
+		//GraphQL operations, with errors in a 200 response
+		{requestStats: []RequestStat{
+			{StartTime: time.Unix(1000, 0), EndTime: time.Unix(2000, 0), Duration: 1000, StatusCode: 200, GraphQL: &GraphQLStat{OperationName: "GetUser"}},
+			{StartTime: time.Unix(1000, 0), EndTime: time.Unix(2000, 0), Duration: 3000, StatusCode: 200, GraphQL: &GraphQLStat{OperationName: "GetUser"}},
+			{StartTime: time.Unix(1000, 0), EndTime: time.Unix(2000, 0), Duration: 1000, StatusCode: 200, GraphQL: &GraphQLStat{OperationName: "AddUser", Errors: []string{"a", "b"}},
+				Error: errors.New("GraphQL errors: a; b"), ErrorKind: ErrorGraphQL},
+		},
+			want: RequestStatSummary{
+				avgRPS:      0.000000000002,
+				avgDuration: 2000,
+				maxDuration: 3000,
+				minDuration: 1000,
+				startTime:   time.Unix(1000, 0),
+				endTime:     time.Unix(2000, 0),
+				statusCodes: map[int]int{200: 2},
+				errorKinds:  map[string]int{ErrorGraphQL: 1},
+				graphQLOperations: map[string]graphQLOperationSummary{
+					"GetUser": {requests: 2, avgDuration: 2000},
+					"AddUser": {requests: 1, failures: 1, errors: 2},
+				},
+			},
+		},
+		//compressed responses
+		{requestStats: []RequestStat{
+			{StartTime: time.Unix(1000, 0), EndTime: time.Unix(2000, 0), Duration: 1000, StatusCode: 200, ContentEncoding: EncodingGzip, EncodedBodySize: 100, DecodedBodySize: 400},
+			{StartTime: time.Unix(1000, 0), EndTime: time.Unix(2000, 0), Duration: 1000, StatusCode: 200, ContentEncoding: EncodingBrotli, EncodedBodySize: 50, DecodedBodySize: 300},
+			{StartTime: time.Unix(1000, 0), EndTime: time.Unix(2000, 0), Duration: 1000, StatusCode: 200},
+		},
+			want: RequestStatSummary{
+				avgRPS:           0.000000000003,
+				avgDuration:      1000,
+				maxDuration:      1000,
+				minDuration:      1000,
+				startTime:        time.Unix(1000, 0),
+				endTime:          time.Unix(2000, 0),
+				statusCodes:      map[int]int{200: 3},
+				contentEncodings: map[string]int{EncodingGzip: 1, EncodingBrotli: 1},
+				encodedBodyBytes: 150,
+				decodedBodyBytes: 700,
+			},
+		},
+		//requests that answered auth challenges, counted even when they failed
+		{requestStats: []RequestStat{
+			{StartTime: time.Unix(1000, 0), EndTime: time.Unix(2000, 0), Duration: 1000, StatusCode: 200, AuthRoundTrips: 1, AuthRoundTripTime: 300},
+			{StartTime: time.Unix(1000, 0), EndTime: time.Unix(2000, 0), Duration: 1000, StatusCode: 200},
+			{StartTime: time.Unix(1000, 0), EndTime: time.Unix(2000, 0), Duration: 1000, ErrorKind: ErrorAuth, Error: errors.New("test error"), AuthRoundTrips: 1, AuthRoundTripTime: 100},
+		},
+			want: RequestStatSummary{
+				avgRPS:               0.000000000002,
+				avgDuration:          1000,
+				maxDuration:          1000,
+				minDuration:          1000,
+				startTime:            time.Unix(1000, 0),
+				endTime:              time.Unix(2000, 0),
+				statusCodes:          map[int]int{200: 2},
+				errorKinds:           map[string]int{ErrorAuth: 1},
+				authChallenged:       2,
+				authRoundTrips:       2,
+				avgAuthRoundTripTime: 200,
 			},
 		},
 		//mix of timings, mix of data transferred, mix of status codes
@@ -79,6 +311,7 @@ func TestCreateRequestsStats(t *testing.T) {
 				maxDataTransferred:   600,
 				minDataTransferred:   100,
 				totalDataTransferred: 2100,
+				errorKinds:           map[string]int{ErrorOther: 1},
 			},
 		},
 		//like test case from above, but differently ordered
@@ -103,6 +336,7 @@ func TestCreateRequestsStats(t *testing.T) {
 				maxDataTransferred:   600,
 				minDataTransferred:   100,
 				totalDataTransferred: 2100,
+				errorKinds:           map[string]int{ErrorOther: 1},
 			},
 		},
 	}
@@ -0,0 +1,111 @@
+package pewpew
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+//StreamStat is the saved information about the response stream of a request, for Stream targets.
+//The RequestStat it belongs to covers the request until the response headers arrived.
+type StreamStat struct {
+	//from the start of the request until the first event arrived, zero if none did
+	TimeToFirstEvent time.Duration `json:"timeToFirstEvent"`
+	Events           int           `json:"events"`
+	//time between each event and the one before it
+	EventGaps []time.Duration `json:"eventGaps"`
+	//from the response headers until the stream ended
+	Lifetime time.Duration `json:"lifetime"`
+	//why the stream ended, such as "server closed", "client closed" or the read error
+	EndReason string `json:"endReason"`
+}
+
+//how runRequest treats the response of a Stream target
+type streamOptions struct {
+	//how long to wait for the response headers, zero for no limit
+	timeout time.Duration
+	//how long to stay subscribed before closing the stream, zero for until the server ends it
+	duration time.Duration
+}
+
+//options for reading the target's responses as streams, nil if it isn't a Stream target
+func newStreamOptions(target Target) *streamOptions {
+	if !target.Stream {
+		return nil
+	}
+	return &streamOptions{
+		timeout:  parseOptionalDuration(target.Timeout),
+		duration: parseOptionalDuration(target.StreamDuration),
+	}
+}
+
+//read events off the response body until the stream ends or the stream duration is up.
+//Server-Sent Events are separated by blank lines, any other body has an event per line.
+//Returns the bytes read from the body.
+func readStream(response *http.Response, reqStartTime time.Time, duration time.Duration, cancel context.CancelFunc) (*StreamStat, int) {
+	streamStartTime := time.Now()
+	var clientClosed int32
+	if duration > 0 {
+		timer := time.AfterFunc(duration, func() {
+			atomic.StoreInt32(&clientClosed, 1)
+			cancel()
+		})
+		defer timer.Stop()
+	}
+	mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	isEventStream := mediaType == "text/event-stream"
+
+	streamStat := &StreamStat{}
+	var lastEventTime time.Time
+	event := func() {
+		now := time.Now()
+		if streamStat.Events == 0 {
+			streamStat.TimeToFirstEvent = now.Sub(reqStartTime)
+		} else {
+			streamStat.EventGaps = append(streamStat.EventGaps, now.Sub(lastEventTime))
+		}
+		streamStat.Events++
+		lastEventTime = now
+	}
+
+	reader := bufio.NewReader(response.Body)
+	bytesRead := 0
+	//whether the Server-Sent Event being read has any data, comments alone aren't dispatched
+	hasData := false
+	for {
+		line, err := reader.ReadString('\n')
+		bytesRead += len(line)
+		trimmed := strings.TrimRight(line, "\r\n")
+		if isEventStream {
+			if err == nil && trimmed == "" {
+				if hasData {
+					event()
+				}
+				hasData = false
+			} else if trimmed == "data" || strings.HasPrefix(trimmed, "data:") {
+				hasData = true
+			}
+		} else if trimmed != "" && (err == nil || err == io.EOF) {
+			//a long poll's response may not end in a newline
+			event()
+		}
+		if err != nil {
+			if err == io.EOF {
+				streamStat.EndReason = "server closed"
+			} else if atomic.LoadInt32(&clientClosed) == 1 {
+				streamStat.EndReason = "client closed"
+			} else {
+				streamStat.EndReason = err.Error()
+			}
+			break
+		}
+	}
+	response.Body.Close()
+	streamStat.Lifetime = time.Since(streamStartTime)
+	return streamStat, bytesRead
+}
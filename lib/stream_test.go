@@ -0,0 +1,109 @@
+package pewpew
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRunStressStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher := w.(http.Flusher)
+		switch r.URL.Path {
+		case "/sse":
+			//three events spread over longer than the timeout, with a heartbeat comment
+			w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+			for i := 0; i < 3; i++ {
+				time.Sleep(50 * time.Millisecond)
+				fmt.Fprintf(w, "event: update\ndata: {\"n\": %d}\n\n", i)
+				flusher.Flush()
+			}
+		case "/forever":
+			w.Header().Set("Content-Type", "text/event-stream")
+			for {
+				fmt.Fprint(w, "data: tick\n\n")
+				flusher.Flush()
+				select {
+				case <-r.Context().Done():
+					return
+				case <-time.After(10 * time.Millisecond):
+				}
+			}
+		case "/poll":
+			time.Sleep(20 * time.Millisecond)
+			fmt.Fprint(w, `{"update": true}`)
+		case "/ndjson":
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			fmt.Fprint(w, "{\"n\": 1}\n")
+			flusher.Flush()
+			fmt.Fprint(w, "{\"n\": 2}\n")
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		path           string
+		streamDuration string
+		events         int
+		endReason      string
+		errorKind      string
+	}{
+		{"/sse", "", 3, "server closed", ""},
+		{"/forever", "100ms", -1, "client closed", ""},
+		{"/poll", "", 1, "server closed", ""},
+		{"/ndjson", "", 2, "server closed", ""},
+		{"/slow", "", 0, "", ErrorTimeout},
+	}
+	for _, c := range cases {
+		target := Target{
+			URL:            server.URL + c.path,
+			Method:         DefaultMethod,
+			Count:          4,
+			Concurrency:    4,
+			Timeout:        "100ms",
+			Stream:         true,
+			StreamDuration: c.streamDuration,
+		}
+		targetStats, err := RunStress(StressConfig{Targets: []Target{target}, Quiet: true}, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("RunStress(%+v) err: %s", target, err)
+		}
+		for _, stat := range targetStats[0] {
+			if c.errorKind != "" {
+				if stat.ErrorKind != c.errorKind {
+					t.Errorf("stream of %s error kind == %q wanted %q", c.path, stat.ErrorKind, c.errorKind)
+				}
+				continue
+			}
+			if stat.Error != nil {
+				t.Fatalf("stream of %s failed: %s", c.path, stat.Error)
+			}
+			if stat.Stream == nil {
+				t.Fatalf("stream of %s has no stats", c.path)
+			}
+			if c.events >= 0 && stat.Stream.Events != c.events {
+				t.Errorf("stream of %s events == %d wanted %d", c.path, stat.Stream.Events, c.events)
+			}
+			if stat.Stream.Events < 1 {
+				t.Errorf("stream of %s has no events", c.path)
+				continue
+			}
+			if len(stat.Stream.EventGaps) != stat.Stream.Events-1 {
+				t.Errorf("stream of %s has %d event gaps for %d events", c.path, len(stat.Stream.EventGaps), stat.Stream.Events)
+			}
+			if stat.Stream.TimeToFirstEvent < stat.Duration {
+				t.Errorf("stream of %s first event came %s before the headers at %s", c.path, stat.Stream.TimeToFirstEvent, stat.Duration)
+			}
+			if stat.Stream.EndReason != c.endReason {
+				t.Errorf("stream of %s end reason == %q wanted %q", c.path, stat.Stream.EndReason, c.endReason)
+			}
+		}
+	}
+}
@@ -1,20 +1,24 @@
 package pewpew
 
 import (
-	"bytes"
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
+	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	websocket "github.com/gorilla/websocket"
 	reggen "github.com/lucasjones/reggen"
 	http2 "golang.org/x/net/http2"
+	grpc "google.golang.org/grpc"
 )
 
 //so concurrent workers don't interlace messages
@@ -22,6 +26,12 @@ var writeLock sync.Mutex
 
 type workerDone struct{}
 
+//the stats of a finished target, which is the idx'th of the config
+type targetDone struct {
+	idx   int
+	stats []RequestStat
+}
+
 //RequestStat is the saved information about an individual completed HTTP request
 type RequestStat struct {
 	Proto     string
@@ -35,6 +45,35 @@ type RequestStat struct {
 	StatusCode      int   `json:"statusCode"`
 	Error           error `json:"error"`
 	DataTransferred int   //bytes
+	//ID of the connection the request was sent over, unique within a run. Zero if no connection was made.
+	ConnID int `json:"connID"`
+	//number of requests in flight on the connection when this one was sent, including itself
+	ConnStreams int `json:"connStreams"`
+	//time spent on the TLS or QUIC handshake, if this request opened a new connection
+	HandshakeTime time.Duration `json:"handshakeTime"`
+	//whether the request was sent as QUIC 0-RTT data on a resumed session
+	Used0RTT bool `json:"used0RTT"`
+	//what kind of failure Error is, such as which timeout fired. One of the Error* constants.
+	ErrorKind string `json:"errorKind"`
+	//local IP address the request was sent from
+	SourceIP string `json:"sourceIP"`
+	//Content-Encoding of the response body, if it was compressed with one that could be decoded
+	ContentEncoding string `json:"contentEncoding"`
+	//size of the response body as it was sent, and once decoded, when it had a ContentEncoding
+	EncodedBodySize int `json:"encodedBodySize"`
+	DecodedBodySize int `json:"decodedBodySize"`
+	//extra round trips made to answer auth challenges before this request, which Duration doesn't include.
+	//Their data is counted in DataTransferred.
+	AuthRoundTrips    int           `json:"authRoundTrips"`
+	AuthRoundTripTime time.Duration `json:"authRoundTripTime"`
+	//the session that followed, for WebSocket targets
+	WebSocket *WebSocketStat `json:"webSocket,omitempty"`
+	//the call made, for gRPC targets, whose status code takes the place of StatusCode
+	GRPC *GRPCStat `json:"grpc,omitempty"`
+	//the events of the response, for Stream targets
+	Stream *StreamStat `json:"stream,omitempty"`
+	//the operation and its errors, for GraphQL targets
+	GraphQL *GraphQLStat `json:"graphQL,omitempty"`
 }
 
 type (
@@ -44,7 +83,11 @@ type (
 		Verbose    bool
 		Quiet      bool
 		NoHTTP2    bool
+		H2C        bool //HTTP2 over plain TCP with prior knowledge, no upgrade from HTTP/1.1
 		EnforceSSL bool
+		//values redacted from what RunStress and PrintPlan write, like the secrets and files interpolated into config files.
+		//Values shorter than 4 characters aren't.
+		Secrets []string
 
 		//global target settings
 
@@ -54,13 +97,70 @@ type (
 		Method          string
 		Body            string
 		BodyFilename    string
+		Form            []string
+		Multipart       []string
+		StreamBodyFile  bool
+		BodySize        string
+		BodyRandom      bool
+		BodyEncoding    string
 		Headers         string
+		HeaderMap       map[string]string
+		HeaderList      []string
 		Cookies         string
+		CookieList      []string
 		UserAgent       string
 		BasicAuth       string
+		BasicAuthUser   string
+		BasicAuthPass   string
 		Compress        bool
 		KeepAlive       bool
 		FollowRedirects bool
+		HTTP3           bool
+
+		BearerToken         string
+		DigestAuthUser      string
+		DigestAuthPass      string
+		OAuth2TokenURL      string
+		OAuth2ClientID      string
+		OAuth2ClientSecret  string
+		OAuth2Scopes        []string
+		OAuth2Username      string
+		OAuth2Password      string
+		HMACKey             string
+		HMACHeader          string
+		HMACTimestampHeader string
+		AWSAccessKeyID      string
+		AWSSecretAccessKey  string
+		AWSSessionToken     string
+		AWSRegion           string
+		AWSService          string
+
+		MaxConnsPerHost     int
+		MaxIdleConnsPerHost int
+		IdleTimeout         string
+		Connections         int
+
+		DialTimeout           string
+		TLSHandshakeTimeout   string
+		ResponseHeaderTimeout string
+		SourceIPs             string
+		Resolve               []string
+
+		WSMessages     []string
+		WSMessageCount int
+		WSMessageRate  float64
+		WSIDField      string
+
+		GRPCMethod        string
+		GRPCMessages      []string
+		GRPCDescriptorSet string
+
+		Stream         bool
+		StreamDuration string
+
+		Query         string
+		Variables     string
+		OperationName string
 	}
 	//Target is location to send the HTTP request.
 	Target struct {
@@ -73,19 +173,144 @@ type (
 		//How many requests can be happening simultaneously for this Target
 		Concurrency int
 		Timeout     string
+		//How long after the test starts to send the target's first request, like "1.5s".
+		//Empty string means right away.
+		StartDelay string
+		//Requests of an access log, each sent once in place of a request to URL, which only gives their scheme and host.
+		//Count must be how many there are, and Concurrency bounds how many are sent at a time. See TargetFromAccessLog.
+		ReplayRequests []ReplayedRequest
 		//A valid HTTP method: GET, HEAD, POST, etc.
 		Method string
 		//String that is the content of the HTTP body. Empty string is no body.
 		Body string
 		//A location on disk to read the HTTP body from. Empty string means it will not be read.
-		BodyFilename    string
-		Headers         string
-		Cookies         string
-		UserAgent       string
-		BasicAuth       string
+		BodyFilename string
+		//Fields sent as an application/x-www-form-urlencoded body, like "name=value", in order
+		Form []string
+		//Fields sent as a multipart/form-data body, like curl's -F option:
+		//"name=value" for a field, or "name=@path;type=image/png" for a file part read from disk
+		Multipart []string
+		//Read BodyFilename from disk as each request is sent, instead of holding it in memory
+		StreamBodyFile bool
+		//Size of a generated body, like "1024", "512KB" or "50MB". Empty string means none.
+		BodySize string
+		//Fill the generated body with random bytes instead of zeros
+		BodyRandom bool
+		//Compress the body once with this content encoding, one of gzip, deflate, br or zstd,
+		//and set the Content-Encoding header. Empty string means send it as is.
+		BodyEncoding string
+		//Headers like "Accept: text/html, Content-Type: application/json",
+		//which can't hold values with commas or colons. Use HeaderMap or HeaderList for those.
+		Headers string
+		//Headers by name, whose values are sent as is
+		HeaderMap map[string]string
+		//Headers like "Accept: text/html, application/json", split at the first colon only.
+		//A name can be repeated to send the header more than once.
+		HeaderList []string
+		//Cookies like "data=123; session=456"
+		Cookies string
+		//Cookies like "session=456", split at the first equals sign only. A name can be repeated.
+		CookieList []string
+		UserAgent  string
+		//Basic auth like "user:password", which can't hold a comma.
+		//Use BasicAuthUser and BasicAuthPass for those.
+		BasicAuth     string
+		BasicAuthUser string
+		BasicAuthPass string
+		//Static token sent as "Authorization: Bearer <token>"
+		BearerToken string
+		//Digest auth credentials, used to answer the server's challenge. Each worker
+		//reuses the nonce of its last challenge, until the server sends a new one.
+		DigestAuthUser string
+		DigestAuthPass string
+		//Token endpoint to fetch an OAuth2 access token from before the test, with the client
+		//credentials grant, or the password grant when OAuth2Username is set. The token is sent
+		//as a bearer token, and replaced with a new one when it expires.
+		OAuth2TokenURL     string
+		OAuth2ClientID     string
+		OAuth2ClientSecret string
+		OAuth2Scopes       []string
+		OAuth2Username     string
+		OAuth2Password     string
+		//Sign each request with an HMAC-SHA256 of it, using this key. See HMACSigner.
+		HMACKey string
+		//Header of the signature, DefaultHMACHeader if empty
+		HMACHeader string
+		//Header of the signature's timestamp, DefaultHMACTimestampHeader if empty
+		HMACTimestampHeader string
+		//Sign each request with AWS Signature Version 4, for the AWS region and service, like "s3"
+		AWSAccessKeyID     string
+		AWSSecretAccessKey string
+		AWSSessionToken    string
+		AWSRegion          string
+		AWSService         string
+		//Signs each request just before it is sent, instead of the HMAC or AWS settings.
+		//Only for use as a library.
+		Signer RequestSigner
+		//Ask for gzip compressed responses with an Accept-Encoding header, unless one is already set.
+		//Responses compressed with any supported encoding are decoded either way.
 		Compress        bool
 		KeepAlive       bool
 		FollowRedirects bool
+		//Send requests over HTTP/3 (QUIC) instead of HTTP/1.1 or HTTP/2
+		HTTP3 bool
+		//Most connections the transport may have open to one host. Zero means no limit.
+		MaxConnsPerHost int
+		//Most idle keep-alive connections kept open to one host. Zero means the net/http default of 2.
+		MaxIdleConnsPerHost int
+		//How long an idle keep-alive connection stays open. Empty string means no limit.
+		IdleTimeout string
+		//Spread the requests over exactly this many keep-alive connections,
+		//each shared by a share of the workers. Zero means let the transport decide.
+		Connections int
+		//Limits on each phase of a request, on top of the total Timeout.
+		//Empty string means no limit. Dialing has no limit with HTTP3, as UDP has no connection phase.
+		DialTimeout           string
+		TLSHandshakeTimeout   string
+		ResponseHeaderTimeout string
+		//Comma separated local IP addresses to send from, each new connection using the next one.
+		//Empty string means let the system choose.
+		SourceIPs string
+		//Addresses to connect to instead of looking up the host, like curl's --resolve:
+		//"example.com:443:127.0.0.1" sends requests to port 443 of example.com to 127.0.0.1.
+		//The request's Host header and TLS server name are still example.com.
+		Resolve []string
+
+		//Settings for ws:// and wss:// URLs, where each request is the WebSocket session of a virtual user.
+		//Messages to send in turn, as text/template strings with {{.ID}} and {{.Seq}} available.
+		WSMessages []string
+		//How many messages each virtual user sends. Zero means each of WSMessages once.
+		WSMessageCount int
+		//Messages per second each virtual user sends. Zero means as fast as possible.
+		WSMessageRate float64
+		//JSON field of the replies that holds the ID of the message answered. Empty string means "id".
+		WSIDField string
+
+		//Settings for grpc:// and grpcs:// URLs, where each request is a call of a gRPC method.
+		//Method to call, like "package.Service/Method"
+		GRPCMethod string
+		//Request messages as JSON. Methods that stream requests send all of them in order,
+		//the others take a single message. Empty means one empty message.
+		GRPCMessages []string
+		//Path to a FileDescriptorSet describing the method, as written by protoc --include_imports --descriptor_set_out.
+		//Empty string means ask the server's reflection service.
+		GRPCDescriptorSet string
+
+		//Keep the response open and record its events, for Server-Sent Events and long polling.
+		//Timeout then only covers waiting for the response headers.
+		Stream bool
+		//How long each request stays subscribed before closing the stream.
+		//Empty string means until the server ends it.
+		StreamDuration string
+
+		//Settings for GraphQL targets, which POST Query as JSON instead of Body.
+		//A 200 response with errors in its body counts as a failed request.
+		Query string
+		//Variables of the query as a JSON object, a text/template string with {{.Seq}} available
+		Variables string
+		//Operation of Query to run, and what its requests are grouped by in the summary.
+		//Empty string means the only operation, grouped by its name.
+		OperationName string
 	}
 )
 
@@ -129,21 +354,82 @@ func RunStress(s StressConfig, w io.Writer) ([][]RequestStat, error) {
 		return nil, errors.New("invalid configuration: " + err.Error())
 	}
 	targetCount := len(s.Targets)
+	redactor := newRedactor(s)
 
 	//setup the queue of requests, one queue per target
 	requestQueues := make([](chan http.Request), targetCount)
 	for idx, target := range s.Targets {
 		requestQueues[idx] = make(chan http.Request, target.Count)
+		//shared by all of the target's requests
+		body, err := loadBody(target)
+		if err != nil {
+			return nil, errors.New("failed to create request with target configuration: " + err.Error())
+		}
+		variables, err := parseGraphQLVariables(target.Variables)
+		if err != nil {
+			return nil, errors.New("failed to create request with target configuration: " + err.Error())
+		}
 		for i := 0; i < target.Count; i++ {
-			req, err := buildRequest(target)
+			reqTarget := target
+			if len(target.ReplayRequests) > 0 {
+				reqTarget = replayedTarget(target, target.ReplayRequests[i])
+			}
+			req, err := buildRequest(reqTarget, body)
+			if err == nil && target.Query != "" {
+				err = setGraphQLBody(&req, target, variables, i)
+			}
 			if err != nil {
 				return nil, errors.New("failed to create request with target configuration: " + err.Error())
 			}
+			if len(target.ReplayRequests) > 0 && target.ReplayRequests[i].Offset > 0 {
+				req = *req.WithContext(context.WithValue(req.Context(), replayOffsetKey{}, target.ReplayRequests[i].Offset))
+			}
 			requestQueues[idx] <- req
 		}
 		close(requestQueues[idx])
 	}
 
+	//resolve the gRPC methods up front, so a bad method or message fails the whole test
+	grpcCalls := make([]*grpcCall, targetCount)
+	for idx, target := range s.Targets {
+		if !isGRPCURL(target.URL) {
+			continue
+		}
+		grpcCalls[idx], err = newGRPCCall(target, s)
+		if err != nil {
+			return nil, errors.New("failed to resolve gRPC method " + target.GRPCMethod + ": " + err.Error())
+		}
+	}
+
+	//fetch OAuth2 tokens up front, so bad credentials fail the whole test
+	oauth2Sources := make([]*oauth2Tokens, targetCount)
+	for idx, target := range s.Targets {
+		oauth2Sources[idx], err = newOAuth2Tokens(target, s)
+		if err != nil {
+			return nil, errors.New("failed to authenticate with " + target.OAuth2TokenURL + ": " + err.Error())
+		}
+	}
+
+	//set up the gRPC connections up front as well, so one that can't be made fails the whole test.
+	//A target's clients and connections share its source IPs, taking turns on them.
+	targetSources := make([]*sourceIPs, targetCount)
+	grpcConns := make([][]*grpc.ClientConn, targetCount)
+	for idx, target := range s.Targets {
+		targetSources[idx] = newSourceIPs(target.SourceIPs, target.Resolve)
+		if grpcCalls[idx] == nil {
+			continue
+		}
+		grpcConns[idx] = make([]*grpc.ClientConn, targetClientCount(target))
+		for i := range grpcConns[idx] {
+			grpcConns[idx][i], err = createGRPCConn(target, s, targetSources[idx])
+			if err != nil {
+				closeGRPCConns(grpcConns)
+				return nil, errors.New("failed to set up gRPC connection to " + target.URL + ": " + err.Error())
+			}
+		}
+	}
+	defer closeGRPCConns(grpcConns)
+
 	if targetCount == 1 {
 		fmt.Fprintf(w, "Stress testing %d target:\n", targetCount)
 	} else {
@@ -151,41 +437,46 @@ func RunStress(s StressConfig, w io.Writer) ([][]RequestStat, error) {
 	}
 
 	//when a target is finished, send all stats into this
-	targetStats := make(chan []RequestStat)
+	targetStats := make(chan targetDone)
 	for idx, target := range s.Targets {
-		go func(target Target, requestQueue chan http.Request, targetStats chan []RequestStat) {
+		go func(idx int, target Target, requestQueue chan http.Request, grpcCall *grpcCall, grpcConns []*grpc.ClientConn, sources *sourceIPs,
+			tokens *oauth2Tokens, targetStats chan targetDone) {
+			signer := newRequestSigner(target)
+			if delay := parseOptionalDuration(target.StartDelay); delay > 0 {
+				time.Sleep(delay)
+			}
 			writeLock.Lock()
-			fmt.Fprintf(w, "- Running %d tests at %s, %d at a time\n", target.Count, target.URL, target.Concurrency)
+			fmt.Fprintf(w, "- Running %d tests at %s, %d at a time\n", target.Count, redactor.Replace(target.URL), target.Concurrency)
 			writeLock.Unlock()
+			//replayed requests are scheduled from here
+			started := time.Now()
 
 			workerDoneChan := make(chan workerDone)   //workers use this to indicate they are done
 			requestStatChan := make(chan RequestStat) //workers communicate each requests' info
 
-			tr := &http.Transport{}
-			tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: !s.EnforceSSL}
-			tr.DisableCompression = !target.Compress
-			tr.DisableKeepAlives = !target.KeepAlive
-			if s.NoHTTP2 {
-				tr.TLSNextProto = make(map[string](func(string, *tls.Conn) http.RoundTripper))
-			} else {
-				http2.ConfigureTransport(tr)
-			}
-			var timeout time.Duration
-			if target.Timeout != "" {
-				timeout, _ = time.ParseDuration(target.Timeout)
-			} else {
-				timeout = time.Duration(0)
+			conns := newConnTracker()
+			clientCount := targetClientCount(target)
+			clients := make([]*http.Client, clientCount)
+			var wsDialer *websocket.Dialer
+			if grpcConns == nil {
+				grpcConns = make([]*grpc.ClientConn, clientCount)
 			}
-			client := &http.Client{Timeout: timeout, Transport: tr}
-			if !target.FollowRedirects {
-				client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
-					return http.ErrUseLastResponse
+			if isWebSocketURL(target.URL) {
+				wsDialer = createWebSocketDialer(target, s, sources)
+			} else if grpcCall == nil {
+				for i := range clients {
+					clients[i] = createClient(target, s, sources)
 				}
 			}
+			//not a transport setting, so that it applies to every protocol the same way
+			responseHeaderTimeout := parseOptionalDuration(target.ResponseHeaderTimeout)
+			streamOpts := newStreamOptions(target)
 
 			//start up the workers
 			for i := 0; i < target.Concurrency; i++ {
-				go func() {
+				go func(client *http.Client, grpcConn *grpc.ClientConn) {
+					//Digest nonces are counted per worker, so workers don't race to use them in order
+					digest := newDigestAuth(target)
 					for {
 						select {
 						case req, ok := <-requestQueue:
@@ -194,13 +485,51 @@ func RunStress(s StressConfig, w io.Writer) ([][]RequestStat, error) {
 								workerDoneChan <- workerDone{}
 								return
 							}
+							//wait for a replayed request's time, which may have passed while the workers were busy
+							if offset, ok := req.Context().Value(replayOffsetKey{}).(time.Duration); ok {
+								time.Sleep(time.Until(started.Add(offset)))
+							}
 
-							response, stat := runRequest(req, client)
+							var response *http.Response
+							var stat RequestStat
+							var authErr error
+							authErrorKind := ErrorAuth
+							if tokens != nil {
+								authErr = tokens.authorize(&req)
+							}
+							//signatures cover the final request, and hold the time it was sent
+							if authErr == nil && signer != nil {
+								authErr = signer.Sign(&req)
+								authErrorKind = ErrorSigning
+							}
+							if authErr != nil {
+								stat = unsentRequestStat(req, authErr, authErrorKind)
+							} else if wsDialer != nil {
+								stat = runWebSocket(req, wsDialer, target, conns)
+							} else if grpcConn != nil {
+								stat = runGRPC(req, grpcConn, grpcCall, target)
+							} else {
+								send := func(req http.Request) (*http.Response, RequestStat) {
+									return runRequest(req, client, conns, responseHeaderTimeout, streamOpts)
+								}
+								if digest != nil {
+									response, stat = digest.do(req, send)
+								} else {
+									response, stat = send(req)
+								}
+								if target.Query != "" {
+									stat = checkGraphQLResponse(response, target, stat)
+								}
+							}
+							if target.HTTP3 && !target.KeepAlive {
+								//QUIC has no keep-alive switch on the transport, so close between requests
+								client.CloseIdleConnections()
+							}
 							if !s.Quiet {
 								writeLock.Lock()
-								printStat(stat, w)
+								printStat(stat, redactor, w)
 								if s.Verbose {
-									printVerbose(&req, response, w)
+									printVerbose(&req, response, redactor, w)
 								}
 								writeLock.Unlock()
 							}
@@ -208,7 +537,7 @@ func RunStress(s StressConfig, w io.Writer) ([][]RequestStat, error) {
 							requestStatChan <- stat
 						}
 					}
-				}()
+				}(clients[i%clientCount], grpcConns[i%clientCount])
 			}
 			requestStats := make([]RequestStat, target.Count)
 			requestsCompleteCount := 0
@@ -227,15 +556,16 @@ func RunStress(s StressConfig, w io.Writer) ([][]RequestStat, error) {
 					break
 				}
 			}
-			targetStats <- requestStats
-		}(target, requestQueues[idx], targetStats)
+			targetStats <- targetDone{idx, requestStats}
+		}(idx, target, requestQueues[idx], grpcCalls[idx], grpcConns[idx], targetSources[idx], oauth2Sources[idx], targetStats)
 	}
 	targetRequestStats := make([][]RequestStat, targetCount)
 	targetDoneCount := 0
 	for {
 		select {
-		case reqStats := <-targetStats:
-			targetRequestStats[targetDoneCount] = reqStats
+		case done := <-targetStats:
+			//targets finish in any order, so their stats are kept in the order of the config
+			targetRequestStats[done.idx] = done.stats
 			targetDoneCount++
 		}
 		if targetDoneCount == targetCount {
@@ -248,52 +578,406 @@ func RunStress(s StressConfig, w io.Writer) ([][]RequestStat, error) {
 }
 
 func validateTargets(s StressConfig) error {
+	for _, problem := range configProblems(s) {
+		if problem.Target == 0 {
+			return errors.New(problem.Message)
+		}
+		return errors.New("target " + strconv.Itoa(problem.Target) + ": " + problem.Message)
+	}
+	return nil
+}
+
+//every problem with the settings of s, in the order of its targets
+func configProblems(s StressConfig) []ConfigProblem {
+	var problems []ConfigProblem
 	if len(s.Targets) == 0 {
-		return errors.New("zero targets")
+		problems = append(problems, ConfigProblem{Setting: "Targets", Message: "zero targets"})
+	}
+	if s.H2C && s.NoHTTP2 {
+		problems = append(problems, ConfigProblem{Setting: "H2C", Message: "h2c requires HTTP2"})
 	}
-	for _, target := range s.Targets {
-		//checks
-		if target.URL == "" {
-			return errors.New("empty URL")
+	for i, target := range s.Targets {
+		for _, problem := range targetProblems(target, s) {
+			problem.Target = i + 1
+			problems = append(problems, problem)
 		}
-		if target.Count <= 0 {
-			return errors.New("request count must be greater than zero")
+	}
+	return problems
+}
+
+//every problem with the settings of target, each naming the setting at fault
+func targetProblems(target Target, s StressConfig) []ConfigProblem {
+	var problems []ConfigProblem
+	problem := func(setting, message string) {
+		problems = append(problems, ConfigProblem{Setting: setting, Message: message})
+	}
+	if target.URL == "" {
+		problem("URL", "empty URL")
+	} else if target.RegexURL {
+		if _, err := reggen.NewGenerator(target.URL); err != nil {
+			problem("URL", "failed to parse regex: "+err.Error())
 		}
-		if target.Concurrency <= 0 {
-			return errors.New("concurrency must be greater than zero")
+	}
+	if target.Count <= 0 {
+		problem("Count", "request count must be greater than zero")
+	}
+	if target.Concurrency <= 0 {
+		problem("Concurrency", "concurrency must be greater than zero")
+	}
+	if target.Method == "" {
+		problem("Method", "method cannot be empty string")
+	}
+	if target.Timeout != "" {
+		//TODO should save this parsed duration so don't have to inefficiently reparse later
+		timeout, err := time.ParseDuration(target.Timeout)
+		if err != nil {
+			problem("Timeout", "failed to parse timeout: "+target.Timeout)
+		} else if timeout <= time.Millisecond {
+			problem("Timeout", "timeout must be greater than one millisecond")
 		}
-		if target.Method == "" {
-			return errors.New("method cannot be empty string")
+	}
+	if target.Concurrency > target.Count {
+		problem("Concurrency", "concurrency must be higher than request count")
+	}
+	if target.HTTP3 && strings.HasPrefix(target.URL, "http://") {
+		problem("HTTP3", "HTTP3 requires https")
+	}
+	if target.HTTP3 && s.H2C {
+		problem("HTTP3", "HTTP3 and h2c cannot both be used")
+	}
+	if s.H2C && (strings.HasPrefix(target.URL, "https://") || strings.HasPrefix(target.URL, "wss://")) {
+		problem("URL", "h2c is HTTP2 over plain TCP, so can't be used with https")
+	}
+	if target.MaxConnsPerHost < 0 || target.MaxIdleConnsPerHost < 0 || target.Connections < 0 {
+		problem("MaxConnsPerHost", "connection limits cannot be negative")
+	}
+	if (target.MaxConnsPerHost > 0 || target.MaxIdleConnsPerHost > 0) && (target.HTTP3 || s.H2C) {
+		problem("MaxConnsPerHost", "per host connection limits are not supported with HTTP3 or h2c")
+	}
+	phaseTimeouts := []struct{ setting, name, value string }{
+		{"IdleTimeout", "idle timeout", target.IdleTimeout},
+		{"DialTimeout", "dial timeout", target.DialTimeout},
+		{"TLSHandshakeTimeout", "TLS handshake timeout", target.TLSHandshakeTimeout},
+		{"ResponseHeaderTimeout", "response header timeout", target.ResponseHeaderTimeout},
+		{"StreamDuration", "stream duration", target.StreamDuration},
+	}
+	for _, phaseTimeout := range phaseTimeouts {
+		if phaseTimeout.value == "" {
+			continue
 		}
-		if target.Timeout != "" {
-			//TODO should save this parsed duration so don't have to inefficiently reparse later
-			timeout, err := time.ParseDuration(target.Timeout)
-			if err != nil {
-				return errors.New("failed to parse timeout: " + target.Timeout)
+		timeout, err := time.ParseDuration(phaseTimeout.value)
+		if err != nil {
+			problem(phaseTimeout.setting, "failed to parse "+phaseTimeout.name+": "+phaseTimeout.value)
+		} else if timeout <= 0 {
+			problem(phaseTimeout.setting, phaseTimeout.name+" must be greater than zero")
+		}
+	}
+	if len(target.ReplayRequests) > 0 {
+		if target.Count != len(target.ReplayRequests) {
+			problem("Count", "request count must be the number of replayed requests, "+strconv.Itoa(len(target.ReplayRequests)))
+		}
+		if target.RegexURL || isWebSocketURL(target.URL) || isGRPCURL(target.URL) {
+			problem("ReplayRequests", "replayed requests can only be sent to an http or https URL that isn't a regex")
+		}
+		for _, r := range target.ReplayRequests {
+			if r.Method == "" || !strings.HasPrefix(r.URI, "/") {
+				problem("ReplayRequests", "replayed requests need a method and a URI starting with /: "+r.Method+" "+r.URI)
+				break
 			}
-			if timeout <= time.Millisecond {
-				return errors.New("timeout must be greater than one millisecond")
+		}
+	}
+	if target.StartDelay != "" {
+		delay, err := time.ParseDuration(target.StartDelay)
+		if err != nil {
+			problem("StartDelay", "failed to parse start delay: "+target.StartDelay)
+		} else if delay < 0 {
+			problem("StartDelay", "start delay cannot be negative")
+		}
+	}
+	if _, err := parseSourceIPs(target.SourceIPs); err != nil {
+		problem("SourceIPs", err.Error())
+	}
+	if _, err := parseResolve(target.Resolve); err != nil {
+		problem("Resolve", err.Error())
+	}
+	if isWebSocketURL(target.URL) {
+		if target.HTTP3 {
+			problem("HTTP3", "HTTP3 is not supported for WebSocket targets")
+		}
+		if target.WSMessageCount < 0 || target.WSMessageRate < 0 {
+			problem("WSMessageCount", "WebSocket message count and rate cannot be negative")
+		}
+		if _, err := parseWSMessages(target.WSMessages); err != nil {
+			problem("WSMessages", err.Error())
+		}
+	}
+	if target.Stream && (isWebSocketURL(target.URL) || isGRPCURL(target.URL)) {
+		problem("Stream", "stream mode is only for HTTP targets")
+	}
+	bodies := 0
+	for _, set := range []bool{target.Body != "" || target.BodyFilename != "", len(target.Form) > 0, len(target.Multipart) > 0, target.BodySize != ""} {
+		if set {
+			bodies++
+		}
+	}
+	if bodies > 1 {
+		problem("Body", "only one of body, form, multipart and body size can be used")
+	}
+	if target.BodyFilename != "" {
+		//checked up front, rather than by the first request when the body file is streamed
+		if info, err := os.Stat(target.BodyFilename); err != nil {
+			problem("BodyFilename", "failed to read body file: "+err.Error())
+		} else if info.IsDir() {
+			problem("BodyFilename", "body file is a directory: "+target.BodyFilename)
+		}
+	}
+	if target.StreamBodyFile && target.BodyFilename == "" {
+		problem("StreamBodyFile", "streaming the body file requires a body file")
+	}
+	if target.BodyRandom && target.BodySize == "" {
+		problem("BodyRandom", "random body requires a body size")
+	}
+	if target.BodyEncoding != "" {
+		if !isSupportedEncoding(target.BodyEncoding) {
+			problem("BodyEncoding", "unsupported body encoding: "+target.BodyEncoding)
+		}
+		if bodies == 0 {
+			problem("BodyEncoding", "body encoding requires a body")
+		}
+		if target.StreamBodyFile {
+			problem("BodyEncoding", "body encoding cannot be used with a streamed body file, as it is compressed once in memory")
+		}
+	}
+	if target.BodySize != "" {
+		if _, err := parseByteSize(target.BodySize); err != nil {
+			problem("BodySize", err.Error())
+		}
+	}
+	if _, err := parseFormFields(target.Form); err != nil {
+		problem("Form", err.Error())
+	}
+	if target.Headers != "" {
+		if _, err := parseKeyValString(target.Headers, ",", ":"); err != nil {
+			problem("Headers", "could not parse headers: "+err.Error())
+		}
+	}
+	if _, err := parseHeaderList(target.HeaderList); err != nil {
+		problem("HeaderList", err.Error())
+	}
+	if target.Cookies != "" {
+		if _, err := parseKeyValString(target.Cookies, ";", "="); err != nil {
+			problem("Cookies", "could not parse cookies: "+err.Error())
+		}
+	}
+	if _, err := parseCookieList(target.CookieList); err != nil {
+		problem("CookieList", err.Error())
+	}
+	if target.BasicAuth != "" && target.BasicAuthUser != "" {
+		problem("BasicAuth", "only one of basic auth and basic auth user can be used")
+	}
+	if target.BasicAuthPass != "" && target.BasicAuthUser == "" {
+		problem("BasicAuthPass", "basic auth password requires a basic auth user")
+	}
+	auths := 0
+	for _, set := range []bool{target.BasicAuth != "" || target.BasicAuthUser != "", target.BearerToken != "", target.DigestAuthUser != "", target.OAuth2TokenURL != ""} {
+		if set {
+			auths++
+		}
+	}
+	if auths > 1 {
+		problem("BearerToken", "only one of basic auth, bearer token, Digest auth and OAuth2 can be used")
+	}
+	if target.DigestAuthPass != "" && target.DigestAuthUser == "" {
+		problem("DigestAuthPass", "Digest auth password requires a Digest auth user")
+	}
+	if target.DigestAuthUser != "" && (isWebSocketURL(target.URL) || isGRPCURL(target.URL)) {
+		problem("DigestAuthUser", "Digest auth is only for HTTP targets")
+	}
+	if target.OAuth2TokenURL != "" {
+		tokenURL, err := url.Parse(target.OAuth2TokenURL)
+		if err != nil || (tokenURL.Scheme != "http" && tokenURL.Scheme != "https") || tokenURL.Host == "" {
+			problem("OAuth2TokenURL", "OAuth2 token URL must be an http or https URL: "+target.OAuth2TokenURL)
+		}
+		if target.OAuth2ClientID == "" {
+			problem("OAuth2ClientID", "OAuth2 requires a client ID")
+		}
+	} else if target.OAuth2ClientID != "" || target.OAuth2ClientSecret != "" || len(target.OAuth2Scopes) > 0 || target.OAuth2Username != "" {
+		problem("OAuth2TokenURL", "OAuth2 settings require an OAuth2 token URL")
+	}
+	if target.OAuth2Password != "" && target.OAuth2Username == "" {
+		problem("OAuth2Password", "OAuth2 password requires an OAuth2 username")
+	}
+	signers := 0
+	for _, set := range []bool{target.Signer != nil, target.HMACKey != "", target.AWSAccessKeyID != ""} {
+		if set {
+			signers++
+		}
+	}
+	if signers > 1 {
+		problem("HMACKey", "only one of HMAC signing, AWS signing and a signer can be used")
+	}
+	if signers > 0 && (isWebSocketURL(target.URL) || isGRPCURL(target.URL)) {
+		problem("URL", "request signing is only for HTTP targets")
+	}
+	if target.HMACKey == "" && (target.HMACHeader != "" || target.HMACTimestampHeader != "") {
+		problem("HMACHeader", "HMAC headers require an HMAC key")
+	}
+	if target.AWSAccessKeyID != "" {
+		if target.AWSSecretAccessKey == "" || target.AWSRegion == "" || target.AWSService == "" {
+			problem("AWSAccessKeyID", "AWS signing requires a secret access key, region and service")
+		}
+		//the signature goes in the Authorization header
+		if auths > 0 {
+			problem("AWSAccessKeyID", "AWS signing cannot be combined with basic auth, bearer token, Digest auth or OAuth2")
+		}
+	} else if target.AWSSecretAccessKey != "" || target.AWSSessionToken != "" || target.AWSRegion != "" || target.AWSService != "" {
+		problem("AWSAccessKeyID", "AWS signing settings require an AWS access key ID")
+	}
+	if _, err := parseMultipartFields(target.Multipart); err != nil {
+		problem("Multipart", err.Error())
+	}
+	if target.Query != "" {
+		if target.Stream || isWebSocketURL(target.URL) || isGRPCURL(target.URL) {
+			problem("Query", "GraphQL queries are only for HTTP targets")
+		}
+		if bodies > 0 {
+			problem("Query", "GraphQL queries cannot be combined with a body")
+		}
+		if _, err := parseGraphQLVariables(target.Variables); err != nil {
+			problem("Variables", err.Error())
+		}
+	} else if target.Variables != "" || target.OperationName != "" {
+		problem("Query", "GraphQL variables and operation name require a query")
+	}
+	if isGRPCURL(target.URL) {
+		if target.HTTP3 || s.H2C {
+			problem("URL", "gRPC targets always use HTTP2 over their own connections")
+		}
+		if target.MaxConnsPerHost > 0 || target.MaxIdleConnsPerHost > 0 {
+			problem("MaxConnsPerHost", "per host connection limits are not supported for gRPC targets")
+		}
+		if _, _, err := parseGRPCMethod(target.GRPCMethod); err != nil {
+			problem("GRPCMethod", err.Error())
+		}
+	}
+	if target.Connections > 0 {
+		if target.MaxConnsPerHost > 0 || target.MaxIdleConnsPerHost > 0 {
+			problem("Connections", "connections cannot be combined with per host connection limits")
+		}
+		if target.Connections > target.Concurrency {
+			problem("Connections", "connections cannot be higher than concurrency")
+		}
+		if !target.KeepAlive {
+			problem("Connections", "connections requires keep-alive")
+		}
+	}
+	return problems
+}
+
+//create the http client used by all of a target's requests,
+//connecting from the target's source IPs in turn
+//one client per connection when the connection count is fixed, otherwise one shared client
+func targetClientCount(target Target) int {
+	if target.Connections > 0 {
+		return target.Connections
+	}
+	return 1
+}
+
+//close the gRPC connections of each target that has them
+func closeGRPCConns(targetConns [][]*grpc.ClientConn) {
+	for _, conns := range targetConns {
+		for _, conn := range conns {
+			if conn != nil {
+				conn.Close()
 			}
 		}
-		if target.Concurrency > target.Count {
-			return errors.New("concurrency must be higher than request count")
+	}
+}
+
+func createClient(target Target, s StressConfig, sources *sourceIPs) *http.Client {
+	idleTimeout := parseOptionalDuration(target.IdleTimeout)
+	dialer := &net.Dialer{Timeout: parseOptionalDuration(target.DialTimeout)}
+	dialContext := sources.dialContext(dialer)
+	var tr http.RoundTripper
+	if target.HTTP3 {
+		tr = newHTTP3Transport(target, s, sources)
+	} else if s.H2C {
+		//dial plain TCP where the transport expects TLS
+		tr = &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+				return dialContext(ctx, network, addr)
+			},
+			DisableCompression: true, //responses are decoded by runRequest
+			IdleConnTimeout:    idleTimeout,
+		}
+	} else {
+		h1tr := &http.Transport{}
+		h1tr.DialContext = dialContext
+		h1tr.TLSHandshakeTimeout = parseOptionalDuration(target.TLSHandshakeTimeout)
+		h1tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: !s.EnforceSSL}
+		h1tr.DisableCompression = true //responses are decoded by runRequest
+		h1tr.DisableKeepAlives = !target.KeepAlive
+		h1tr.MaxConnsPerHost = target.MaxConnsPerHost
+		h1tr.MaxIdleConnsPerHost = target.MaxIdleConnsPerHost
+		h1tr.IdleConnTimeout = idleTimeout
+		if target.Connections > 0 {
+			//each client of the target holds exactly one connection
+			h1tr.MaxConnsPerHost = 1
+			h1tr.MaxIdleConnsPerHost = 1
 		}
+		if s.NoHTTP2 {
+			h1tr.TLSNextProto = make(map[string](func(string, *tls.Conn) http.RoundTripper))
+		} else {
+			http2.ConfigureTransport(h1tr)
+		}
+		tr = h1tr
 	}
-	return nil
+	var timeout time.Duration
+	if target.Stream {
+		//the client's timeout includes reading the body, which would cut streams off
+		timeout = time.Duration(0)
+	} else if target.Timeout != "" {
+		timeout, _ = time.ParseDuration(target.Timeout)
+	} else {
+		timeout = time.Duration(0)
+	}
+	client := &http.Client{Timeout: timeout, Transport: tr}
+	if !target.FollowRedirects {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+	return client
+}
+
+//parse a duration that has already been validated, empty string is zero
+func parseOptionalDuration(durationStr string) time.Duration {
+	if durationStr == "" {
+		return 0
+	}
+	duration, _ := time.ParseDuration(durationStr)
+	return duration
 }
 
-//build the http request out of the target's config
-func buildRequest(t Target) (http.Request, error) {
+//build the http request out of the target's config.
+//body is the target's body as loaded once for all of its requests, nil to load it here.
+func buildRequest(t Target, body *requestBody) (http.Request, error) {
 	if t.URL == "" {
 		return http.Request{}, errors.New("empty URL")
 	}
 	if len(t.URL) < 8 {
 		return http.Request{}, errors.New("URL too short")
 	}
-	//prepend "http://" if scheme not provided
+	//prepend "http://" if scheme not provided, or "https://" for HTTP3 which is always encrypted
 	//maybe a cleaner way to do this via net.url?
-	if t.URL[:7] != "http://" && t.URL[:8] != "https://" {
-		t.URL = "http://" + t.URL
+	if t.URL[:7] != "http://" && t.URL[:8] != "https://" && !isWebSocketURL(t.URL) && !isGRPCURL(t.URL) {
+		if t.HTTP3 {
+			t.URL = "https://" + t.URL
+		} else {
+			t.URL = "http://" + t.URL
+		}
 	}
 	var urlStr string
 	var err error
@@ -315,18 +999,7 @@ func buildRequest(t Target) (http.Request, error) {
 	}
 
 	//setup the request
-	var req *http.Request
-	if t.BodyFilename != "" {
-		fileContents, err := ioutil.ReadFile(t.BodyFilename)
-		if err != nil {
-			return http.Request{}, errors.New("failed to read contents of file " + t.BodyFilename + ": " + err.Error())
-		}
-		req, err = http.NewRequest(t.Method, URL.String(), bytes.NewBuffer(fileContents))
-	} else if t.Body != "" {
-		req, err = http.NewRequest(t.Method, URL.String(), bytes.NewBuffer([]byte(t.Body)))
-	} else {
-		req, err = http.NewRequest(t.Method, URL.String(), nil)
-	}
+	req, err := http.NewRequest(t.Method, URL.String(), nil)
 	if err != nil {
 		return http.Request{}, errors.New("failed to create request: " + err.Error())
 	}
@@ -340,6 +1013,16 @@ func buildRequest(t Target) (http.Request, error) {
 			req.Header.Add(key, val)
 		}
 	}
+	for key, val := range t.HeaderMap {
+		req.Header.Add(key, val)
+	}
+	headerList, err := parseHeaderList(t.HeaderList)
+	if err != nil {
+		return http.Request{}, err
+	}
+	for _, header := range headerList {
+		req.Header.Add(header[0], header[1])
+	}
 
 	req.Header.Set("User-Agent", t.UserAgent)
 
@@ -353,6 +1036,13 @@ func buildRequest(t Target) (http.Request, error) {
 			req.AddCookie(&http.Cookie{Name: key, Value: val})
 		}
 	}
+	cookieList, err := parseCookieList(t.CookieList)
+	if err != nil {
+		return http.Request{}, err
+	}
+	for _, cookie := range cookieList {
+		req.AddCookie(&http.Cookie{Name: cookie[0], Value: cookie[1]})
+	}
 
 	if t.BasicAuth != "" {
 		authMap, err := parseKeyValString(t.BasicAuth, ",", ":")
@@ -364,9 +1054,62 @@ func buildRequest(t Target) (http.Request, error) {
 			break
 		}
 	}
+	if t.BasicAuthUser != "" {
+		req.SetBasicAuth(t.BasicAuthUser, t.BasicAuthPass)
+	}
+	//OAuth2 tokens are set as each request is sent, as they may have been replaced by then
+	if t.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+t.BearerToken)
+	}
+
+	//like the transport would, which is left to only decode responses so their encoding can be recorded
+	if t.Compress && req.Header.Get("Accept-Encoding") == "" && req.Header.Get("Range") == "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+
+	//after the headers, which may have set a Content-Type of their own
+	if body == nil {
+		body, err = loadBody(t)
+		if err != nil {
+			return http.Request{}, err
+		}
+	}
+	if body != nil {
+		if err := body.setOn(req); err != nil {
+			return http.Request{}, err
+		}
+	}
 	return *req, nil
 }
 
+//split each of headers like "Accept: text/html, application/json" at its first colon,
+//into pairs of name and value, keeping their order and any repeated names
+func parseHeaderList(headers []string) ([][2]string, error) {
+	var parsed [][2]string
+	for _, header := range headers {
+		parts := strings.SplitN(header, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, errors.New("header must be like Name: value: " + header)
+		}
+		parsed = append(parsed, [2]string{strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])})
+	}
+	return parsed, nil
+}
+
+//split each of cookies like "session=456" at its first equals sign,
+//into pairs of name and value, keeping their order and any repeated names
+func parseCookieList(cookies []string) ([][2]string, error) {
+	var parsed [][2]string
+	for _, cookie := range cookies {
+		parts := strings.SplitN(cookie, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, errors.New("cookie must be like name=value: " + cookie)
+		}
+		parsed = append(parsed, [2]string{strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])})
+	}
+	return parsed, nil
+}
+
 //splits on delim into parts and trims whitespace
 //delim1 splits the pairs, delim2 splits amongst the pairs
 //like parseKeyValString("key1: val2, key3 : val4,key5:val6 ", ",", ":") becomes
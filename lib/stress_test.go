@@ -1,11 +1,24 @@
 package pewpew
 
 import (
+	"crypto/tls"
 	"io"
 	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	quic "github.com/quic-go/quic-go"
+	http3 "github.com/quic-go/quic-go/http3"
+	http2 "golang.org/x/net/http2"
+	h2c "golang.org/x/net/http2/h2c"
 )
 
 const tempFilename = "/tmp/testdata"
@@ -41,88 +54,919 @@ func TestValidateTargets(t *testing.T) {
 			Targets: []Target{
 				{
 					URL:         DefaultURL,
-					Count:       0,
+					Count:       0,
+					Concurrency: DefaultConcurrency,
+					Timeout:     DefaultTimeout,
+					Method:      DefaultMethod,
+				},
+			},
+		}, true},
+		//zero concurrency
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         DefaultURL,
+					Count:       DefaultCount,
+					Concurrency: 0,
+					Timeout:     DefaultTimeout,
+					Method:      DefaultMethod,
+				},
+			},
+		}, true},
+		//concurrency > count
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         DefaultURL,
+					Count:       10,
+					Concurrency: 20,
+					Timeout:     DefaultTimeout,
+					Method:      DefaultMethod,
+				},
+			},
+		}, true},
+		//empty method
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         DefaultURL,
+					Count:       DefaultCount,
+					Concurrency: DefaultConcurrency,
+					Timeout:     DefaultTimeout,
+					Method:      "",
+				},
+			},
+		}, true},
+		//empty timeout string okay
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         DefaultURL,
+					Count:       DefaultCount,
+					Concurrency: DefaultConcurrency,
+					Timeout:     "",
+					Method:      DefaultMethod,
+				},
+			},
+		}, false},
+		//invalid time string
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         DefaultURL,
+					Count:       DefaultCount,
+					Concurrency: DefaultConcurrency,
+					Timeout:     "unparseable",
+					Method:      DefaultMethod,
+				},
+			},
+		}, true},
+		//timeout too short
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         DefaultURL,
+					Count:       DefaultCount,
+					Concurrency: DefaultConcurrency,
+					Timeout:     "1ms",
+					Method:      DefaultMethod,
+				},
+			},
+		}, true},
+
+		//HTTP3 with plaintext URL
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         "http://localhost",
+					Count:       DefaultCount,
+					Concurrency: DefaultConcurrency,
+					Method:      DefaultMethod,
+					HTTP3:       true,
+				},
+			},
+		}, true},
+		//HTTP3 and h2c
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         "https://localhost",
+					Count:       DefaultCount,
+					Concurrency: DefaultConcurrency,
+					Method:      DefaultMethod,
+					HTTP3:       true,
+				},
+			},
+			H2C: true,
+		}, true},
+		//negative connection limit
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:             DefaultURL,
+					Count:           DefaultCount,
+					Concurrency:     DefaultConcurrency,
+					Method:          DefaultMethod,
+					MaxConnsPerHost: -1,
+				},
+			},
+		}, true},
+		//per host connection limit with h2c
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:             DefaultURL,
+					Count:           DefaultCount,
+					Concurrency:     DefaultConcurrency,
+					Method:          DefaultMethod,
+					MaxConnsPerHost: 2,
+				},
+			},
+			H2C: true,
+		}, true},
+		//invalid idle timeout
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         DefaultURL,
+					Count:       DefaultCount,
+					Concurrency: DefaultConcurrency,
+					Method:      DefaultMethod,
+					IdleTimeout: "unparseable",
+				},
+			},
+		}, true},
+		//connections > concurrency
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         DefaultURL,
+					Count:       DefaultCount,
+					Concurrency: 2,
+					Method:      DefaultMethod,
+					KeepAlive:   true,
+					Connections: 3,
+				},
+			},
+		}, true},
+		//connections without keep-alive
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         DefaultURL,
+					Count:       DefaultCount,
+					Concurrency: 2,
+					Method:      DefaultMethod,
+					Connections: 2,
+				},
+			},
+		}, true},
+		//connections and per host limit
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:             DefaultURL,
+					Count:           DefaultCount,
+					Concurrency:     2,
+					Method:          DefaultMethod,
+					KeepAlive:       true,
+					Connections:     2,
+					MaxConnsPerHost: 2,
+				},
+			},
+		}, true},
+		//invalid dial timeout
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         DefaultURL,
+					Count:       DefaultCount,
+					Concurrency: DefaultConcurrency,
+					Method:      DefaultMethod,
+					DialTimeout: "unparseable",
+				},
+			},
+		}, true},
+		//negative response header timeout
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:                   DefaultURL,
+					Count:                 DefaultCount,
+					Concurrency:           DefaultConcurrency,
+					Method:                DefaultMethod,
+					ResponseHeaderTimeout: "-1s",
+				},
+			},
+		}, true},
+		//invalid source IP
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         DefaultURL,
+					Count:       DefaultCount,
+					Concurrency: DefaultConcurrency,
+					Method:      DefaultMethod,
+					SourceIPs:   "127.0.0.1,localhost",
+				},
+			},
+		}, true},
+		//invalid WebSocket message template
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         "ws://localhost",
+					Count:       DefaultCount,
+					Concurrency: DefaultConcurrency,
+					Method:      DefaultMethod,
+					WSMessages:  []string{"{{.ID"},
+				},
+			},
+		}, true},
+		//WebSocket over HTTP3
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         "wss://localhost",
+					Count:       DefaultCount,
+					Concurrency: DefaultConcurrency,
+					Method:      DefaultMethod,
+					HTTP3:       true,
+				},
+			},
+		}, true},
+		//gRPC without a method
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         "grpc://localhost:50051",
+					Count:       DefaultCount,
+					Concurrency: DefaultConcurrency,
+					Method:      DefaultMethod,
+				},
+			},
+		}, true},
+		//gRPC over HTTP3
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         "grpcs://localhost:50051",
+					Count:       DefaultCount,
+					Concurrency: DefaultConcurrency,
+					Method:      DefaultMethod,
+					GRPCMethod:  "grpc.health.v1.Health/Check",
+					HTTP3:       true,
+				},
+			},
+		}, true},
+		//bad stream duration
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:            DefaultURL,
+					Count:          DefaultCount,
+					Concurrency:    DefaultConcurrency,
+					Method:         DefaultMethod,
+					Stream:         true,
+					StreamDuration: "forever",
+				},
+			},
+		}, true},
+		//stream mode for a WebSocket
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         "ws://localhost",
+					Count:       DefaultCount,
+					Concurrency: DefaultConcurrency,
+					Method:      DefaultMethod,
+					Stream:      true,
+				},
+			},
+		}, true},
+		//GraphQL variables that aren't an object
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         DefaultURL,
+					Count:       DefaultCount,
+					Concurrency: DefaultConcurrency,
+					Method:      DefaultMethod,
+					Query:       "{ user { id } }",
+					Variables:   "[{{.Seq}}]",
+				},
+			},
+		}, true},
+		//GraphQL query with a body
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         DefaultURL,
+					Count:       DefaultCount,
+					Concurrency: DefaultConcurrency,
+					Method:      DefaultMethod,
+					Query:       "{ user { id } }",
+					Body:        "{}",
+				},
+			},
+		}, true},
+		//GraphQL variables without a query
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         DefaultURL,
+					Count:       DefaultCount,
+					Concurrency: DefaultConcurrency,
+					Method:      DefaultMethod,
+					Variables:   `{"id": 1}`,
+				},
+			},
+		}, true},
+		//form and body
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         DefaultURL,
+					Count:       DefaultCount,
+					Concurrency: DefaultConcurrency,
+					Method:      DefaultMethod,
+					Body:        "data",
+					Form:        []string{"a=b"},
+				},
+			},
+		}, true},
+		//invalid multipart field
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         DefaultURL,
+					Count:       DefaultCount,
+					Concurrency: DefaultConcurrency,
+					Method:      DefaultMethod,
+					Multipart:   []string{"a"},
+				},
+			},
+		}, true},
+		//invalid body size
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         DefaultURL,
+					Count:       DefaultCount,
+					Concurrency: DefaultConcurrency,
+					Method:      DefaultMethod,
+					BodySize:    "lots",
+				},
+			},
+		}, true},
+		//streaming a body file without one
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:            DefaultURL,
+					Count:          DefaultCount,
+					Concurrency:    DefaultConcurrency,
+					Method:         DefaultMethod,
+					StreamBodyFile: true,
+				},
+			},
+		}, true},
+		//compressed body
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:          DefaultURL,
+					Count:        DefaultCount,
+					Concurrency:  DefaultConcurrency,
+					Method:       DefaultMethod,
+					Body:         "hello",
+					BodyEncoding: EncodingZstd,
+				},
+			},
+		}, false},
+		//unsupported body encoding
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:          DefaultURL,
+					Count:        DefaultCount,
+					Concurrency:  DefaultConcurrency,
+					Method:       DefaultMethod,
+					Body:         "hello",
+					BodyEncoding: "lzma",
+				},
+			},
+		}, true},
+		//body encoding without a body
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:          DefaultURL,
+					Count:        DefaultCount,
+					Concurrency:  DefaultConcurrency,
+					Method:       DefaultMethod,
+					BodyEncoding: EncodingGzip,
+				},
+			},
+		}, true},
+		//body encoding of a streamed body file
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:            DefaultURL,
+					Count:          DefaultCount,
+					Concurrency:    DefaultConcurrency,
+					Method:         DefaultMethod,
+					BodyFilename:   "body.json",
+					StreamBodyFile: true,
+					BodyEncoding:   EncodingGzip,
+				},
+			},
+		}, true},
+		//invalid header list
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         DefaultURL,
+					Count:       DefaultCount,
+					Concurrency: DefaultConcurrency,
+					Method:      DefaultMethod,
+					HeaderList:  []string{"Accept"},
+				},
+			},
+		}, true},
+		//invalid cookie list
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         DefaultURL,
+					Count:       DefaultCount,
+					Concurrency: DefaultConcurrency,
+					Method:      DefaultMethod,
+					CookieList:  []string{"a"},
+				},
+			},
+		}, true},
+		//both forms of basic auth
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:           DefaultURL,
+					Count:         DefaultCount,
+					Concurrency:   DefaultConcurrency,
+					Method:        DefaultMethod,
+					BasicAuth:     "user:pass",
+					BasicAuthUser: "user",
+				},
+			},
+		}, true},
+		//basic auth password without a user
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:           DefaultURL,
+					Count:         DefaultCount,
+					Concurrency:   DefaultConcurrency,
+					Method:        DefaultMethod,
+					BasicAuthPass: "pass",
+				},
+			},
+		}, true},
+		//OAuth2 client credentials
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:            DefaultURL,
+					Count:          DefaultCount,
+					Concurrency:    DefaultConcurrency,
+					Method:         DefaultMethod,
+					OAuth2TokenURL: "https://localhost/token",
+					OAuth2ClientID: "id",
+				},
+			},
+		}, false},
+		//OAuth2 without a client ID
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:            DefaultURL,
+					Count:          DefaultCount,
+					Concurrency:    DefaultConcurrency,
+					Method:         DefaultMethod,
+					OAuth2TokenURL: "https://localhost/token",
+				},
+			},
+		}, true},
+		//OAuth2 token URL that isn't http
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:            DefaultURL,
+					Count:          DefaultCount,
+					Concurrency:    DefaultConcurrency,
+					Method:         DefaultMethod,
+					OAuth2TokenURL: "localhost/token",
+					OAuth2ClientID: "id",
+				},
+			},
+		}, true},
+		//OAuth2 settings without a token URL
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:            DefaultURL,
+					Count:          DefaultCount,
+					Concurrency:    DefaultConcurrency,
+					Method:         DefaultMethod,
+					OAuth2ClientID: "id",
+				},
+			},
+		}, true},
+		//OAuth2 password without a username
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:            DefaultURL,
+					Count:          DefaultCount,
+					Concurrency:    DefaultConcurrency,
+					Method:         DefaultMethod,
+					OAuth2TokenURL: "https://localhost/token",
+					OAuth2ClientID: "id",
+					OAuth2Password: "pass",
+				},
+			},
+		}, true},
+		//bearer token and basic auth
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         DefaultURL,
+					Count:       DefaultCount,
+					Concurrency: DefaultConcurrency,
+					Method:      DefaultMethod,
+					BearerToken: "abc123",
+					BasicAuth:   "user:pass",
+				},
+			},
+		}, true},
+		//HMAC signing
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         DefaultURL,
+					Count:       DefaultCount,
+					Concurrency: DefaultConcurrency,
+					Method:      DefaultMethod,
+					HMACKey:     "secret",
+					HMACHeader:  "X-Sig",
+				},
+			},
+		}, false},
+		//AWS signing
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:                DefaultURL,
+					Count:              DefaultCount,
+					Concurrency:        DefaultConcurrency,
+					Method:             DefaultMethod,
+					AWSAccessKeyID:     "AKID",
+					AWSSecretAccessKey: "secret",
+					AWSRegion:          "us-east-1",
+					AWSService:         "s3",
+				},
+			},
+		}, false},
+		//HMAC and AWS signing
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:                DefaultURL,
+					Count:              DefaultCount,
+					Concurrency:        DefaultConcurrency,
+					Method:             DefaultMethod,
+					HMACKey:            "secret",
+					AWSAccessKeyID:     "AKID",
+					AWSSecretAccessKey: "secret",
+					AWSRegion:          "us-east-1",
+					AWSService:         "s3",
+				},
+			},
+		}, true},
+		//HMAC signing of a WebSocket
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         "ws://localhost/socket",
+					Count:       DefaultCount,
+					Concurrency: DefaultConcurrency,
+					Method:      DefaultMethod,
+					HMACKey:     "secret",
+				},
+			},
+		}, true},
+		//HMAC header without a key
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         DefaultURL,
+					Count:       DefaultCount,
+					Concurrency: DefaultConcurrency,
+					Method:      DefaultMethod,
+					HMACHeader:  "X-Sig",
+				},
+			},
+		}, true},
+		//AWS signing without a region
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:                DefaultURL,
+					Count:              DefaultCount,
+					Concurrency:        DefaultConcurrency,
+					Method:             DefaultMethod,
+					AWSAccessKeyID:     "AKID",
+					AWSSecretAccessKey: "secret",
+					AWSService:         "s3",
+				},
+			},
+		}, true},
+		//AWS signing settings without an access key
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         DefaultURL,
+					Count:       DefaultCount,
+					Concurrency: DefaultConcurrency,
+					Method:      DefaultMethod,
+					AWSRegion:   "us-east-1",
+				},
+			},
+		}, true},
+		//AWS signing with a bearer token
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:                DefaultURL,
+					Count:              DefaultCount,
+					Concurrency:        DefaultConcurrency,
+					Method:             DefaultMethod,
+					AWSAccessKeyID:     "AKID",
+					AWSSecretAccessKey: "secret",
+					AWSRegion:          "us-east-1",
+					AWSService:         "s3",
+					BearerToken:        "abc123",
+				},
+			},
+		}, true},
+		//Digest auth
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:            DefaultURL,
+					Count:          DefaultCount,
+					Concurrency:    DefaultConcurrency,
+					Method:         DefaultMethod,
+					DigestAuthUser: "user",
+					DigestAuthPass: "pass",
+				},
+			},
+		}, false},
+		//Digest auth and bearer token
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:            DefaultURL,
+					Count:          DefaultCount,
+					Concurrency:    DefaultConcurrency,
+					Method:         DefaultMethod,
+					DigestAuthUser: "user",
+					BearerToken:    "abc123",
+				},
+			},
+		}, true},
+		//Digest auth password without a user
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:            DefaultURL,
+					Count:          DefaultCount,
+					Concurrency:    DefaultConcurrency,
+					Method:         DefaultMethod,
+					DigestAuthPass: "pass",
+				},
+			},
+		}, true},
+		//Digest auth of a WebSocket
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:            "ws://localhost/socket",
+					Count:          DefaultCount,
+					Concurrency:    DefaultConcurrency,
+					Method:         DefaultMethod,
+					DigestAuthUser: "user",
+				},
+			},
+		}, true},
+		//start delay
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         DefaultURL,
+					Count:       DefaultCount,
 					Concurrency: DefaultConcurrency,
-					Timeout:     DefaultTimeout,
 					Method:      DefaultMethod,
+					StartDelay:  "1.5s",
 				},
 			},
-		}, true},
-		//zero concurrency
+		}, false},
+		//bad start delay
 		{StressConfig{
 			Targets: []Target{
 				{
 					URL:         DefaultURL,
 					Count:       DefaultCount,
-					Concurrency: 0,
-					Timeout:     DefaultTimeout,
+					Concurrency: DefaultConcurrency,
 					Method:      DefaultMethod,
+					StartDelay:  "soon",
 				},
 			},
 		}, true},
-		//concurrency > count
+		//negative start delay
 		{StressConfig{
 			Targets: []Target{
 				{
 					URL:         DefaultURL,
-					Count:       10,
-					Concurrency: 20,
-					Timeout:     DefaultTimeout,
+					Count:       DefaultCount,
+					Concurrency: DefaultConcurrency,
 					Method:      DefaultMethod,
+					StartDelay:  "-1s",
 				},
 			},
 		}, true},
-		//empty method
+		//replayed requests
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:            DefaultURL,
+					Count:          2,
+					Concurrency:    DefaultConcurrency,
+					Method:         DefaultMethod,
+					ReplayRequests: []ReplayedRequest{{Method: "GET", URI: "/"}, {Method: "POST", URI: "/orders", Offset: time.Second}},
+				},
+			},
+		}, false},
+		//fewer requests than replayed
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:            DefaultURL,
+					Count:          DefaultCount,
+					Concurrency:    DefaultConcurrency,
+					Method:         DefaultMethod,
+					ReplayRequests: []ReplayedRequest{{Method: "GET", URI: "/"}},
+				},
+			},
+		}, true},
+		//replayed request without a path
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:            DefaultURL,
+					Count:          1,
+					Concurrency:    DefaultConcurrency,
+					Method:         DefaultMethod,
+					ReplayRequests: []ReplayedRequest{{Method: "GET", URI: "http://example.com/"}},
+				},
+			},
+		}, true},
+		//replayed requests to a WebSocket
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:            "ws://localhost",
+					Count:          1,
+					Concurrency:    DefaultConcurrency,
+					Method:         DefaultMethod,
+					ReplayRequests: []ReplayedRequest{{Method: "GET", URI: "/"}},
+				},
+			},
+		}, true},
+		//bad resolve
 		{StressConfig{
 			Targets: []Target{
 				{
 					URL:         DefaultURL,
 					Count:       DefaultCount,
 					Concurrency: DefaultConcurrency,
-					Timeout:     DefaultTimeout,
-					Method:      "",
+					Method:      DefaultMethod,
+					Resolve:     []string{"localhost:80"},
 				},
 			},
 		}, true},
-		//empty timeout string okay
+		//resolve
 		{StressConfig{
 			Targets: []Target{
 				{
 					URL:         DefaultURL,
 					Count:       DefaultCount,
 					Concurrency: DefaultConcurrency,
-					Timeout:     "",
 					Method:      DefaultMethod,
+					Resolve:     []string{"localhost:80:127.0.0.1"},
 				},
 			},
 		}, false},
-		//invalid time string
+		//h2c without HTTP2
 		{StressConfig{
 			Targets: []Target{
 				{
 					URL:         DefaultURL,
 					Count:       DefaultCount,
 					Concurrency: DefaultConcurrency,
-					Timeout:     "unparseable",
 					Method:      DefaultMethod,
 				},
 			},
+			H2C:     true,
+			NoHTTP2: true,
 		}, true},
-		//timeout too short
+		//h2c with an https target
 		{StressConfig{
 			Targets: []Target{
 				{
-					URL:         DefaultURL,
+					URL:         "https://localhost",
 					Count:       DefaultCount,
 					Concurrency: DefaultConcurrency,
-					Timeout:     "1ms",
 					Method:      DefaultMethod,
 				},
 			},
+			H2C: true,
 		}, true},
 
 		//good cases
 		{*NewStressConfig(), false},
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:                 DefaultURL,
+					Count:               DefaultCount,
+					Concurrency:         2,
+					Method:              DefaultMethod,
+					KeepAlive:           true,
+					MaxConnsPerHost:     2,
+					MaxIdleConnsPerHost: 2,
+					IdleTimeout:         "30s",
+				},
+			},
+		}, false},
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         DefaultURL,
+					Count:       DefaultCount,
+					Concurrency: 2,
+					Method:      DefaultMethod,
+					KeepAlive:   true,
+					Connections: 2,
+				},
+			},
+		}, false},
+		//invalid regex URL
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         "http://localhost/[a-z",
+					RegexURL:    true,
+					Count:       DefaultCount,
+					Concurrency: DefaultConcurrency,
+					Method:      DefaultMethod,
+				},
+			},
+		}, true},
+		//missing body file
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:          DefaultURL,
+					Count:        DefaultCount,
+					Concurrency:  DefaultConcurrency,
+					Method:       DefaultMethod,
+					BodyFilename: "/does/not/exist",
+				},
+			},
+		}, true},
+		//unparseable headers
+		{StressConfig{
+			Targets: []Target{
+				{
+					URL:         DefaultURL,
+					Count:       DefaultCount,
+					Concurrency: DefaultConcurrency,
+					Method:      DefaultMethod,
+					Headers:     "Accept",
+				},
+			},
+		}, true},
 	}
 	for _, c := range cases {
 		err := validateTargets(c.s)
@@ -163,6 +1007,16 @@ func TestBuildRequest(t *testing.T) {
 			BasicAuth: "::"}, true}, //invalid basic auth
 		{Target{URL: "http://localhost",
 			Method: "@"}, true}, //invalid method
+		{Target{URL: "http://localhost",
+			Method: "@", Form: []string{"a=b"}}, true}, //invalid method with a form
+		{Target{URL: "http://localhost",
+			Form: []string{"a"}}, true}, //invalid form field
+		{Target{URL: "http://localhost",
+			Multipart: []string{"a=@/thisfiledoesnotexist"}}, true}, //bad multipart file
+		{Target{URL: "http://localhost",
+			HeaderList: []string{"Accept"}}, true}, //invalid header list
+		{Target{URL: "http://localhost",
+			CookieList: []string{"=b"}}, true}, //invalid cookie list
 
 		//good cases
 		{Target{URL: "localhost"}, false}, //missing scheme (http://) should be auto fixed
@@ -172,24 +1026,154 @@ func TestBuildRequest(t *testing.T) {
 			Body:   "data"}, false},
 		{Target{URL: "https://www.github.com"}, false},
 		{Target{URL: "http://github.com"}, false},
+		{Target{URL: "localhost", HTTP3: true}, false}, //missing scheme should be https for HTTP3
+		{Target{URL: "ws://localhost/socket"}, false},
+		{Target{URL: "wss://localhost/socket"}, false},
+		{Target{URL: "grpc://localhost:50051"}, false},
 		{Target{URL: "http://localhost",
 			BodyFilename: ""}, false},
 		{Target{URL: "http://localhost",
 			BodyFilename: tempFilename}, false},
+		{Target{URL: "http://localhost",
+			Method: "POST",
+			Form:   []string{"a=b", "c=d"}}, false},
+		{Target{URL: "http://localhost",
+			Method:    "POST",
+			Multipart: []string{"a=b", "c=@" + tempFilename}}, false},
 		{Target{URL: "http://localhost:80/path/?param=val&another=one",
 			Headers:   "Accept-Encoding:gzip, Content-Type:application/json",
 			Cookies:   "a=b;c=d",
 			UserAgent: "pewpewpew",
 			BasicAuth: "user:pass"}, false},
+		{Target{URL: "http://localhost",
+			HeaderMap:     map[string]string{"If-Modified-Since": "Wed, 21 Oct 2015 07:28:00 GMT"},
+			HeaderList:    []string{"Accept: text/html, application/json", "X-Forwarded-For: a", "X-Forwarded-For: b"},
+			CookieList:    []string{"a=b", "a=c"},
+			BasicAuthUser: "user",
+			BasicAuthPass: "pass:with,commas"}, false},
+		{Target{URL: "http://localhost",
+			BearerToken: "abc123"}, false},
 	}
 	for _, c := range cases {
-		_, err := buildRequest(c.target)
+		_, err := buildRequest(c.target, nil)
 		if (err != nil) != c.hasErr {
 			t.Errorf("buildRequest(%+v) err: %t wanted: %t", c.target, (err != nil), c.hasErr)
 		}
 	}
 }
 
+func TestBuildRequestHeaders(t *testing.T) {
+	req, err := buildRequest(Target{
+		URL:           "http://localhost",
+		Headers:       "X-Old: 1",
+		HeaderMap:     map[string]string{"if-modified-since": "Wed, 21 Oct 2015 07:28:00 GMT"},
+		HeaderList:    []string{"Accept: text/html, application/json", "X-Forwarded-For: a", "X-Forwarded-For: b", "Referer: http://localhost:8080/"},
+		Cookies:       "old=1",
+		CookieList:    []string{"a=b", "a=c=d"},
+		BasicAuthUser: "user",
+		BasicAuthPass: "pass:with,commas",
+	}, nil)
+	if err != nil {
+		t.Fatalf("buildRequest err: %s", err)
+	}
+	wantHeaders := map[string][]string{
+		"X-Old":             {"1"},
+		"If-Modified-Since": {"Wed, 21 Oct 2015 07:28:00 GMT"},
+		"Accept":            {"text/html, application/json"},
+		"X-Forwarded-For":   {"a", "b"},
+		"Referer":           {"http://localhost:8080/"},
+	}
+	for name, want := range wantHeaders {
+		if got := req.Header.Values(name); !reflect.DeepEqual(got, want) {
+			t.Errorf("header %s == %q wanted %q", name, got, want)
+		}
+	}
+	var cookies []string
+	for _, cookie := range req.Cookies() {
+		cookies = append(cookies, cookie.Name+"="+cookie.Value)
+	}
+	if want := []string{"old=1", "a=b", "a=c=d"}; !reflect.DeepEqual(cookies, want) {
+		t.Errorf("cookies == %q wanted %q", cookies, want)
+	}
+	if user, pass, _ := req.BasicAuth(); user != "user" || pass != "pass:with,commas" {
+		t.Errorf("basic auth == %q, %q wanted %q, %q", user, pass, "user", "pass:with,commas")
+	}
+
+	req, err = buildRequest(Target{URL: "http://localhost", BearerToken: "abc123"}, nil)
+	if err != nil {
+		t.Fatalf("buildRequest err: %s", err)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer abc123" {
+		t.Errorf("Authorization == %q wanted %q", got, "Bearer abc123")
+	}
+
+	//Compress asks for gzip, unless the target asks for encodings of its own
+	for _, c := range []struct {
+		target Target
+		want   string
+	}{
+		{Target{URL: "http://localhost", Compress: true}, "gzip"},
+		{Target{URL: "http://localhost", Compress: true, HeaderList: []string{"Accept-Encoding: br, zstd"}}, "br, zstd"},
+		{Target{URL: "http://localhost"}, ""},
+	} {
+		req, err = buildRequest(c.target, nil)
+		if err != nil {
+			t.Fatalf("buildRequest err: %s", err)
+		}
+		if got := req.Header.Get("Accept-Encoding"); got != c.want {
+			t.Errorf("Accept-Encoding of %+v == %q wanted %q", c.target.HeaderList, got, c.want)
+		}
+	}
+}
+
+func TestParseHeaderList(t *testing.T) {
+	cases := []struct {
+		headers []string
+		want    [][2]string
+		hasErr  bool
+	}{
+		{nil, nil, false},
+		{[]string{"Accept: text/html, application/json"}, [][2]string{{"Accept", "text/html, application/json"}}, false},
+		{[]string{"X-A:1", " X-A : 2 "}, [][2]string{{"X-A", "1"}, {"X-A", "2"}}, false},
+		{[]string{"X-Empty:"}, [][2]string{{"X-Empty", ""}}, false},
+		{[]string{"Accept"}, nil, true},
+		{[]string{": value"}, nil, true},
+	}
+	for _, c := range cases {
+		result, err := parseHeaderList(c.headers)
+		if (err != nil) != c.hasErr {
+			t.Errorf("parseHeaderList(%q) err: %t wanted %t", c.headers, (err != nil), c.hasErr)
+			continue
+		}
+		if !reflect.DeepEqual(result, c.want) {
+			t.Errorf("parseHeaderList(%q) == %v wanted %v", c.headers, result, c.want)
+		}
+	}
+}
+
+func TestParseCookieList(t *testing.T) {
+	cases := []struct {
+		cookies []string
+		want    [][2]string
+		hasErr  bool
+	}{
+		{nil, nil, false},
+		{[]string{"a=b", "a=c=d"}, [][2]string{{"a", "b"}, {"a", "c=d"}}, false},
+		{[]string{"a"}, nil, true},
+		{[]string{"=b"}, nil, true},
+	}
+	for _, c := range cases {
+		result, err := parseCookieList(c.cookies)
+		if (err != nil) != c.hasErr {
+			t.Errorf("parseCookieList(%q) err: %t wanted %t", c.cookies, (err != nil), c.hasErr)
+			continue
+		}
+		if !reflect.DeepEqual(result, c.want) {
+			t.Errorf("parseCookieList(%q) == %v wanted %v", c.cookies, result, c.want)
+		}
+	}
+}
+
 func TestParseKeyValString(t *testing.T) {
 	cases := []struct {
 		str    string
@@ -234,6 +1218,7 @@ func TestRunStress(t *testing.T) {
 		{StressConfig{Targets: []Target{{URL: "http://localhost", Method: "GET", Count: 1, Concurrency: 1}}}, ioutil.Discard, false},                                                                     //single target
 		{StressConfig{Targets: []Target{{URL: "http://localhost:999999999", Method: "GET", Count: 1, Concurrency: 1}}}, ioutil.Discard, false},                                                           //request that should cause an http err that will get handled
 		{StressConfig{Targets: []Target{{URL: "http://localhost", Method: "GET", Count: 1, Concurrency: 1}}, NoHTTP2: true}, ioutil.Discard, false},                                                      //noHTTP
+		{StressConfig{Targets: []Target{{URL: "http://localhost", Method: "GET", Count: 1, Concurrency: 1}}, H2C: true}, ioutil.Discard, false},                                                          //h2c
 		{StressConfig{Targets: []Target{{URL: "http://localhost", Method: "GET", Count: 1, Concurrency: 1}}, Timeout: "2s"}, ioutil.Discard, false},                                                      //timeout
 		{StressConfig{Targets: []Target{{URL: "http://localhost", Method: "GET", Count: 1, Concurrency: 1}}, FollowRedirects: true}, ioutil.Discard, false},                                              //follow redirects
 		{StressConfig{Targets: []Target{{URL: "http://localhost", Method: "GET", Count: 1, Concurrency: 1}}, FollowRedirects: false}, ioutil.Discard, false},                                             //don't follow redirects
@@ -249,3 +1234,288 @@ func TestRunStress(t *testing.T) {
 		}
 	}
 }
+
+func TestRunStressH2C(t *testing.T) {
+	server := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}), &http2.Server{}))
+	defer server.Close()
+
+	stressConfig := StressConfig{
+		Targets: []Target{{URL: server.URL, Method: "GET", Count: 20, Concurrency: 4}},
+		H2C:     true,
+		Quiet:   true,
+	}
+	targetStats, err := RunStress(stressConfig, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("RunStress(%+v) err: %s", stressConfig, err)
+	}
+	connIDs := make(map[int]bool)
+	for _, stat := range targetStats[0] {
+		if stat.Error != nil {
+			t.Fatalf("h2c request failed: %s", stat.Error)
+		}
+		if stat.Proto != "HTTP/2.0" {
+			t.Errorf("h2c request proto == %s wanted HTTP/2.0", stat.Proto)
+		}
+		connIDs[stat.ConnID] = true
+	}
+	//all workers should share the one connection
+	if len(connIDs) != 1 {
+		t.Errorf("h2c requests used %d connections wanted 1", len(connIDs))
+	}
+}
+
+func TestRunStressHTTP3(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+	//borrow the test certificate of a TLS server
+	tlsServer := httptest.NewTLSServer(handler)
+	defer tlsServer.Close()
+	udpConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen on UDP: %s", err)
+	}
+	server := &http3.Server{
+		Handler:    handler,
+		TLSConfig:  http3.ConfigureTLSConfig(&tls.Config{Certificates: tlsServer.TLS.Certificates}),
+		QUICConfig: &quic.Config{Allow0RTT: true},
+	}
+	go server.Serve(udpConn)
+	defer server.Close()
+
+	stressConfig := StressConfig{
+		Targets: []Target{{URL: "https://" + udpConn.LocalAddr().String(), Method: "GET", Count: 5, Concurrency: 1, HTTP3: true, SourceIPs: "127.0.0.1"}},
+		Quiet:   true,
+	}
+	targetStats, err := RunStress(stressConfig, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("RunStress(%+v) err: %s", stressConfig, err)
+	}
+	zeroRTTCount := 0
+	connIDs := make(map[int]bool)
+	for i, stat := range targetStats[0] {
+		if stat.Error != nil {
+			t.Fatalf("HTTP3 request failed: %s", stat.Error)
+		}
+		if stat.Proto != "HTTP/3.0" {
+			t.Errorf("HTTP3 request proto == %s wanted HTTP/3.0", stat.Proto)
+		}
+		//without keep-alive every request makes its own handshake
+		if stat.HandshakeTime <= 0 {
+			t.Errorf("HTTP3 request %d handshake time == %s wanted > 0", i, stat.HandshakeTime)
+		}
+		if stat.Used0RTT {
+			zeroRTTCount++
+		}
+		if stat.SourceIP != "127.0.0.1" {
+			t.Errorf("HTTP3 request source IP == %s wanted 127.0.0.1", stat.SourceIP)
+		}
+		connIDs[stat.ConnID] = true
+	}
+	if len(connIDs) != len(targetStats[0]) {
+		t.Errorf("HTTP3 requests used %d connections wanted %d", len(connIDs), len(targetStats[0]))
+	}
+	//only the first request has no session to resume
+	if zeroRTTCount != len(targetStats[0])-1 {
+		t.Errorf("HTTP3 requests sent as 0-RTT == %d wanted %d", zeroRTTCount, len(targetStats[0])-1)
+	}
+
+	//with keep-alive the requests share one connection, though each gets a wrapper of its own
+	stressConfig.Targets[0].KeepAlive = true
+	targetStats, err = RunStress(stressConfig, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("RunStress(%+v) err: %s", stressConfig, err)
+	}
+	connIDs = make(map[int]bool)
+	for _, stat := range targetStats[0] {
+		if stat.Error != nil {
+			t.Fatalf("HTTP3 request failed: %s", stat.Error)
+		}
+		connIDs[stat.ConnID] = true
+	}
+	if len(connIDs) != 1 {
+		t.Errorf("HTTP3 requests with keep-alive used %d connections wanted 1", len(connIDs))
+	}
+}
+
+func TestRunStressConnections(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		target   Target
+		maxConns int
+	}{
+		{Target{URL: server.URL, Method: "GET", Count: 30, Concurrency: 6, KeepAlive: true, Connections: 3}, 3},
+		{Target{URL: server.URL, Method: "GET", Count: 30, Concurrency: 6, KeepAlive: true, MaxConnsPerHost: 2, MaxIdleConnsPerHost: 2}, 2},
+		{Target{URL: server.URL, Method: "GET", Count: 30, Concurrency: 6, KeepAlive: true, Connections: 2, SourceIPs: "127.0.0.1"}, 2},
+	}
+	for _, c := range cases {
+		targetStats, err := RunStress(StressConfig{Targets: []Target{c.target}, Quiet: true}, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("RunStress(%+v) err: %s", c.target, err)
+		}
+		connRequests := make(map[int]int)
+		for _, stat := range targetStats[0] {
+			if stat.Error != nil {
+				t.Fatalf("request failed: %s", stat.Error)
+			}
+			if c.target.SourceIPs != "" && stat.SourceIP != c.target.SourceIPs {
+				t.Errorf("request source IP == %s wanted %s", stat.SourceIP, c.target.SourceIPs)
+			}
+			connRequests[stat.ConnID]++
+		}
+		if len(connRequests) > c.maxConns {
+			t.Errorf("RunStress(%+v) used %d connections wanted at most %d", c.target, len(connRequests), c.maxConns)
+		}
+	}
+}
+
+func TestRunStressTimeouts(t *testing.T) {
+	//answers the headers after a while, then the body after another while
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	//nothing listens here once closed
+	closedServer := httptest.NewServer(http.NotFoundHandler())
+	closedServer.Close()
+
+	cases := []struct {
+		target    Target
+		errorKind string
+	}{
+		{Target{URL: server.URL, ResponseHeaderTimeout: "20ms"}, ErrorResponseHeaderTimeout},
+		{Target{URL: server.URL, Timeout: "50ms"}, ErrorTimeout},
+		{Target{URL: server.URL, ResponseHeaderTimeout: "150ms", DialTimeout: "1s", TLSHandshakeTimeout: "1s"}, ""},
+		{Target{URL: closedServer.URL, DialTimeout: "1s"}, ErrorConnRefused},
+	}
+	for _, c := range cases {
+		c.target.Method = "GET"
+		c.target.Count = 1
+		c.target.Concurrency = 1
+		targetStats, err := RunStress(StressConfig{Targets: []Target{c.target}, Quiet: true}, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("RunStress(%+v) err: %s", c.target, err)
+		}
+		stat := targetStats[0][0]
+		if stat.ErrorKind != c.errorKind {
+			t.Errorf("RunStress(%+v) error kind == %q wanted %q (error: %v)", c.target, stat.ErrorKind, c.errorKind, stat.Error)
+		}
+	}
+}
+
+func TestRunStressStartDelay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	start := time.Now()
+	//the delayed target comes first, so finishes last
+	targets := []Target{
+		{URL: server.URL + "/later", Method: "GET", Count: 2, Concurrency: 1, StartDelay: "200ms"},
+		{URL: server.URL + "/now", Method: "GET", Count: 1, Concurrency: 1},
+	}
+	targetStats, err := RunStress(StressConfig{Targets: targets, Quiet: true}, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("RunStress err: %s", err)
+	}
+	for i, stats := range targetStats {
+		//stats are in the order of the targets, not the order they finished in
+		if len(stats) != targets[i].Count {
+			t.Fatalf("RunStress returned %d stats for target %d wanted %d", len(stats), i+1, targets[i].Count)
+		}
+		for _, stat := range stats {
+			if stat.Error != nil {
+				t.Fatalf("request failed: %s", stat.Error)
+			}
+			if stat.URL != targets[i].URL {
+				t.Errorf("RunStress returned a stat of %s for target %d, %s", stat.URL, i+1, targets[i].URL)
+			}
+			delayed := strings.HasSuffix(stat.URL, "/later")
+			if waited := stat.StartTime.Sub(start); delayed != (waited >= 200*time.Millisecond) {
+				t.Errorf("request to %s started after %s", stat.URL, waited)
+			}
+		}
+	}
+}
+
+func TestRunStressReplay(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	inFlight, maxInFlight := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received = append(received, r.Method+" "+r.URL.RequestURI()+" "+r.Referer())
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	requests := []ReplayedRequest{
+		{Method: "GET", URI: "/search?q=pew", Referer: "https://example.com/"},
+		{Method: "POST", URI: "/orders"},
+		{Method: "GET", URI: "/a"},
+		{Method: "GET", URI: "/b"},
+		{Method: "DELETE", URI: "/orders/1", Offset: 200 * time.Millisecond},
+	}
+	target := Target{URL: server.URL, Method: "GET", Count: len(requests), Concurrency: 2, KeepAlive: true, ReplayRequests: requests}
+	start := time.Now()
+	targetStats, err := RunStress(StressConfig{Targets: []Target{target}, Quiet: true}, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("RunStress err: %s", err)
+	}
+	conns := make(map[int]bool)
+	for _, stat := range targetStats[0] {
+		if stat.Error != nil {
+			t.Fatalf("request failed: %s", stat.Error)
+		}
+		conns[stat.ConnID] = true
+		delayed := strings.HasSuffix(stat.URL, "/orders/1")
+		if waited := stat.StartTime.Sub(start); delayed != (waited >= 200*time.Millisecond) {
+			t.Errorf("replayed request to %s started after %s", stat.URL, waited)
+		}
+	}
+	sort.Strings(received)
+	want := []string{"DELETE /orders/1 ", "GET /a ", "GET /b ", "GET /search?q=pew https://example.com/", "POST /orders "}
+	if !reflect.DeepEqual(received, want) {
+		t.Errorf("replay sent %q wanted %q", received, want)
+	}
+	//the requests share the target's client, so its workers keep their connections
+	if maxInFlight > 2 || len(conns) > 2 {
+		t.Errorf("replay sent %d requests at a time over %d connections wanted at most 2", maxInFlight, len(conns))
+	}
+}
+
+func TestRunStressResolve(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	//pewpew.invalid can't be looked up, so only reaches the server if resolved
+	target := Target{URL: "http://pewpew.invalid:" + port + "/", Method: "GET", Count: 2, Concurrency: 1,
+		Resolve: []string{"pewpew.invalid:" + port + ":127.0.0.1"}}
+	targetStats, err := RunStress(StressConfig{Targets: []Target{target}, Quiet: true}, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("RunStress err: %s", err)
+	}
+	for _, stat := range targetStats[0] {
+		if stat.Error != nil || stat.StatusCode != http.StatusOK {
+			t.Errorf("resolved request failed: %d %v", stat.StatusCode, stat.Error)
+		}
+	}
+}
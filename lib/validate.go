@@ -0,0 +1,311 @@
+package pewpew
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	unstable "github.com/pelletier/go-toml/v2/unstable"
+	yaml "gopkg.in/yaml.v3"
+)
+
+//ConfigProblem is a setting that would stop a stress test from running
+type ConfigProblem struct {
+	//config file the setting is in, empty when it isn't in one
+	File string
+	//line of the setting in the config file, from 1, or 0 when it isn't in the file
+	Line int
+	//target whose setting it is, from 1, or 0 for a global setting
+	Target int
+	//name of the setting, like "Timeout"
+	Setting string
+	Message string
+}
+
+func (p ConfigProblem) String() string {
+	var problem string
+	if p.Target > 0 {
+		problem = "target " + strconv.Itoa(p.Target) + ": "
+	}
+	if p.Setting != "" {
+		problem += p.Setting + ": "
+	}
+	return problem + p.Message
+}
+
+//ConfigFile is a config file to validate
+type ConfigFile struct {
+	Name   string
+	Data   []byte
+	Format string //toml, json or yaml
+}
+
+//a key of a config file, and where it is
+type configKey struct {
+	target int //from 1, or 0 for a global setting
+	name   string
+	line   int
+}
+
+//where the settings of a config file are
+type configLayout struct {
+	lines       map[int]map[string]int //lower case settings of each target, or 0 for global ones, to their lines
+	targetLines []int
+	hasTargets  bool
+}
+
+//ValidateConfig checks config files, layered in order, for every problem that would stop a stress test,
+//and returns them in the order of the files and their lines.
+//Keys that aren't settings are problems, unless they are one of commandKeys, which the command line reads itself.
+//s is the stress test the files configure, with the global settings applied to its targets,
+//and each of its problems is located at the setting at fault: the target's, or else the global one it was given,
+//in the last file that sets it. When s is nil, only the keys are checked.
+func ValidateConfig(files []ConfigFile, s *StressConfig, commandKeys []string) ([]ConfigProblem, error) {
+	//keys are matched ignoring case, as they are when the config is read
+	globalSettings := settingNames(reflect.TypeOf(StressConfig{}))
+	for _, key := range commandKeys {
+		globalSettings[strings.ToLower(key)] = true
+	}
+	targetSettings := settingNames(reflect.TypeOf(Target{}))
+
+	var problems []ConfigProblem
+	layouts := make([]configLayout, len(files))
+	//the file whose targets are used, as a later file's targets replace those before
+	targetsFile := -1
+	for i, file := range files {
+		var keys []configKey
+		var err error
+		switch strings.ToLower(file.Format) {
+		case "toml":
+			keys, layouts[i].targetLines, err = tomlConfigKeys(file.Data)
+		case "json":
+			keys, layouts[i].targetLines, err = jsonConfigKeys(file.Data)
+		case "yaml", "yml":
+			keys, layouts[i].targetLines, err = yamlConfigKeys(file.Data)
+		default:
+			return nil, errors.New("unsupported format of config file " + file.Name + ": " + file.Format)
+		}
+		if err != nil {
+			return nil, errors.New("failed to parse config file " + file.Name + ": " + err.Error())
+		}
+		layouts[i].lines = make(map[int]map[string]int)
+		for _, key := range keys {
+			name := strings.ToLower(key.name)
+			if layouts[i].lines[key.target] == nil {
+				layouts[i].lines[key.target] = make(map[string]int)
+			}
+			layouts[i].lines[key.target][name] = key.line
+			if key.target == 0 && name == "targets" {
+				layouts[i].hasTargets = true
+			}
+			switch {
+			case key.target == 0 && !globalSettings[name]:
+				problems = append(problems, ConfigProblem{file.Name, key.line, 0, key.name, "unknown setting"})
+			case key.target > 0 && !targetSettings[name] && globalSettings[name]:
+				problems = append(problems, ConfigProblem{file.Name, key.line, key.target, key.name, "only a global setting, so it can't be set per target"})
+			case key.target > 0 && !targetSettings[name]:
+				problems = append(problems, ConfigProblem{file.Name, key.line, key.target, key.name, "unknown setting"})
+			}
+		}
+		if layouts[i].hasTargets || len(layouts[i].targetLines) > 0 {
+			targetsFile = i
+		}
+	}
+
+	if s != nil {
+		//only when the targets are the files', and not replaced on the command line
+		fileTargets := targetsFile != -1 && len(s.Targets) == len(layouts[targetsFile].targetLines)
+		for _, problem := range configProblems(*s) {
+			name := strings.ToLower(problem.Setting)
+			if fileTargets && problem.Target > 0 {
+				problem.File, problem.Line = files[targetsFile].Name, layouts[targetsFile].lines[problem.Target][name]
+			}
+			//the global setting the target was given
+			for i := len(files) - 1; i >= 0 && problem.Line == 0; i-- {
+				problem.File, problem.Line = files[i].Name, layouts[i].lines[0][name]
+			}
+			if problem.Line == 0 && fileTargets && problem.Target > 0 {
+				problem.File, problem.Line = files[targetsFile].Name, layouts[targetsFile].targetLines[problem.Target-1]
+			}
+			if problem.Line == 0 {
+				problem.File = ""
+			}
+			problems = append(problems, problem)
+		}
+	}
+	//problems that aren't in a file go last
+	order := make(map[string]int)
+	for i, file := range files {
+		order[file.Name] = i + 1
+	}
+	order[""] = len(files) + 1
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].File != problems[j].File {
+			return order[problems[i].File] < order[problems[j].File]
+		}
+		return problems[i].Line < problems[j].Line
+	})
+	return problems, nil
+}
+
+//the lower case names of the settings of a config struct, leaving out those only set by library users
+func settingNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Type.Kind() != reflect.Interface {
+			names[strings.ToLower(t.Field(i).Name)] = true
+		}
+	}
+	return names
+}
+
+//the keys of a TOML config, and the lines its targets start on
+func tomlConfigKeys(data []byte) ([]configKey, []int, error) {
+	var keys []configKey
+	var targetLines []int
+	target := 0
+	//in a table that's the value of a setting, like HeaderMap, so whose keys aren't settings
+	inValue := false
+	var parser unstable.Parser
+	parser.Reset(data)
+	for parser.NextExpression() {
+		expression := parser.Expression()
+		if expression.Kind != unstable.KeyValue && expression.Kind != unstable.Table && expression.Kind != unstable.ArrayTable {
+			continue
+		}
+		keyParts := expression.Key()
+		keyParts.Next()
+		name := string(keyParts.Node().Data)
+		line := parser.Shape(keyParts.Node().Raw).Start.Line
+		dotted := keyParts.Next()
+		switch {
+		case expression.Kind == unstable.KeyValue:
+			if !inValue {
+				keys = append(keys, configKey{target, name, line})
+			}
+		case strings.EqualFold(name, "Targets") && expression.Kind == unstable.ArrayTable && !dotted:
+			targetLines = append(targetLines, line)
+			target = len(targetLines)
+			inValue = false
+		case strings.EqualFold(name, "Targets") && dotted:
+			//like [Targets.HeaderMap], a setting of the last target
+			keys = append(keys, configKey{target, string(keyParts.Node().Data), parser.Shape(keyParts.Node().Raw).Start.Line})
+			inValue = true
+		default:
+			//top level tables are global settings
+			keys = append(keys, configKey{0, name, line})
+			target = 0
+			inValue = true
+		}
+	}
+	return keys, targetLines, parser.Error()
+}
+
+//the keys of a JSON config, and the lines its targets start on
+func jsonConfigKeys(data []byte) ([]configKey, []int, error) {
+	var keys []configKey
+	var targetLines []int
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	//the line of what was just read
+	line := func() int {
+		return bytes.Count(data[:decoder.InputOffset()], []byte("\n")) + 1
+	}
+	if err := expectJSONDelim(decoder, '{'); err != nil {
+		return nil, nil, err
+	}
+	for decoder.More() {
+		name, err := decoder.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, configKey{0, name.(string), line()})
+		if !strings.EqualFold(name.(string), "Targets") {
+			var value json.RawMessage
+			if err := decoder.Decode(&value); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		if err := expectJSONDelim(decoder, '['); err != nil {
+			return nil, nil, errors.New("Targets must be a list: " + err.Error())
+		}
+		for decoder.More() {
+			if err := expectJSONDelim(decoder, '{'); err != nil {
+				return nil, nil, errors.New("each of Targets must be an object: " + err.Error())
+			}
+			targetLines = append(targetLines, line())
+			for decoder.More() {
+				setting, err := decoder.Token()
+				if err != nil {
+					return nil, nil, err
+				}
+				keys = append(keys, configKey{len(targetLines), setting.(string), line()})
+				var value json.RawMessage
+				if err := decoder.Decode(&value); err != nil {
+					return nil, nil, err
+				}
+			}
+			if err := expectJSONDelim(decoder, '}'); err != nil {
+				return nil, nil, err
+			}
+		}
+		if err := expectJSONDelim(decoder, ']'); err != nil {
+			return nil, nil, err
+		}
+	}
+	return keys, targetLines, expectJSONDelim(decoder, '}')
+}
+
+//read the next token of decoder, which must be delim
+func expectJSONDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return errors.New("expected " + delim.String())
+	}
+	return nil
+}
+
+//the keys of a YAML config, and the lines its targets start on
+func yamlConfigKeys(data []byte) ([]configKey, []int, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, nil, err
+	}
+	//an empty file
+	if len(document.Content) == 0 {
+		return nil, nil, nil
+	}
+	config := document.Content[0]
+	if config.Kind != yaml.MappingNode {
+		return nil, nil, errors.New("config must be a mapping of settings")
+	}
+	var keys []configKey
+	var targetLines []int
+	for i := 0; i+1 < len(config.Content); i += 2 {
+		name, value := config.Content[i], config.Content[i+1]
+		keys = append(keys, configKey{0, name.Value, name.Line})
+		if !strings.EqualFold(name.Value, "Targets") {
+			continue
+		}
+		if value.Kind != yaml.SequenceNode {
+			return nil, nil, errors.New("Targets must be a list")
+		}
+		for _, target := range value.Content {
+			if target.Kind != yaml.MappingNode {
+				return nil, nil, errors.New("each of Targets must be a mapping of settings")
+			}
+			targetLines = append(targetLines, target.Line)
+			for j := 0; j+1 < len(target.Content); j += 2 {
+				keys = append(keys, configKey{len(targetLines), target.Content[j].Value, target.Content[j].Line})
+			}
+		}
+	}
+	return keys, targetLines, nil
+}
@@ -0,0 +1,186 @@
+package pewpew
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	//the same config in each format, with a target per kind of problem
+	stress := &StressConfig{Targets: []Target{
+		{URL: "http://localhost", Method: "GET", Count: 10, Concurrency: 1, Timeout: "5x"},
+		{URL: "http://localhost", Method: "GET", Count: 1, Concurrency: 2, HeaderList: []string{"Accept"}},
+		{URL: "http://localhost/[a-z", RegexURL: true, Method: "GET", Count: 10, Concurrency: 1, BodyFilename: "/does/not/exist"},
+	}}
+	cases := []struct {
+		config string
+		format string
+		s      *StressConfig
+		want   []ConfigProblem
+	}{
+		{`Count = 10
+Timout = "1s"
+HeaderList = ["Accept"]
+
+[[Targets]]
+URL = "http://localhost"
+Timeout = "5x"
+[[Targets]]
+URL = "http://localhost"
+concurrency = 2
+Count = 1
+Quiet = true
+[Targets.HeaderMap]
+Anything = "goes"
+[[Targets]]
+URL = "http://localhost/[a-z"
+RegexURL = true
+BodyFilename = "/does/not/exist"
+`, "toml", stress, []ConfigProblem{
+			{"config.toml", 2, 0, "Timout", "unknown setting"},
+			{"config.toml", 3, 2, "HeaderList", "header must be like Name: value: Accept"},
+			{"config.toml", 7, 1, "Timeout", "failed to parse timeout: 5x"},
+			{"config.toml", 10, 2, "Concurrency", "concurrency must be higher than request count"},
+			{"config.toml", 12, 2, "Quiet", "only a global setting, so it can't be set per target"},
+			{"config.toml", 16, 3, "URL", ""},
+			{"config.toml", 18, 3, "BodyFilename", ""},
+		}},
+		{`{
+    "Count": 10,
+    "Timout": "1s",
+    "HeaderList": ["Accept"],
+    "Targets": [
+        {
+            "URL": "http://localhost",
+            "Timeout": "5x"
+        },
+        {"URL": "http://localhost", "concurrency": 2, "Count": 1, "Quiet": true, "HeaderMap": {"Anything": "goes"}},
+        {
+            "URL": "http://localhost/[a-z",
+            "RegexURL": true,
+            "BodyFilename": "/does/not/exist"
+        }
+    ]
+}`, "json", stress, []ConfigProblem{
+			{"config.json", 3, 0, "Timout", "unknown setting"},
+			{"config.json", 4, 2, "HeaderList", "header must be like Name: value: Accept"},
+			{"config.json", 8, 1, "Timeout", "failed to parse timeout: 5x"},
+			{"config.json", 10, 2, "Quiet", "only a global setting, so it can't be set per target"},
+			{"config.json", 10, 2, "Concurrency", "concurrency must be higher than request count"},
+			{"config.json", 12, 3, "URL", ""},
+			{"config.json", 14, 3, "BodyFilename", ""},
+		}},
+		{`Count: 10
+Timout: 1s
+HeaderList: [Accept]
+Targets:
+  - URL: http://localhost
+    Timeout: 5x
+  - URL: http://localhost
+    concurrency: 2
+    Count: 1
+    Quiet: true
+    HeaderMap:
+      Anything: goes
+  - URL: http://localhost/[a-z
+    RegexURL: true
+    BodyFilename: /does/not/exist
+`, "yaml", stress, []ConfigProblem{
+			{"config.yaml", 2, 0, "Timout", "unknown setting"},
+			{"config.yaml", 3, 2, "HeaderList", "header must be like Name: value: Accept"},
+			{"config.yaml", 6, 1, "Timeout", "failed to parse timeout: 5x"},
+			{"config.yaml", 8, 2, "Concurrency", "concurrency must be higher than request count"},
+			{"config.yaml", 10, 2, "Quiet", "only a global setting, so it can't be set per target"},
+			{"config.yaml", 13, 3, "URL", ""},
+			{"config.yaml", 15, 3, "BodyFilename", ""},
+		}},
+		//problems without a line go last, and command keys are known
+		{`regex = true
+H2C = true
+`, "toml", &StressConfig{H2C: true, NoHTTP2: true}, []ConfigProblem{
+			{"config.toml", 2, 0, "H2C", "h2c requires HTTP2"},
+			{"", 0, 0, "Targets", "zero targets"},
+		}},
+		//only the keys
+		{`[[Targets]]
+URL = "http://localhost"
+Counts = 2
+`, "toml", nil, []ConfigProblem{
+			{"config.toml", 3, 1, "Counts", "unknown setting"},
+		}},
+		{"", "yaml", nil, nil},
+	}
+	for _, c := range cases {
+		problems, err := ValidateConfig([]ConfigFile{{"config." + c.format, []byte(c.config), c.format}}, c.s, []string{"regex"})
+		if err != nil {
+			t.Errorf("ValidateConfig(%s) err: %s", c.format, err)
+			continue
+		}
+		//messages of errors from elsewhere, like opening files, aren't compared
+		for i := range problems {
+			if i < len(c.want) && c.want[i].Message == "" {
+				problems[i].Message = ""
+			}
+		}
+		if !reflect.DeepEqual(problems, c.want) {
+			t.Errorf("ValidateConfig(%s) ==\n%v\nwanted\n%v", c.format, problems, c.want)
+		}
+	}
+
+	//a later file's global settings apply over the targets of an earlier one
+	files := []ConfigFile{
+		{"base.toml", []byte("Timeout = \"1s\"\n[[Targets]]\nURL = \"http://localhost\"\n[[Targets]]\nURL = \"http://localhost\"\nCount = 1\n"), "toml"},
+		{"prod.yaml", []byte("Concurrency: 2\nTimeout: 5x\nTimout: 1s\n"), "yaml"},
+	}
+	layered := &StressConfig{Targets: []Target{
+		{URL: "http://localhost", Method: "GET", Count: 10, Concurrency: 2, Timeout: "5x"},
+		{URL: "http://localhost", Method: "GET", Count: 1, Concurrency: 2, Timeout: "5x"},
+	}}
+	want := []ConfigProblem{
+		{"prod.yaml", 1, 2, "Concurrency", "concurrency must be higher than request count"},
+		{"prod.yaml", 2, 1, "Timeout", "failed to parse timeout: 5x"},
+		{"prod.yaml", 2, 2, "Timeout", "failed to parse timeout: 5x"},
+		{"prod.yaml", 3, 0, "Timout", "unknown setting"},
+	}
+	problems, err := ValidateConfig(files, layered, nil)
+	if err != nil {
+		t.Fatalf("ValidateConfig of layered files err: %s", err)
+	}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("ValidateConfig of layered files ==\n%v\nwanted\n%v", problems, want)
+	}
+
+	badConfigs := []struct {
+		config string
+		format string
+	}{
+		{`Count = `, "toml"},
+		{`{"Targets": {}}`, "json"},
+		{`{"Count": 1`, "json"},
+		{`[1, 2]`, "json"},
+		{"Targets: one", "yaml"},
+		{"- a", "yaml"},
+		{"Count=1", "ini"},
+	}
+	for _, c := range badConfigs {
+		if _, err := ValidateConfig([]ConfigFile{{"config", []byte(c.config), c.format}}, nil, nil); err == nil {
+			t.Errorf("ValidateConfig(%q, %s) succeeded", c.config, c.format)
+		}
+	}
+}
+
+func TestConfigProblemString(t *testing.T) {
+	cases := []struct {
+		p    ConfigProblem
+		want string
+	}{
+		{ConfigProblem{"", 0, 0, "", "zero targets"}, "zero targets"},
+		{ConfigProblem{"config.toml", 2, 0, "H2C", "h2c requires HTTP2"}, "H2C: h2c requires HTTP2"},
+		{ConfigProblem{"config.toml", 7, 1, "Timeout", "failed to parse timeout: 5x"}, "target 1: Timeout: failed to parse timeout: 5x"},
+	}
+	for _, c := range cases {
+		if got := c.p.String(); got != c.want {
+			t.Errorf("%#v.String() == %q wanted %q", c.p, got, c.want)
+		}
+	}
+}
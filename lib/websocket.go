@@ -0,0 +1,270 @@
+package pewpew
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	websocket "github.com/gorilla/websocket"
+)
+
+//DefaultWSIDField is the JSON field WebSocket replies are matched to their messages by
+const DefaultWSIDField = "id"
+
+//how long to wait for the last replies when a target has no Timeout
+const defaultWSReplyTimeout = 10 * time.Second
+
+//last WebSocket message ID handed out, shared by all targets so IDs are unique per run
+var lastWSMessageID int64
+
+//WebSocketStat is the saved information about one WebSocket session of a virtual user.
+//The RequestStat it belongs to covers the opening handshake.
+type WebSocketStat struct {
+	//from the end of the opening handshake until the connection closed
+	SessionDuration  time.Duration `json:"sessionDuration"`
+	MessagesSent     int           `json:"messagesSent"`
+	MessagesReceived int           `json:"messagesReceived"`
+	//time until the reply of each answered message, in the order the replies arrived
+	RoundTrips []time.Duration `json:"roundTrips"`
+	//messages sent that never got a reply with their ID
+	Unanswered int `json:"unanswered"`
+	//why the connection ended, such as "client closed" or "close 1011: internal error"
+	DisconnectReason string `json:"disconnectReason"`
+}
+
+//values available to the templates of WSMessages
+type wsMessageData struct {
+	//unique ID of the message, to be echoed in the WSIDField of the reply
+	ID string
+	//index of the message within the session, starting at 0
+	Seq int
+}
+
+func isWebSocketURL(url string) bool {
+	return strings.HasPrefix(url, "ws://") || strings.HasPrefix(url, "wss://")
+}
+
+//parse the message templates of a WebSocket target
+func parseWSMessages(messages []string) ([]*template.Template, error) {
+	templates := make([]*template.Template, len(messages))
+	for i, message := range messages {
+		tmpl, err := template.New(fmt.Sprintf("message%d", i)).Parse(message)
+		if err != nil {
+			return nil, errors.New("failed to parse WebSocket message template: " + err.Error())
+		}
+		//catch references to fields that don't exist before the test starts
+		if err := tmpl.Execute(ioutil.Discard, wsMessageData{}); err != nil {
+			return nil, errors.New("failed to execute WebSocket message template: " + err.Error())
+		}
+		templates[i] = tmpl
+	}
+	return templates, nil
+}
+
+//create the dialer used by all of a WebSocket target's virtual users
+func createWebSocketDialer(target Target, s StressConfig, sources *sourceIPs) *websocket.Dialer {
+	dialer := &net.Dialer{Timeout: parseOptionalDuration(target.DialTimeout)}
+	return &websocket.Dialer{
+		NetDialContext:    sources.dialContext(dialer),
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: !s.EnforceSSL},
+		HandshakeTimeout:  parseOptionalDuration(target.Timeout),
+		EnableCompression: target.Compress,
+	}
+}
+
+//open a WebSocket as one virtual user, send the target's messages at its rate,
+//and wait for the replies before closing
+func runWebSocket(req http.Request, dialer *websocket.Dialer, target Target, conns *connTracker) (stat RequestStat) {
+	//already validated
+	templates, _ := parseWSMessages(target.WSMessages)
+	idField := target.WSIDField
+	if idField == "" {
+		idField = DefaultWSIDField
+	}
+	reqStartTime := time.Now()
+	conn, response, err := dialer.DialContext(context.Background(), req.URL.String(), req.Header)
+	reqEndTime := time.Now()
+	stat = RequestStat{
+		Proto:     "WebSocket",
+		URL:       req.URL.String(),
+		Method:    req.Method,
+		StartTime: reqStartTime,
+		EndTime:   reqEndTime,
+		Duration:  reqEndTime.Sub(reqStartTime),
+	}
+	if response != nil {
+		stat.StatusCode = response.StatusCode
+	}
+	if err != nil {
+		stat.Error = err
+		stat.ErrorKind = classifyError(err)
+		return
+	}
+	defer conn.Close()
+	if host, _, err := net.SplitHostPort(conn.LocalAddr().String()); err == nil {
+		stat.SourceIP = host
+	}
+	if conns != nil {
+		stat.ConnID, stat.ConnStreams = conns.acquire(conn.UnderlyingConn())
+		defer conns.release(stat.ConnID)
+	}
+
+	wsStat := &WebSocketStat{}
+	stat.WebSocket = wsStat
+	var statLock sync.Mutex
+	//send times of the messages still waiting for a reply, by ID
+	pending := make(map[string]time.Time)
+	//whether replies have come with and without the ID field, as replies without one can't be waited for
+	var repliesWithIDs, repliesWithoutIDs bool
+	var dataTransferred int64
+
+	//read replies until the connection ends
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		for {
+			_, message, err := conn.ReadMessage()
+			receivedTime := time.Now()
+			if err != nil {
+				statLock.Lock()
+				if closeErr, ok := err.(*websocket.CloseError); ok {
+					if closeErr.Code == websocket.CloseNormalClosure && wsStat.DisconnectReason == "" {
+						wsStat.DisconnectReason = "server closed"
+					} else if wsStat.DisconnectReason == "" {
+						wsStat.DisconnectReason = fmt.Sprintf("close %d: %s", closeErr.Code, closeErr.Text)
+					}
+				} else if wsStat.DisconnectReason == "" {
+					wsStat.DisconnectReason = err.Error()
+				}
+				statLock.Unlock()
+				return
+			}
+			atomic.AddInt64(&dataTransferred, int64(len(message)))
+			var reply map[string]interface{}
+			statLock.Lock()
+			wsStat.MessagesReceived++
+			var id interface{}
+			hasID := false
+			if json.Unmarshal(message, &reply) == nil {
+				id, hasID = reply[idField]
+			}
+			if hasID {
+				repliesWithIDs = true
+				idStr := fmt.Sprint(id)
+				if sentTime, ok := pending[idStr]; ok {
+					wsStat.RoundTrips = append(wsStat.RoundTrips, receivedTime.Sub(sentTime))
+					delete(pending, idStr)
+				}
+			} else {
+				repliesWithoutIDs = true
+			}
+			statLock.Unlock()
+		}
+	}()
+
+	var ticker *time.Ticker
+	if target.WSMessageRate > 0 {
+		ticker = time.NewTicker(time.Duration(float64(time.Second) / target.WSMessageRate))
+		defer ticker.Stop()
+	}
+	messageCount := target.WSMessageCount
+	if messageCount == 0 {
+		messageCount = len(templates)
+	}
+sendMessages:
+	for seq := 0; seq < messageCount && len(templates) > 0; seq++ {
+		if seq > 0 && ticker != nil {
+			select {
+			case <-ticker.C:
+			case <-readerDone:
+				break sendMessages
+			}
+		}
+		select {
+		case <-readerDone:
+			//connection ended early
+			break sendMessages
+		default:
+		}
+		data := wsMessageData{ID: fmt.Sprintf("%d", atomic.AddInt64(&lastWSMessageID, 1)), Seq: seq}
+		var message bytes.Buffer
+		if err := templates[seq%len(templates)].Execute(&message, data); err != nil {
+			stat.Error = errors.New("failed to create WebSocket message: " + err.Error())
+			stat.ErrorKind = ErrorOther
+			break
+		}
+		statLock.Lock()
+		pending[data.ID] = time.Now()
+		statLock.Unlock()
+		if err := conn.WriteMessage(websocket.TextMessage, message.Bytes()); err != nil {
+			statLock.Lock()
+			delete(pending, data.ID)
+			statLock.Unlock()
+			break
+		}
+		atomic.AddInt64(&dataTransferred, int64(message.Len()))
+		statLock.Lock()
+		wsStat.MessagesSent++
+		statLock.Unlock()
+	}
+
+	//give the last replies up to the target's timeout to arrive
+	timeout := parseOptionalDuration(target.Timeout)
+	if timeout <= 0 {
+		timeout = defaultWSReplyTimeout
+	}
+	waitTimeout := time.After(timeout)
+	pollTicker := time.NewTicker(10 * time.Millisecond)
+	defer pollTicker.Stop()
+waitReplies:
+	for {
+		statLock.Lock()
+		waiting := len(pending)
+		unmatchable := repliesWithoutIDs && !repliesWithIDs
+		statLock.Unlock()
+		if waiting == 0 || unmatchable {
+			break
+		}
+		select {
+		case <-readerDone:
+			break waitReplies
+		case <-waitTimeout:
+			break waitReplies
+		case <-pollTicker.C:
+		}
+	}
+
+	//close the connection, unless the server already has
+	statLock.Lock()
+	if wsStat.DisconnectReason == "" {
+		wsStat.DisconnectReason = "client closed"
+	}
+	statLock.Unlock()
+	conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+		time.Now().Add(time.Second))
+	select {
+	case <-readerDone:
+	case <-time.After(time.Second):
+	}
+	conn.Close()
+	<-readerDone
+
+	statLock.Lock()
+	defer statLock.Unlock()
+	wsStat.SessionDuration = time.Since(reqEndTime)
+	wsStat.Unanswered = len(pending)
+	stat.DataTransferred = int(atomic.LoadInt64(&dataTransferred))
+	return
+}
@@ -0,0 +1,113 @@
+package pewpew
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	websocket "github.com/gorilla/websocket"
+)
+
+func TestParseWSMessages(t *testing.T) {
+	cases := []struct {
+		messages []string
+		hasErr   bool
+	}{
+		{nil, false},
+		{[]string{"plain"}, false},
+		{[]string{`{"id": "{{.ID}}", "seq": {{.Seq}}}`, "second"}, false},
+		{[]string{"{{.ID"}, true},     //unterminated action
+		{[]string{"{{.Nope}}"}, true}, //unknown field
+	}
+	for _, c := range cases {
+		templates, err := parseWSMessages(c.messages)
+		if (err != nil) != c.hasErr {
+			t.Errorf("parseWSMessages(%q) err: %t wanted %t", c.messages, (err != nil), c.hasErr)
+			continue
+		}
+		if err == nil && len(templates) != len(c.messages) {
+			t.Errorf("parseWSMessages(%q) parsed %d templates wanted %d", c.messages, len(templates), len(c.messages))
+		}
+	}
+}
+
+func TestRunStressWebSocket(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	//echoes every message, unless told to give up
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			messageType, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if strings.Contains(string(message), "give up") {
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "gave up"),
+					time.Now().Add(time.Second))
+				return
+			}
+			conn.WriteMessage(messageType, message)
+		}
+	}))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	cases := []struct {
+		target           Target
+		timeout          string
+		roundTrips       int
+		disconnectReason string
+		wantErr          bool
+	}{
+		{Target{WSMessages: []string{`{"id": "{{.ID}}", "seq": {{.Seq}}}`}, WSMessageCount: 5, WSMessageRate: 200}, "1s", 5, "client closed", false},
+		//no ID to match replies with, so no waiting for them, even without a timeout
+		{Target{WSMessages: []string{`{"seq": {{.Seq}}}`}, WSMessageCount: 3}, "1s", 0, "client closed", false},
+		{Target{WSMessages: []string{`{"seq": {{.Seq}}}`}, WSMessageCount: 3}, "", 0, "client closed", false},
+		//replies matched by another field
+		{Target{WSMessages: []string{`{"ref": {{.ID}}}`}, WSMessageCount: 3, WSIDField: "ref"}, "1s", 3, "client closed", false},
+		{Target{WSMessages: []string{`{"id": "{{.ID}}"}`, "give up"}, WSMessageCount: 4}, "1s", 1, "close 1011: gave up", false},
+		//without a count each message is sent once
+		{Target{WSMessages: []string{`{"id": "{{.ID}}"}`, `{"id": "{{.ID}}", "seq": {{.Seq}}}`}}, "1s", 2, "client closed", false},
+		//a template that only fails after its first message
+		{Target{WSMessages: []string{`{"id": "{{.ID}}"{{if .Seq}}{{index .ID 99}}{{end}}}`}, WSMessageCount: 2}, "1s", 1, "client closed", true},
+	}
+	for _, c := range cases {
+		c.target.URL = wsURL
+		c.target.Method = "GET"
+		c.target.Count = 4
+		c.target.Concurrency = 2
+		c.target.Timeout = c.timeout
+		targetStats, err := RunStress(StressConfig{Targets: []Target{c.target}, Quiet: true}, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("RunStress(%+v) err: %s", c.target, err)
+		}
+		for _, stat := range targetStats[0] {
+			if (stat.Error != nil) != c.wantErr {
+				t.Fatalf("WebSocket session of %+v err: %v wanted error: %t", c.target, stat.Error, c.wantErr)
+			}
+			if stat.StatusCode != http.StatusSwitchingProtocols {
+				t.Errorf("WebSocket handshake status == %d wanted %d", stat.StatusCode, http.StatusSwitchingProtocols)
+			}
+			if stat.WebSocket == nil {
+				t.Fatalf("WebSocket session of %+v has no stats", c.target)
+			}
+			if len(stat.WebSocket.RoundTrips) != c.roundTrips {
+				t.Errorf("WebSocket session of %+v round trips == %d wanted %d", c.target, len(stat.WebSocket.RoundTrips), c.roundTrips)
+			}
+			if stat.WebSocket.DisconnectReason != c.disconnectReason {
+				t.Errorf("WebSocket session of %+v disconnect reason == %q wanted %q", c.target, stat.WebSocket.DisconnectReason, c.disconnectReason)
+			}
+			if stat.WebSocket.Unanswered == 0 && stat.WebSocket.SessionDuration > 500*time.Millisecond {
+				t.Errorf("WebSocket session of %+v with nothing to wait for lasted %s", c.target, stat.WebSocket.SessionDuration)
+			}
+		}
+	}
+}